@@ -4,18 +4,32 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"fake-mc-server/internal/aggregator"
+	"fake-mc-server/internal/ban"
+	"fake-mc-server/internal/blocklist"
+	"fake-mc-server/internal/capture"
+	"fake-mc-server/internal/cli"
 	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/delay"
+	"fake-mc-server/internal/geoip"
 	"fake-mc-server/internal/limiter"
 	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/monitor"
 	"fake-mc-server/internal/network"
 	"fake-mc-server/internal/protocol"
+	"fake-mc-server/internal/rdns"
+	"fake-mc-server/internal/signature"
+	_ "fake-mc-server/internal/store" // 注册 sqlite 事件投递目标
 	"fake-mc-server/internal/sync"
+	"fake-mc-server/internal/tarpit"
 )
 
 // 构建时注入的版本信息
@@ -50,6 +64,11 @@ func printVersion() {
 }
 
 func main() {
+	// 子命令（如 replay）在解析服务器参数之前分派
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1], os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	if *showVersion {
@@ -84,10 +103,100 @@ func main() {
 		Str("config_path", *configPath).
 		Msg("启动服务器")
 
+	// 加载 GeoIP 数据库
+	geo, err := geoip.NewDatabase(&cfg.GeoIP, mainLogger)
+	if err != nil {
+		mainLogger.Error().Err(err).Msg("加载 GeoIP 数据库失败")
+		os.Exit(1)
+	}
+	if geo != nil {
+		loggerManager.GetHoneypotLogger().AddEnricher(geo.Enrich)
+		go geo.Watch(ctx, cfg.GeoIP.ReloadInterval)
+	}
+
+	// 反向解析客户端 IP
+	reverseDNS := rdns.NewTagger(&cfg.ReverseDNS, net.DefaultResolver, mainLogger)
+	if reverseDNS != nil {
+		loggerManager.GetHoneypotLogger().AddEnricher(reverseDNS.Enrich)
+		go reverseDNS.Run(ctx)
+	}
+
 	// 创建限流器
-	rateLimiter := limiter.NewRateLimiter(cfg, mainLogger)
+	rateLimiter, err := limiter.NewRateLimiter(cfg, mainLogger, geo)
+	if err != nil {
+		mainLogger.Error().Err(err).Msg("创建限流器失败")
+		os.Exit(1)
+	}
+	if err := rateLimiter.LoadState(); err != nil {
+		mainLogger.Warn().Err(err).Msg("恢复限流器状态失败，将从空状态开始")
+	}
+	defer func() {
+		if err := rateLimiter.SaveState(); err != nil {
+			mainLogger.Warn().Err(err).Msg("保存限流器状态失败")
+		}
+	}()
+	go rateLimiter.RunSnapshots(ctx)
 	rateLimiter.StartCleanupRoutine()
 
+	// 焦油坑：延迟过高的连接由共享时间轮慢慢写出响应，不占用处理协程
+	tarpitPool := tarpit.NewTarpit(&cfg.Tarpit, mainLogger)
+	go tarpitPool.Run(ctx)
+
+	// 延迟调度器：延迟等待和延迟写出共用一个分层时间轮，关闭时放弃未到期的延迟
+	delayScheduler := delay.NewScheduler(mainLogger)
+	go delayScheduler.Run(ctx)
+
+	// 自动封禁：按协议违规、登录尝试和限流次数封禁 IP
+	banManager, err := ban.NewManager(&cfg.AutoBan, cfg.Security.IPWhitelist, mainLogger)
+	if err != nil {
+		mainLogger.Error().Err(err).Msg("初始化自动封禁失败")
+		os.Exit(1)
+	}
+	if banManager != nil {
+		loggerManager.GetHoneypotLogger().AddObserver(banManager.Observe)
+		rateLimiter.SetRejectHook(banManager.RecordRateLimit)
+		defer banManager.Close()
+		go banManager.Run(ctx)
+	}
+
+	// 监控 HTTP 服务：健康检查、封禁列表导出、封禁管理
+	monitorServer := monitor.NewHTTPServer(cfg, mainLogger)
+	if cfg.Blocklist.Enabled {
+		blocklistSource, err := blocklist.NewSource(cfg, rateLimiter)
+		if err != nil {
+			mainLogger.Error().Err(err).Msg("初始化封禁列表导出失败")
+			os.Exit(1)
+		}
+		defer blocklistSource.Close()
+		monitorServer.Handle(cfg.Blocklist.Path, monitor.RequireToken(cfg.Blocklist.Token, blocklist.NewHandler(blocklistSource, &cfg.Blocklist, mainLogger)))
+	}
+	if banManager != nil {
+		apiPath := strings.TrimSuffix(cfg.AutoBan.APIPath, "/")
+		banHandler := monitor.RequireToken(cfg.AutoBan.Token, ban.NewHandler(banManager, apiPath))
+		monitorServer.Handle(apiPath, banHandler)
+		monitorServer.Handle(apiPath+"/", banHandler)
+	}
+	go monitorServer.Run(ctx)
+
+	// 加载扫描器特征库
+	var signatures *signature.Database
+	if cfg.Signature.Enabled {
+		signatures, err = signature.NewDatabase(cfg.Signature.RulesPath, mainLogger)
+		if err != nil {
+			mainLogger.Error().Err(err).Msg("加载扫描器特征库失败")
+			os.Exit(1)
+		}
+		go signatures.Watch(ctx, cfg.Signature.ReloadInterval)
+	}
+
+	// 创建抓包记录器
+	capturer, err := capture.NewCapturer(&cfg.Capture)
+	if err != nil {
+		mainLogger.Error().Err(err).Msg("初始化抓包失败")
+		os.Exit(1)
+	}
+	defer capturer.Close()
+
 	// 创建上游同步器
 	upstreamSyncer := sync.NewUpstreamSyncer(cfg, mainLogger, ctx)
 	go func() {
@@ -96,8 +205,12 @@ func main() {
 		}
 	}()
 
+	// 按 IP 聚合攻击会话
+	sessionAggregator := aggregator.NewAggregator(&cfg.Aggregation, loggerManager.GetHoneypotLogger())
+	go sessionAggregator.Run(ctx)
+
 	// 创建快速协议处理器
-	protocolHandler := protocol.NewFastHandler(cfg, mainLogger, upstreamSyncer, rateLimiter, loggerManager.GetHoneypotLogger())
+	protocolHandler := protocol.NewFastHandler(cfg, mainLogger, upstreamSyncer, rateLimiter, loggerManager.GetHoneypotLogger(), signatures, capturer, tarpitPool, delayScheduler)
 
 	// 创建网络服务器
 	server, err := network.NewServer(cfg, mainLogger, protocolHandler, ctx)
@@ -108,6 +221,7 @@ func main() {
 	if server == nil {
 		mainLogger.Fatal().Msg("网络服务器创建返回 nil")
 	}
+	server.SetAcceptFilter(banManager.Allow)
 
 	// 启动服务器
 	go func() {
@@ -134,7 +248,7 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// 启动基于 context 的监控服务
-	go startPerformanceMonitoring(ctx, performanceLogger, server, rateLimiter)
+	go startPerformanceMonitoring(ctx, performanceLogger, server, rateLimiter, loggerManager.GetHoneypotLogger())
 	go startAttackMonitoring(ctx, attackLogger, rateLimiter)
 
 	mainLogger.Info().
@@ -151,6 +265,9 @@ func main() {
 		mainLogger.Info().Msg("上下文取消")
 	}
 
+	// 在蜜罐日志随 context 关闭前输出会话的最终摘要
+	sessionAggregator.Close()
+
 	// 取消 context，通知所有组件停止（包括 loggerManager）
 	cancel()
 
@@ -161,7 +278,7 @@ func main() {
 }
 
 // startPerformanceMonitoring 启动性能监控
-func startPerformanceMonitoring(ctx context.Context, perfLogger *logger.PerformanceLogger, server *network.Server, rateLimiter *limiter.RateLimiter) {
+func startPerformanceMonitoring(ctx context.Context, perfLogger *logger.PerformanceLogger, server *network.Server, rateLimiter *limiter.RateLimiter, honeypotLogger *logger.HoneypotLogger) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
@@ -202,6 +319,11 @@ func startPerformanceMonitoring(ctx context.Context, perfLogger *logger.Performa
 					}
 				}
 			}
+
+			// 获取蜜罐日志队列统计
+			if honeypotLogger.IsEnabled() {
+				perfLogger.LogHoneypotQueueMetrics(honeypotLogger.GetStats())
+			}
 		}
 	}
 }
@@ -4,18 +4,31 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"fake-mc-server/internal/aggregator"
+	"fake-mc-server/internal/ban"
+	"fake-mc-server/internal/blocklist"
+	"fake-mc-server/internal/capture"
+	"fake-mc-server/internal/cli"
 	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/delay"
+	"fake-mc-server/internal/geoip"
 	"fake-mc-server/internal/limiter"
 	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/monitor"
 	"fake-mc-server/internal/network"
 	"fake-mc-server/internal/protocol"
+	"fake-mc-server/internal/rdns"
+	"fake-mc-server/internal/signature"
 	"fake-mc-server/internal/sync"
+	"fake-mc-server/internal/tarpit"
 )
 
 // 构建时注入的版本信息
@@ -50,6 +63,11 @@ func printVersion() {
 }
 
 func main() {
+	// 子命令（如 replay）在解析服务器参数之前分派
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1], os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	// 显示版本信息
@@ -92,9 +110,108 @@ func main() {
 	}
 	defer honeypotLogger.Close()
 
+	// 按 IP 聚合攻击会话（需要在蜜罐日志关闭前输出最终摘要）
+	sessionAggregator := aggregator.NewAggregator(&cfg.Aggregation, honeypotLogger)
+	defer sessionAggregator.Close()
+	go sessionAggregator.Run(ctx)
+
+	// 加载 GeoIP 数据库
+	if cfg.GeoIP.Enabled {
+		fmt.Println("⏳ 加载 GeoIP 数据库...")
+	}
+	geo, err := geoip.NewDatabase(&cfg.GeoIP, mainLogger)
+	if err != nil {
+		fmt.Printf("❌ 加载 GeoIP 数据库失败: %v\n", err)
+		os.Exit(1)
+	}
+	if geo != nil {
+		honeypotLogger.AddEnricher(geo.Enrich)
+		go geo.Watch(ctx, cfg.GeoIP.ReloadInterval)
+	}
+
+	// 反向解析客户端 IP（在后台协程中进行，事件写入时只读缓存）
+	reverseDNS := rdns.NewTagger(&cfg.ReverseDNS, net.DefaultResolver, mainLogger)
+	if reverseDNS != nil {
+		honeypotLogger.AddEnricher(reverseDNS.Enrich)
+		go reverseDNS.Run(ctx)
+	}
+
 	// 初始化限流器
 	fmt.Println("⏳ 初始化限流器...")
-	rateLimiter := limiter.NewRateLimiter(cfg, mainLogger)
+	rateLimiter, err := limiter.NewRateLimiter(cfg, mainLogger, geo)
+	if err != nil {
+		fmt.Printf("❌ 初始化限流器失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := rateLimiter.LoadState(); err != nil {
+		fmt.Printf("⚠️ 恢复限流器状态失败，将从空状态开始: %v\n", err)
+	}
+	defer func() {
+		if err := rateLimiter.SaveState(); err != nil {
+			fmt.Printf("⚠️ 保存限流器状态失败: %v\n", err)
+		}
+	}()
+	go rateLimiter.RunSnapshots(ctx)
+
+	// 焦油坑：延迟过高的连接由共享时间轮慢慢写出响应，不占用处理协程
+	tarpitPool := tarpit.NewTarpit(&cfg.Tarpit, mainLogger)
+	go tarpitPool.Run(ctx)
+
+	// 延迟调度器：延迟等待和延迟写出共用一个分层时间轮，关闭时放弃未到期的延迟
+	delayScheduler := delay.NewScheduler(mainLogger)
+	go delayScheduler.Run(ctx)
+
+	// 自动封禁：按协议违规、登录尝试和限流次数封禁 IP
+	banManager, err := ban.NewManager(&cfg.AutoBan, cfg.Security.IPWhitelist, mainLogger)
+	if err != nil {
+		fmt.Printf("❌ 初始化自动封禁失败: %v\n", err)
+		os.Exit(1)
+	}
+	if banManager != nil {
+		honeypotLogger.AddObserver(banManager.Observe)
+		rateLimiter.SetRejectHook(banManager.RecordRateLimit)
+		defer banManager.Close()
+		go banManager.Run(ctx)
+	}
+
+	// 监控 HTTP 服务：健康检查、封禁列表导出、封禁管理
+	monitorServer := monitor.NewHTTPServer(cfg, mainLogger)
+	if cfg.Blocklist.Enabled {
+		blocklistSource, err := blocklist.NewSource(cfg, rateLimiter)
+		if err != nil {
+			fmt.Printf("❌ 初始化封禁列表导出失败: %v\n", err)
+			os.Exit(1)
+		}
+		defer blocklistSource.Close()
+		monitorServer.Handle(cfg.Blocklist.Path, monitor.RequireToken(cfg.Blocklist.Token, blocklist.NewHandler(blocklistSource, &cfg.Blocklist, mainLogger)))
+	}
+	if banManager != nil {
+		apiPath := strings.TrimSuffix(cfg.AutoBan.APIPath, "/")
+		banHandler := monitor.RequireToken(cfg.AutoBan.Token, ban.NewHandler(banManager, apiPath))
+		monitorServer.Handle(apiPath, banHandler)
+		monitorServer.Handle(apiPath+"/", banHandler)
+	}
+	go monitorServer.Run(ctx)
+
+	// 加载扫描器特征库
+	var signatures *signature.Database
+	if cfg.Signature.Enabled {
+		fmt.Println("⏳ 加载扫描器特征库...")
+		signatures, err = signature.NewDatabase(cfg.Signature.RulesPath, mainLogger)
+		if err != nil {
+			fmt.Printf("❌ 加载扫描器特征库失败: %v\n", err)
+			os.Exit(1)
+		}
+		go signatures.Watch(ctx, cfg.Signature.ReloadInterval)
+	}
+
+	// 初始化抓包记录器
+	capturer, err := capture.NewCapturer(&cfg.Capture)
+	if err != nil {
+		fmt.Printf("❌ 初始化抓包失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer capturer.Close()
 
 	// 初始化上游同步器
 	fmt.Println("⏳ 初始化上游同步器...")
@@ -117,6 +234,10 @@ func main() {
 		upstreamSyncer,
 		honeypotLogger,
 		rateLimiter,
+		signatures,
+		capturer,
+		tarpitPool,
+		delayScheduler,
 	)
 
 	// 创建网络服务器
@@ -126,6 +247,7 @@ func main() {
 		fmt.Printf("❌ 创建网络服务器失败: %v\n", err)
 		os.Exit(1)
 	}
+	server.SetAcceptFilter(banManager.Allow)
 
 	// 启动服务器
 	go func() {
@@ -179,6 +301,16 @@ func main() {
 	stats := server.GetStats()
 	fmt.Println("📈 服务器统计:")
 	fmt.Printf("   - 当前连接数: %v\n", stats["connection_count"])
+	delayStats := delayScheduler.GetStats()
+	fmt.Printf("   - 延迟响应: 按时写出 %v, 放弃 %v\n", delayStats["delayed_responses"], delayStats["cancelled_delays"])
+	if tarpitStats := tarpitPool.GetStats(); tarpitStats != nil {
+		fmt.Printf("   - 焦油坑: 累计 %v, 名额已满 %v\n", tarpitStats["total_connections"], tarpitStats["refused_connections"])
+	}
+	if honeypotLogger.IsEnabled() {
+		honeypotStats := honeypotLogger.GetStats()
+		fmt.Printf("   - 蜜罐事件: 写入 %v, 丢弃 %v, 投递目标丢弃 %v\n",
+			honeypotStats["written_events"], honeypotStats["dropped_events"], honeypotStats["sink_dropped_events"])
+	}
 
 	fmt.Println("👋 FakeMCServer (GoMC Edition) 已停止")
 }
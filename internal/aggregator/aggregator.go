@@ -0,0 +1,255 @@
+// Package aggregator 将同一 IP 的蜜罐事件按时间间隔聚合为攻击会话
+package aggregator
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+// EventType 会话摘要的事件类型
+const EventType = "attack_session"
+
+// session 正在跟踪的会话
+type session struct {
+	ip          string
+	firstSeen   time.Time
+	lastSeen    time.Time
+	connections int
+	events      int
+	eventCounts map[string]int
+	hostnames   map[string]struct{}
+	usernames   map[string]struct{}
+	protocols   map[int]struct{}
+	totalDelay  int64
+
+	minute        time.Time // 当前统计连接速率的分钟
+	minuteConns   int
+	maxConnPerMin int
+
+	dirty bool          // 上次输出摘要后是否有新事件
+	elem  *list.Element // 在 Aggregator.order 中的位置
+}
+
+// Aggregator 攻击会话聚合器
+// 通过 HoneypotLogger 的观察者接收原始事件，并把会话摘要作为 attack_session 事件写回同一日志
+// Observe 在日志写入协程中执行，不能直接写日志（block 策略下会自锁），摘要统一在锁外输出
+type Aggregator struct {
+	config   *config.AggregationConfig
+	honeypot *logger.HoneypotLogger
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	order    list.List               // 按最近活动排序的会话，队首为最近活动
+	pending  []*logger.HoneypotEvent // 处理事件时结束的会话，下次 Tick 时输出
+	now      func() time.Time
+}
+
+// NewAggregator 创建聚合器并注册到蜜罐日志，未启用时返回 nil
+func NewAggregator(cfg *config.AggregationConfig, honeypotLogger *logger.HoneypotLogger) *Aggregator {
+	if !cfg.Enabled || !honeypotLogger.IsEnabled() {
+		return nil
+	}
+	a := &Aggregator{
+		config:   cfg,
+		honeypot: honeypotLogger,
+		sessions: make(map[string]*session),
+		now:      time.Now,
+	}
+	honeypotLogger.AddObserver(a.Observe)
+	return a
+}
+
+// Observe 将一条事件计入对应 IP 的会话
+func (a *Aggregator) Observe(event *logger.HoneypotEvent) {
+	if a == nil || event.EventType == EventType || event.ClientIP == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.sessions[event.ClientIP]
+	if s != nil && event.Timestamp.Sub(s.lastSeen) > a.config.SessionGap {
+		// 间隔过长，旧会话结束，开始新会话
+		a.pending = append(a.pending, s.summary(true))
+		a.remove(s)
+		s = nil
+	}
+	if s == nil {
+		if len(a.sessions) >= a.config.MaxSessions {
+			a.evictOldest()
+		}
+		s = &session{
+			ip:          event.ClientIP,
+			firstSeen:   event.Timestamp,
+			eventCounts: make(map[string]int),
+			hostnames:   make(map[string]struct{}),
+			usernames:   make(map[string]struct{}),
+			protocols:   make(map[int]struct{}),
+		}
+		s.elem = a.order.PushFront(s)
+		a.sessions[event.ClientIP] = s
+	} else {
+		a.order.MoveToFront(s.elem)
+	}
+	a.update(s, event)
+}
+
+// update 更新会话统计
+func (a *Aggregator) update(s *session, event *logger.HoneypotEvent) {
+	if event.Timestamp.After(s.lastSeen) {
+		s.lastSeen = event.Timestamp
+	}
+	s.events++
+	s.eventCounts[event.EventType]++
+	s.totalDelay += event.DelayApplied
+	s.dirty = true
+
+	// 按握手事件计数连接；没有握手的连接（如横幅抓取）只有结束时不带协议版本的 fingerprint 事件
+	if event.EventType == "handshake" || (event.EventType == "fingerprint" && event.ProtocolVersion == 0) {
+		s.connections++
+		minute := event.Timestamp.Truncate(time.Minute)
+		if !minute.Equal(s.minute) {
+			s.minute, s.minuteConns = minute, 0
+		}
+		s.minuteConns++
+		s.maxConnPerMin = max(s.maxConnPerMin, s.minuteConns)
+	}
+
+	limit := a.config.MaxDistinct
+	if event.ServerAddress != "" && len(s.hostnames) < limit {
+		s.hostnames[event.ServerAddress] = struct{}{}
+	}
+	if event.Username != "" && len(s.usernames) < limit {
+		s.usernames[event.Username] = struct{}{}
+	}
+	if event.ProtocolVersion != 0 && len(s.protocols) < limit {
+		s.protocols[event.ProtocolVersion] = struct{}{}
+	}
+}
+
+// evictOldest 会话数达到上限时提前结束最久未活动的会话
+func (a *Aggregator) evictOldest() {
+	if oldest := a.order.Back(); oldest != nil {
+		s := oldest.Value.(*session)
+		a.pending = append(a.pending, s.summary(true))
+		a.remove(s)
+	}
+}
+
+// remove 停止跟踪会话
+func (a *Aggregator) remove(s *session) {
+	a.order.Remove(s.elem)
+	delete(a.sessions, s.ip)
+}
+
+// summary 生成会话摘要事件
+func (s *session) summary(ended bool) *logger.HoneypotEvent {
+	s.dirty = false
+	return &logger.HoneypotEvent{
+		ClientIP:  s.ip,
+		EventType: EventType,
+		Session:   s.attackSession(ended),
+	}
+}
+
+// attackSession 生成会话统计的快照
+func (s *session) attackSession(ended bool) *logger.AttackSession {
+	return &logger.AttackSession{
+		ID:            fmt.Sprintf("%s-%d", s.ip, s.firstSeen.UnixMilli()),
+		FirstSeen:     s.firstSeen,
+		LastSeen:      s.lastSeen,
+		Connections:   s.connections,
+		Events:        s.events,
+		EventCounts:   maps.Clone(s.eventCounts),
+		Hostnames:     slices.Sorted(maps.Keys(s.hostnames)),
+		Usernames:     slices.Sorted(maps.Keys(s.usernames)),
+		Protocols:     slices.Sorted(maps.Keys(s.protocols)),
+		MaxConnPerMin: s.maxConnPerMin,
+		TotalDelayMs:  s.totalDelay,
+		Ended:         ended,
+	}
+}
+
+// collect 取出待输出的摘要；final 为 true 时结束所有会话
+func (a *Aggregator) collect(final bool) []*logger.HoneypotEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	summaries := a.pending
+	a.pending = nil
+	now := a.now()
+	for _, s := range a.sessions {
+		switch {
+		case final || now.Sub(s.lastSeen) > a.config.SessionGap:
+			summaries = append(summaries, s.summary(true))
+			a.remove(s)
+		case s.dirty:
+			summaries = append(summaries, s.summary(false))
+		}
+	}
+	return summaries
+}
+
+// emit 将会话摘要写入蜜罐日志
+func (a *Aggregator) emit(summaries []*logger.HoneypotEvent) {
+	for _, summary := range summaries {
+		summary.Timestamp = a.now()
+		a.honeypot.LogEvent(summary)
+	}
+}
+
+// Tick 结束超过间隔未活动的会话，并为有新事件的活跃会话输出阶段性摘要
+func (a *Aggregator) Tick() {
+	if a == nil {
+		return
+	}
+	a.emit(a.collect(false))
+}
+
+// Run 按配置的间隔输出摘要，直到 ctx 取消
+func (a *Aggregator) Run(ctx context.Context) {
+	if a == nil {
+		return
+	}
+
+	ticker := time.NewTicker(a.config.EmitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Tick()
+		}
+	}
+}
+
+// Close 等待已记录的事件处理完毕后，结束所有会话并输出最终摘要
+// 需要在蜜罐日志关闭前调用
+func (a *Aggregator) Close() {
+	if a == nil {
+		return
+	}
+	a.honeypot.Flush()
+	a.emit(a.collect(true))
+}
+
+// ActiveSessions 当前跟踪的会话数
+func (a *Aggregator) ActiveSessions() int {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.sessions)
+}
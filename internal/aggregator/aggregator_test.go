@@ -0,0 +1,111 @@
+package aggregator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+func TestAggregatorSessions(t *testing.T) {
+	var buf bytes.Buffer
+	honeypotLogger := logger.NewHoneypotWriterLogger(&buf)
+	defer honeypotLogger.Close()
+
+	a := NewAggregator(&config.AggregationConfig{
+		Enabled:     true,
+		SessionGap:  10 * time.Minute,
+		MaxSessions: 10,
+		MaxDistinct: 10,
+	}, honeypotLogger)
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := base
+	a.now = func() time.Time { return now }
+
+	ip := "192.0.2.1"
+	// 扫描器在一分钟内做了状态查询、两次登录尝试和一次横幅抓取
+	for i, e := range []logger.HoneypotEvent{
+		{EventType: "handshake", ServerAddress: "mc.example.com", ProtocolVersion: 765},
+		{EventType: "fingerprint", ProtocolVersion: 765},
+		{EventType: "handshake", ServerAddress: "play.example.com", ProtocolVersion: 47},
+		{EventType: "login_attempt", Username: "admin", DelayApplied: 200},
+		{EventType: "login_attempt", Username: "root", DelayApplied: 300},
+		{EventType: "fingerprint", ProtocolVersion: 47},
+		{EventType: "fingerprint"}, // 没有握手的横幅抓取
+	} {
+		e.ClientIP = ip
+		e.Timestamp = base.Add(time.Duration(i) * time.Second)
+		a.Observe(&e)
+	}
+	// 间隔超过 session_gap 后同一 IP 开始新会话
+	a.Observe(&logger.HoneypotEvent{ClientIP: ip, EventType: "fingerprint", Timestamp: base.Add(time.Hour)})
+
+	now = base.Add(time.Hour + time.Minute)
+	a.Tick()
+	a.Close()
+	honeypotLogger.Flush()
+
+	var sessions []*logger.AttackSession
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event logger.HoneypotEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("解析事件失败: %v", err)
+		}
+		if event.EventType == EventType {
+			if event.ClientIP != ip {
+				t.Errorf("ClientIP = %q, want %q", event.ClientIP, ip)
+			}
+			sessions = append(sessions, event.Session)
+		}
+	}
+
+	// 第一个会话在新事件到来时结束，第二个会话先输出阶段性摘要，关闭时再输出最终摘要
+	if len(sessions) != 3 {
+		t.Fatalf("输出 %d 条会话摘要, want 3", len(sessions))
+	}
+	first := sessions[0]
+	if !first.Ended || first.Connections != 3 || first.Events != 7 || first.MaxConnPerMin != 3 || first.TotalDelayMs != 500 {
+		t.Errorf("第一个会话 = %+v", first)
+	}
+	if !slices.Equal(first.Hostnames, []string{"mc.example.com", "play.example.com"}) ||
+		!slices.Equal(first.Usernames, []string{"admin", "root"}) ||
+		!slices.Equal(first.Protocols, []int{47, 765}) {
+		t.Errorf("第一个会话的去重字段 = %v %v %v", first.Hostnames, first.Usernames, first.Protocols)
+	}
+	if sessions[1].Ended || !sessions[2].Ended || sessions[1].ID != sessions[2].ID || sessions[1].ID == first.ID {
+		t.Errorf("第二个会话摘要 = %+v, %+v", sessions[1], sessions[2])
+	}
+}
+
+// TestAggregatorEvictsLeastRecent 会话数达到上限时结束最久未活动的会话
+func TestAggregatorEvictsLeastRecent(t *testing.T) {
+	var buf bytes.Buffer
+	honeypotLogger := logger.NewHoneypotWriterLogger(&buf)
+	defer honeypotLogger.Close()
+
+	a := NewAggregator(&config.AggregationConfig{
+		Enabled:     true,
+		SessionGap:  10 * time.Minute,
+		MaxSessions: 2,
+		MaxDistinct: 10,
+	}, honeypotLogger)
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.1", "192.0.2.3"} {
+		a.Observe(&logger.HoneypotEvent{ClientIP: ip, EventType: "fingerprint", Timestamp: base.Add(time.Duration(i) * time.Second)})
+	}
+
+	if n := a.ActiveSessions(); n != 2 {
+		t.Fatalf("ActiveSessions() = %d, want 2", n)
+	}
+	if len(a.pending) != 1 || a.pending[0].ClientIP != "192.0.2.2" || !a.pending[0].Session.Ended {
+		t.Errorf("被淘汰的会话 = %+v", a.pending)
+	}
+}
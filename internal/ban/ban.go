@@ -0,0 +1,430 @@
+// Package ban 按违规次数自动封禁 IP，重复违规时封禁时长递增，封禁记录持久化到磁盘
+package ban
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/netutil"
+)
+
+// 计数的违规类型
+const (
+	KindViolation = "violation"  // 协议违规
+	KindLogin     = "login"      // 登录尝试
+	KindRateLimit = "rate_limit" // 触发 IP 限流
+)
+
+// Ban 一条封禁记录
+// 封禁结束后记录保留到 ForgetAfter，期间再次封禁时按 Offences 递增时长
+type Ban struct {
+	IP       string    `json:"ip"`
+	Reason   string    `json:"reason"`
+	Offences int       `json:"offences"`
+	Start    time.Time `json:"start"`
+	Until    time.Time `json:"until"`
+}
+
+// Active 封禁是否仍在生效
+func (b *Ban) Active(now time.Time) bool {
+	return now.Before(b.Until)
+}
+
+// counter 一个 IP 在当前窗口内的违规次数
+type counter struct {
+	ip          string
+	windowStart time.Time
+	counts      map[string]int
+	elem        *list.Element // 在 Manager.order 中的位置
+}
+
+// Manager 自动封禁管理器
+// Allow 在接受连接时调用，只持有读锁；Observe 在蜜罐日志写入协程中执行，封禁只写运行日志，不写蜜罐事件（block 策略下会自锁）
+type Manager struct {
+	config    *config.AutoBanConfig
+	whitelist []netip.Prefix
+	logger    zerolog.Logger
+
+	mu       sync.RWMutex
+	bans     map[string]*Ban
+	counters map[string]*counter
+	order    list.List // 按最近计数排序的计数器，队首为最近计数，超过 MaxTrackedIPs 时淘汰队尾
+	dirty    bool
+	now      func() time.Time
+}
+
+// NewManager 创建封禁管理器并恢复持久化的封禁记录，未启用时返回 nil
+// whitelist 中的地址和网段永不封禁
+func NewManager(cfg *config.AutoBanConfig, whitelist []string, logger zerolog.Logger) (*Manager, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	m := &Manager{
+		config:   cfg,
+		logger:   logger.With().Str("component", "auto_ban").Logger(),
+		bans:     make(map[string]*Ban),
+		counters: make(map[string]*counter),
+		now:      time.Now,
+	}
+	for _, s := range whitelist {
+		prefix, err := netutil.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的白名单地址 %q: %w", s, err)
+		}
+		m.whitelist = append(m.whitelist, prefix)
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Allow 判断是否接受该 IP 的连接，可作为 network.AcceptFilter 使用
+func (m *Manager) Allow(ip string) bool {
+	if m == nil {
+		return true
+	}
+	m.mu.RLock()
+	b := m.bans[ip]
+	banned := b != nil && b.Active(m.now())
+	m.mu.RUnlock()
+	return !banned
+}
+
+// Observe 按蜜罐事件计数协议违规和登录尝试
+func (m *Manager) Observe(event *logger.HoneypotEvent) {
+	if m == nil {
+		return
+	}
+	switch event.EventType {
+	case "protocol_violation":
+		m.record(event.ClientIP, KindViolation)
+	case "login_attempt":
+		m.record(event.ClientIP, KindLogin)
+	}
+}
+
+// RecordRateLimit 记录一次 IP 限流，可作为限流器的拒绝回调使用
+func (m *Manager) RecordRateLimit(ip string) {
+	if m == nil {
+		return
+	}
+	m.record(ip, KindRateLimit)
+}
+
+// record 计入一次违规，超过阈值时封禁
+func (m *Manager) record(ip, kind string) {
+	if ip == "" || m.whitelisted(ip) {
+		return
+	}
+	limit := m.limit(kind)
+	if limit < 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if b := m.bans[ip]; b != nil && b.Active(now) {
+		return
+	}
+	c := m.counters[ip]
+	switch {
+	case c == nil:
+		if m.config.MaxTrackedIPs > 0 && len(m.counters) >= m.config.MaxTrackedIPs {
+			m.removeCounter(m.order.Back().Value.(*counter).ip)
+		}
+		c = &counter{ip: ip, windowStart: now, counts: make(map[string]int)}
+		c.elem = m.order.PushFront(c)
+		m.counters[ip] = c
+	case now.Sub(c.windowStart) >= m.config.Window:
+		c.windowStart = now
+		clear(c.counts)
+		m.order.MoveToFront(c.elem)
+	default:
+		m.order.MoveToFront(c.elem)
+	}
+	c.counts[kind]++
+	if c.counts[kind] <= limit {
+		return
+	}
+
+	m.removeCounter(ip)
+	reason := fmt.Sprintf("%s 超过 %d 次/%s", kind, limit, m.config.Window)
+	m.ban(ip, 0, reason, now)
+}
+
+// removeCounter 停止计数，调用方需持有写锁
+func (m *Manager) removeCounter(ip string) {
+	if c := m.counters[ip]; c != nil {
+		m.order.Remove(c.elem)
+		delete(m.counters, ip)
+	}
+}
+
+// limit 违规类型对应的阈值
+func (m *Manager) limit(kind string) int {
+	switch kind {
+	case KindViolation:
+		return m.config.MaxViolations
+	case KindLogin:
+		return m.config.MaxLoginAttempts
+	default:
+		return m.config.MaxRateLimitHits
+	}
+}
+
+// Ban 手动封禁，duration 为 0 时按递增规则计算时长
+func (m *Manager) Ban(ip string, duration time.Duration, reason string) (*Ban, error) {
+	if m == nil {
+		return nil, errors.New("自动封禁未启用")
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 IP 地址 %q", ip)
+	}
+	ip = addr.Unmap().String()
+	if m.whitelisted(ip) {
+		return nil, fmt.Errorf("%s 在白名单中", ip)
+	}
+	if reason == "" {
+		reason = "manual"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeCounter(ip)
+	b := *m.ban(ip, duration, reason, m.now())
+	return &b, nil
+}
+
+// ban 记录封禁，调用方需持有写锁
+func (m *Manager) ban(ip string, duration time.Duration, reason string, now time.Time) *Ban {
+	b := m.bans[ip]
+	if b == nil || (!b.Active(now) && now.Sub(b.Until) > m.config.ForgetAfter) {
+		b = &Ban{IP: ip}
+		m.bans[ip] = b
+	}
+	b.Offences++
+	if duration <= 0 {
+		duration = m.duration(b.Offences)
+	}
+	b.Reason = reason
+	b.Start = now
+	b.Until = now.Add(duration)
+	m.dirty = true
+
+	m.logger.Warn().
+		Str("ip", ip).
+		Str("reason", reason).
+		Int("offences", b.Offences).
+		Dur("duration", duration).
+		Msg("IP 已封禁")
+	return b
+}
+
+// duration 第 offences 次封禁的时长：BanDuration × Multiplier^(offences-1)，不超过 MaxBanDuration
+func (m *Manager) duration(offences int) time.Duration {
+	d := float64(m.config.BanDuration) * math.Pow(m.config.Multiplier, float64(offences-1))
+	if m.config.MaxBanDuration > 0 && d > float64(m.config.MaxBanDuration) {
+		return m.config.MaxBanDuration
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// Unban 解除封禁并清除违规记录，返回该 IP 是否处于封禁中
+func (m *Manager) Unban(ip string) bool {
+	if m == nil {
+		return false
+	}
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		ip = addr.Unmap().String()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.bans[ip]
+	delete(m.bans, ip)
+	m.removeCounter(ip)
+	if b == nil {
+		return false
+	}
+	m.dirty = true
+	m.logger.Info().Str("ip", ip).Msg("IP 已解除封禁")
+	return b.Active(m.now())
+}
+
+// List 返回生效中的封禁，按到期时间排序
+func (m *Manager) List() []Ban {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	now := m.now()
+	bans := []Ban{}
+	for _, b := range m.bans {
+		if b.Active(now) {
+			bans = append(bans, *b)
+		}
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(bans, func(a, b Ban) int {
+		if c := a.Until.Compare(b.Until); c != 0 {
+			return c
+		}
+		return strings.Compare(a.IP, b.IP)
+	})
+	return bans
+}
+
+// Run 定期清理过期的计数和记录，并保存变更，直到 ctx 取消
+func (m *Manager) Run(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.prune()
+			if err := m.save(); err != nil {
+				m.logger.Warn().Err(err).Msg("保存封禁记录失败")
+			}
+		}
+	}
+}
+
+// Close 保存封禁记录
+func (m *Manager) Close() error {
+	if m == nil {
+		return nil
+	}
+	return m.save()
+}
+
+// prune 清理过期的窗口计数和已被遗忘的封禁记录
+func (m *Manager) prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for ip, c := range m.counters {
+		if now.Sub(c.windowStart) >= m.config.Window {
+			m.removeCounter(ip)
+		}
+	}
+	for ip, b := range m.bans {
+		if !b.Active(now) && now.Sub(b.Until) > m.config.ForgetAfter {
+			delete(m.bans, ip)
+			m.dirty = true
+		}
+	}
+}
+
+// load 读取持久化的封禁记录，文件不存在时视为没有记录
+func (m *Manager) load() error {
+	if m.config.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.config.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取封禁记录失败: %w", err)
+	}
+
+	var bans []*Ban
+	if err := sonic.Unmarshal(data, &bans); err != nil {
+		return fmt.Errorf("解析封禁记录失败: %w", err)
+	}
+	for _, b := range bans {
+		if b.IP != "" {
+			m.bans[b.IP] = b
+		}
+	}
+	m.logger.Info().Int("count", len(m.bans)).Str("path", m.config.StatePath).Msg("已恢复封禁记录")
+	return nil
+}
+
+// save 有变更时写入封禁记录，先写临时文件再替换，避免中途退出留下不完整的文件
+func (m *Manager) save() error {
+	if m.config.StatePath == "" {
+		return nil
+	}
+	m.mu.Lock()
+	if !m.dirty {
+		m.mu.Unlock()
+		return nil
+	}
+	bans := make([]Ban, 0, len(m.bans))
+	for _, b := range m.bans {
+		bans = append(bans, *b)
+	}
+	m.dirty = false
+	m.mu.Unlock()
+
+	slices.SortFunc(bans, func(a, b Ban) int { return strings.Compare(a.IP, b.IP) })
+	err := m.write(bans)
+	if err != nil {
+		// 下次重试
+		m.mu.Lock()
+		m.dirty = true
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// write 原子地写入封禁记录文件
+func (m *Manager) write(bans []Ban) error {
+	data, err := sonic.ConfigStd.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.config.StatePath), 0755); err != nil {
+		return err
+	}
+	tmp := m.config.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.config.StatePath)
+}
+
+// whitelisted 判断 IP 是否在白名单中
+func (m *Manager) whitelisted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range m.whitelist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package ban
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+func newTestManager(t *testing.T, statePath string, now *time.Time) *Manager {
+	t.Helper()
+	cfg := &config.AutoBanConfig{
+		Enabled:          true,
+		Window:           time.Minute,
+		MaxViolations:    2,
+		MaxLoginAttempts: -1,
+		MaxRateLimitHits: 3,
+		BanDuration:      time.Minute,
+		Multiplier:       4,
+		MaxBanDuration:   time.Hour,
+		ForgetAfter:      24 * time.Hour,
+		StatePath:        statePath,
+	}
+	m, err := NewManager(cfg, []string{"10.0.0.0/8"}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func TestEscalation(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newTestManager(t, "", &now)
+	violation := func(ip string) {
+		m.Observe(&logger.HoneypotEvent{ClientIP: ip, EventType: "protocol_violation"})
+	}
+
+	for range 2 {
+		violation("192.0.2.1")
+	}
+	if !m.Allow("192.0.2.1") {
+		t.Fatal("未超过阈值时不应封禁")
+	}
+	violation("192.0.2.1")
+	if m.Allow("192.0.2.1") {
+		t.Fatal("超过阈值后应封禁")
+	}
+
+	// 第二次封禁时长翻 4 倍，第三次达到上限
+	for i, want := range []time.Duration{4 * time.Minute, 16 * time.Minute, time.Hour, time.Hour} {
+		now = m.List()[0].Until
+		if !m.Allow("192.0.2.1") {
+			t.Fatalf("第 %d 次封禁到期后应放行", i+1)
+		}
+		for range 3 {
+			violation("192.0.2.1")
+		}
+		bans := m.List()
+		if len(bans) != 1 || bans[0].Offences != i+2 || bans[0].Until.Sub(now) != want {
+			t.Fatalf("第 %d 次封禁 = %+v, want 时长 %s", i+2, bans, want)
+		}
+	}
+
+	// 长时间未再犯，重新按首次封禁计算
+	now = m.List()[0].Until.Add(25 * time.Hour)
+	for range 3 {
+		violation("192.0.2.1")
+	}
+	if b := m.List()[0]; b.Offences != 1 || b.Until.Sub(now) != time.Minute {
+		t.Errorf("遗忘后封禁 = %+v", b)
+	}
+
+	// 白名单和禁用的阈值
+	for range 10 {
+		violation("10.1.2.3")
+		m.Observe(&logger.HoneypotEvent{ClientIP: "192.0.2.2", EventType: "login_attempt"})
+		m.RecordRateLimit("192.0.2.3")
+	}
+	if !m.Allow("10.1.2.3") || !m.Allow("192.0.2.2") || m.Allow("192.0.2.3") {
+		t.Error("白名单或阈值处理错误")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "state", "bans.json")
+	m := newTestManager(t, path, &now)
+	if _, err := m.Ban("192.0.2.1", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Ban("10.0.0.1", 0, ""); err == nil {
+		t.Error("白名单地址不应被封禁")
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newTestManager(t, path, &now)
+	if reloaded.Allow("192.0.2.1") {
+		t.Fatal("重启后应恢复封禁")
+	}
+	bans := reloaded.List()
+	if len(bans) != 1 || bans[0].Reason != "manual" || bans[0].Offences != 1 {
+		t.Errorf("恢复的封禁 = %+v", bans)
+	}
+}
+
+func TestCountersBounded(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newTestManager(t, "", &now)
+	m.config.MaxTrackedIPs = 2
+	violation := func(ip string) {
+		m.Observe(&logger.HoneypotEvent{ClientIP: ip, EventType: "protocol_violation"})
+	}
+
+	// 伪造源地址的洪水只保留最近的计数，最久未违规的 IP 被淘汰后重新计数
+	violation("192.0.2.1")
+	violation("192.0.2.1")
+	violation("192.0.2.2")
+	violation("192.0.2.3")
+	if len(m.counters) != 2 || m.order.Len() != 2 || m.counters["192.0.2.1"] != nil {
+		t.Fatalf("计数器 = %v", m.counters)
+	}
+	violation("192.0.2.1")
+	if !m.Allow("192.0.2.1") {
+		t.Error("被淘汰的计数不应累计")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Now()
+	m := newTestManager(t, "", &now)
+	handler := NewHandler(m, "/bans")
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/bans?ip=192.0.2.7&duration=30m&reason=test"); rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/bans?ip=bad"); rec.Code != http.StatusBadRequest {
+		t.Errorf("无效 IP: status = %d", rec.Code)
+	}
+	rec := do(http.MethodGet, "/bans")
+	if !strings.Contains(rec.Body.String(), `"ip": "192.0.2.7"`) || !strings.Contains(rec.Body.String(), `"reason": "test"`) {
+		t.Errorf("GET body = %s", rec.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/bans/192.0.2.7"); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/bans/192.0.2.7"); rec.Code != http.StatusNotFound {
+		t.Errorf("重复 DELETE status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/bans"); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("解封后 GET body = %s", rec.Body.String())
+	}
+}
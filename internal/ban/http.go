@@ -0,0 +1,80 @@
+package ban
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Handler 封禁管理接口，令牌校验由 monitor.RequireToken 完成
+//
+//	GET    {path}                              列出生效中的封禁
+//	POST   {path}?ip=&duration=&reason=        手动封禁，duration 省略时按递增规则计算
+//	DELETE {path}/{ip}                         解除封禁
+type Handler struct {
+	manager *Manager
+	path    string
+}
+
+// NewHandler 创建管理接口，需同时注册 path 和 path + "/"
+func NewHandler(manager *Manager, path string) *Handler {
+	return &Handler{manager: manager, path: strings.TrimSuffix(path, "/")}
+}
+
+// ServeHTTP 处理管理请求
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, http.StatusOK, h.manager.List())
+
+	case http.MethodPost:
+		query := r.URL.Query()
+		var duration time.Duration
+		if v := query.Get("duration"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				http.Error(w, "无效的 duration", http.StatusBadRequest)
+				return
+			}
+			duration = d
+		}
+		b, err := h.manager.Ban(query.Get("ip"), duration, query.Get("reason"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+
+	case http.MethodDelete:
+		ip := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, h.path), "/")
+		if ip == "" {
+			ip = r.URL.Query().Get("ip")
+		}
+		if ip == "" {
+			http.Error(w, "缺少 IP", http.StatusBadRequest)
+			return
+		}
+		if !h.manager.Unban(ip) {
+			http.Error(w, "IP 未被封禁", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := sonic.ConfigStd.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
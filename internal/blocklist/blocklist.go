@@ -0,0 +1,204 @@
+// Package blocklist 根据蜜罐观察到的 IP 生成可供防火墙使用的封禁列表
+package blocklist
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"fake-mc-server/internal/limiter"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/netutil"
+)
+
+// Criteria 列入封禁列表的条件，同时设置多项时需全部满足
+type Criteria struct {
+	MinEvents      int       // 窗口内符合类型的事件数下限
+	MinConnections int64     // 限流器记录的连接数下限
+	EventTypes     []string  // 只统计这些类型的事件，为空统计全部
+	Labels         []string  // 命中任一扫描器特征标签，为空不检查
+	Since          time.Time // 时间窗口，零值不限制
+	Until          time.Time
+}
+
+// Entry 封禁列表中的一个 IP
+type Entry struct {
+	IP          string         `json:"ip"`
+	Events      int            `json:"events"`
+	Connections int64          `json:"connections,omitempty"`
+	EventTypes  map[string]int `json:"event_types,omitempty"`
+	Labels      []string       `json:"labels,omitempty"`
+	FirstSeen   time.Time      `json:"first_seen"`
+	LastSeen    time.Time      `json:"last_seen"`
+	Reasons     []string       `json:"reasons"`
+
+	addr netip.Addr
+}
+
+// record 累计中的 IP 状态
+type record struct {
+	events      int
+	connections int64
+	types       map[string]int
+	labels      map[string]struct{}
+	first, last time.Time
+}
+
+// seen 扩展首次/最后出现时间
+func (r *record) seen(t time.Time) {
+	if r.first.IsZero() || t.Before(r.first) {
+		r.first = t
+	}
+	if t.After(r.last) {
+		r.last = t
+	}
+}
+
+// Builder 从事件和限流器状态累计各 IP 的表现
+type Builder struct {
+	criteria Criteria
+	exclude  []netip.Prefix
+	records  map[netip.Addr]*record
+}
+
+// NewBuilder 创建累计器，exclude 中的地址和网段（如 IP 白名单）不会被列入
+func NewBuilder(criteria Criteria, exclude []string) (*Builder, error) {
+	b := &Builder{criteria: criteria, records: make(map[netip.Addr]*record)}
+	for _, s := range exclude {
+		prefix, err := netutil.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的排除地址 %q: %w", s, err)
+		}
+		b.exclude = append(b.exclude, prefix)
+	}
+	return b, nil
+}
+
+// inWindow 判断时间是否在窗口内
+func (b *Builder) inWindow(t time.Time) bool {
+	if !b.criteria.Since.IsZero() && t.Before(b.criteria.Since) {
+		return false
+	}
+	if !b.criteria.Until.IsZero() && !t.Before(b.criteria.Until) {
+		return false
+	}
+	return true
+}
+
+// get 取得 IP 的累计状态，无法解析的地址返回 nil
+func (b *Builder) get(ip string) *record {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	r, ok := b.records[addr]
+	if !ok {
+		r = &record{types: make(map[string]int)}
+		b.records[addr] = r
+	}
+	return r
+}
+
+// AddEvent 累计一条蜜罐事件
+func (b *Builder) AddEvent(e *logger.HoneypotEvent) {
+	if e.EventType == "attack_session" || !b.inWindow(e.Timestamp) {
+		return
+	}
+	r := b.get(e.ClientIP)
+	if r == nil {
+		return
+	}
+	r.seen(e.Timestamp)
+	// 标签来自任意类型的事件，事件数只统计指定类型
+	for _, label := range e.Labels {
+		if r.labels == nil {
+			r.labels = make(map[string]struct{})
+		}
+		r.labels[label] = struct{}{}
+	}
+	if len(b.criteria.EventTypes) == 0 || slices.Contains(b.criteria.EventTypes, e.EventType) {
+		r.events++
+		r.types[e.EventType]++
+	}
+}
+
+// AddLimiterState 累计限流器记录的 IP 状态
+func (b *Builder) AddLimiterState(s limiter.IPState) {
+	if !b.inWindow(s.LastRequest) {
+		return
+	}
+	r := b.get(s.IP)
+	if r == nil {
+		return
+	}
+	r.connections = max(r.connections, s.RequestCount)
+	r.seen(s.FirstRequest)
+	r.seen(s.LastRequest)
+}
+
+// Build 返回满足条件的 IP，按地址排序
+func (b *Builder) Build() []Entry {
+	c := b.criteria
+	var entries []Entry
+	for addr, r := range b.records {
+		if b.excluded(addr) {
+			continue
+		}
+
+		if r.events < c.MinEvents || r.connections < c.MinConnections {
+			continue
+		}
+		var reasons []string
+		if r.events > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d 个事件 (>= %d)", r.events, c.MinEvents))
+		}
+		if c.MinConnections > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d 次连接 (>= %d)", r.connections, c.MinConnections))
+		}
+
+		var labels []string
+		for label := range r.labels {
+			labels = append(labels, label)
+		}
+		slices.Sort(labels)
+		if len(c.Labels) > 0 {
+			var matched []string
+			for _, label := range c.Labels {
+				if _, ok := r.labels[label]; ok {
+					matched = append(matched, label)
+				}
+			}
+			if len(matched) == 0 {
+				continue
+			}
+			reasons = append(reasons, "命中标签 "+strings.Join(matched, ", "))
+		}
+
+		entries = append(entries, Entry{
+			IP:          addr.String(),
+			Events:      r.events,
+			Connections: r.connections,
+			EventTypes:  r.types,
+			Labels:      labels,
+			FirstSeen:   r.first,
+			LastSeen:    r.last,
+			Reasons:     reasons,
+			addr:        addr,
+		})
+	}
+	slices.SortFunc(entries, func(a, b Entry) int { return a.addr.Compare(b.addr) })
+	return entries
+}
+
+// excluded 判断地址是否在排除列表中
+func (b *Builder) excluded(addr netip.Addr) bool {
+	for _, prefix := range b.exclude {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
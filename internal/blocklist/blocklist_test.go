@@ -0,0 +1,145 @@
+package blocklist
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/limiter"
+	"fake-mc-server/internal/logger"
+)
+
+func TestAggregate(t *testing.T) {
+	var addrs []netip.Addr
+	for _, s := range []string{
+		"192.0.2.0", "192.0.2.1", "192.0.2.2", "192.0.2.3", // 192.0.2.0/30
+		"192.0.2.5", "192.0.2.4", "192.0.2.6", // 192.0.2.4/31 + 192.0.2.6/32
+		"198.51.100.7", "198.51.100.7",
+		"2001:db8::", "2001:db8::1",
+	} {
+		addrs = append(addrs, netip.MustParseAddr(s))
+	}
+
+	var got []string
+	for _, p := range Aggregate(addrs) {
+		got = append(got, p.String())
+	}
+	want := []string{"192.0.2.0/30", "192.0.2.4/31", "192.0.2.6/32", "198.51.100.7/32", "2001:db8::/127"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Aggregate() = %v, want %v", got, want)
+	}
+}
+
+// fakeLimiter 固定的限流器状态
+type fakeLimiter []limiter.IPState
+
+func (f fakeLimiter) IPStates() []limiter.IPState { return f }
+
+func TestCollect(t *testing.T) {
+	now := time.Now()
+	events := []*logger.HoneypotEvent{
+		{Timestamp: now, ClientIP: "192.0.2.1", EventType: "login_attempt"},
+		{Timestamp: now, ClientIP: "192.0.2.1", EventType: "login_attempt"},
+		{Timestamp: now, ClientIP: "192.0.2.1", EventType: "status_query"},
+		{Timestamp: now, ClientIP: "192.0.2.9", EventType: "fingerprint", Labels: []string{"masscan"}},
+		{Timestamp: now, ClientIP: "10.0.0.1", EventType: "login_attempt"},
+		{Timestamp: now, ClientIP: "10.0.0.1", EventType: "login_attempt"},
+		{Timestamp: now.Add(-48 * time.Hour), ClientIP: "198.51.100.1", EventType: "login_attempt"},
+		{Timestamp: now.Add(-48 * time.Hour), ClientIP: "198.51.100.1", EventType: "login_attempt"},
+	}
+	collect := func(c Criteria) []string {
+		t.Helper()
+		b, err := NewBuilder(c, []string{"10.0.0.0/8"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			b.AddEvent(e)
+		}
+		b.AddLimiterState(limiter.IPState{IP: "203.0.113.5", RequestCount: 500, FirstRequest: now, LastRequest: now})
+		var ips []string
+		for _, e := range b.Build() {
+			ips = append(ips, e.IP)
+		}
+		return ips
+	}
+
+	since := now.Add(-time.Hour)
+	tests := []struct {
+		name     string
+		criteria Criteria
+		want     []string
+	}{
+		{"事件数与窗口", Criteria{MinEvents: 2, Since: since}, []string{"192.0.2.1"}},
+		{"事件类型", Criteria{MinEvents: 3, EventTypes: []string{"login_attempt"}, Since: since}, nil},
+		{"不限窗口", Criteria{MinEvents: 2, EventTypes: []string{"login_attempt"}}, []string{"192.0.2.1", "198.51.100.1"}},
+		{"标签", Criteria{Labels: []string{"masscan"}, Since: since}, []string{"192.0.2.9"}},
+		{"连接数", Criteria{MinConnections: 100, Since: since}, []string{"203.0.113.5"}},
+	}
+	for _, tt := range tests {
+		if got := collect(tt.criteria); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Build() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	entries := []Entry{{IP: "192.0.2.1"}, {IP: "192.0.2.0"}, {IP: "2001:db8::1"}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "ipset", entries, "fakemc"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"create fakemc hash:net family inet -exist", "add fakemc 192.0.2.0/31 -exist", "add fakemc6 2001:db8::1/128 -exist"} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("ipset 输出缺少 %q:\n%s", line, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := Write(&buf, "nft", entries[:2], "fakemc"); err != nil {
+		t.Fatal(err)
+	}
+	want := "set fakemc_v4 {\n\ttype ipv4_addr\n\tflags interval\n\telements = {\n\t\t192.0.2.0/31\n\t}\n}\n" +
+		"set fakemc_v6 {\n\ttype ipv6_addr\n\tflags interval\n}\n"
+	if buf.String() != want {
+		t.Errorf("nft 输出 =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	if err := Write(&buf, "xml", entries, "fakemc"); err == nil {
+		t.Error("未知格式应返回错误")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	source := &Source{Limiter: fakeLimiter{
+		{IP: "203.0.113.5", RequestCount: 500, FirstRequest: time.Now(), LastRequest: time.Now()},
+	}}
+	cfg := &config.BlocklistConfig{MinEvents: 0, Window: time.Hour, SetName: "fakemc"}
+	handler := NewHandler(source, cfg, zerolog.Nop())
+
+	req := httptest.NewRequest(http.MethodGet, "/blocklist?min_connections=100&format=json", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ip": "203.0.113.5"`) {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/blocklist?min_events=x", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("无效参数: status = %d", rec.Code)
+	}
+}
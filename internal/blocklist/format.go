@@ -0,0 +1,160 @@
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// Formats 支持的输出格式
+var Formats = []string{"plain", "cidr", "ipset", "nft", "json"}
+
+// ContentType 格式对应的 HTTP Content-Type
+func ContentType(format string) string {
+	if format == "json" {
+		return "application/json; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Write 按格式输出封禁列表，setName 用于 ipset 和 nftables 集合
+func Write(w io.Writer, format string, entries []Entry, setName string) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "plain":
+		for _, e := range entries {
+			fmt.Fprintln(bw, e.IP)
+		}
+	case "cidr":
+		for _, p := range Aggregate(addrs(entries)) {
+			fmt.Fprintln(bw, p)
+		}
+	case "ipset":
+		writeIPSet(bw, Aggregate(addrs(entries)), setName)
+	case "nft":
+		writeNFT(bw, Aggregate(addrs(entries)), setName)
+	case "json":
+		if entries == nil {
+			entries = []Entry{}
+		}
+		data, err := sonic.ConfigStd.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+		bw.WriteByte('\n')
+	default:
+		return fmt.Errorf("未知的封禁列表格式: %s，可选: %s", format, strings.Join(Formats, ", "))
+	}
+	return bw.Flush()
+}
+
+// addrs 取出条目中的地址
+func addrs(entries []Entry) []netip.Addr {
+	result := make([]netip.Addr, 0, len(entries))
+	for _, e := range entries {
+		addr := e.addr
+		if !addr.IsValid() {
+			var err error
+			if addr, err = netip.ParseAddr(e.IP); err != nil {
+				continue
+			}
+		}
+		result = append(result, addr.Unmap())
+	}
+	return result
+}
+
+// writeIPSet 输出 ipset restore 格式，IPv4 与 IPv6 分别放入 setName 和 setName6
+func writeIPSet(w io.Writer, prefixes []netip.Prefix, setName string) {
+	fmt.Fprintf(w, "create %s hash:net family inet -exist\n", setName)
+	fmt.Fprintf(w, "create %s6 hash:net family inet6 -exist\n", setName)
+	for _, p := range prefixes {
+		name := setName
+		if p.Addr().Is6() {
+			name += "6"
+		}
+		fmt.Fprintf(w, "add %s %s -exist\n", name, p)
+	}
+}
+
+// writeNFT 输出 nftables 集合定义，可在 table 中 include，IPv4 与 IPv6 分别为 setName_v4 和 setName_v6
+func writeNFT(w io.Writer, prefixes []netip.Prefix, setName string) {
+	var v4, v6 []string
+	for _, p := range prefixes {
+		if p.Addr().Is4() {
+			v4 = append(v4, p.String())
+		} else {
+			v6 = append(v6, p.String())
+		}
+	}
+	for _, set := range []struct {
+		suffix, typ string
+		elements    []string
+	}{
+		{"_v4", "ipv4_addr", v4},
+		{"_v6", "ipv6_addr", v6},
+	} {
+		fmt.Fprintf(w, "set %s%s {\n\ttype %s\n\tflags interval\n", setName, set.suffix, set.typ)
+		// nftables 不接受空的 elements
+		if len(set.elements) > 0 {
+			fmt.Fprintf(w, "\telements = {\n\t\t%s\n\t}\n", strings.Join(set.elements, ",\n\t\t"))
+		}
+		fmt.Fprintln(w, "}")
+	}
+}
+
+// Aggregate 将地址合并为覆盖完全相同地址集合的最少网段
+func Aggregate(addrs []netip.Addr) []netip.Prefix {
+	sorted := slices.Clone(addrs)
+	slices.SortFunc(sorted, netip.Addr.Compare)
+	sorted = slices.Compact(sorted)
+
+	var result []netip.Prefix
+	for i := 0; i < len(sorted); {
+		// 找出连续地址区间 [lo, hi]
+		lo, hi := sorted[i], sorted[i]
+		i++
+		for i < len(sorted) && sorted[i].BitLen() == hi.BitLen() && hi.Next() == sorted[i] {
+			hi = sorted[i]
+			i++
+		}
+		result = appendRange(result, lo, hi)
+	}
+	return result
+}
+
+// appendRange 将区间 [lo, hi] 拆分为对齐的网段
+func appendRange(result []netip.Prefix, lo, hi netip.Addr) []netip.Prefix {
+	for {
+		// 从最大的网段开始尝试：起点需对齐，且不能超出区间
+		var p netip.Prefix
+		for bits := 0; bits <= lo.BitLen(); bits++ {
+			p = netip.PrefixFrom(lo, bits)
+			if p.Masked().Addr() == lo && lastAddr(p).Compare(hi) <= 0 {
+				break
+			}
+		}
+		result = append(result, p)
+		last := lastAddr(p)
+		if last == hi {
+			return result
+		}
+		lo = last.Next()
+	}
+}
+
+// lastAddr 网段中的最后一个地址
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
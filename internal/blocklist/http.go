@@ -0,0 +1,113 @@
+package blocklist
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/report"
+)
+
+// Handler 封禁列表 HTTP 导出，令牌校验由 monitor.RequireToken 完成
+//
+// 查询参数: format (plain, cidr, ipset, nft, json)、min_events、min_connections、
+// type 和 label（可重复或逗号分隔）、since 和 until（时长、RFC3339 或日期）
+type Handler struct {
+	source *Source
+	config *config.BlocklistConfig
+	logger zerolog.Logger
+}
+
+// NewHandler 创建 HTTP 导出处理器
+func NewHandler(source *Source, cfg *config.BlocklistConfig, logger zerolog.Logger) *Handler {
+	return &Handler{
+		source: source,
+		config: cfg,
+		logger: logger.With().Str("component", "blocklist").Logger(),
+	}
+}
+
+// ServeHTTP 按查询参数生成封禁列表
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "plain"
+	}
+	criteria, err := h.criteria(query, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.source.Collect(r.Context(), criteria)
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("生成封禁列表失败")
+		http.Error(w, "生成封禁列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, format, entries, h.config.SetName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType(format))
+	w.Header().Set("X-Blocklist-Entries", strconv.Itoa(len(entries)))
+	w.Write(buf.Bytes())
+}
+
+// criteria 解析查询参数，未指定的条件使用配置中的默认值
+func (h *Handler) criteria(query url.Values, now time.Time) (Criteria, error) {
+	c := Criteria{
+		MinEvents:  h.config.MinEvents,
+		EventTypes: splitList(query["type"]),
+		Labels:     splitList(query["label"]),
+		Since:      now.Add(-h.config.Window),
+	}
+	var err error
+	if v := query.Get("min_events"); v != "" {
+		if c.MinEvents, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("min_events: %w", err)
+		}
+	}
+	if v := query.Get("min_connections"); v != "" {
+		if c.MinConnections, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return c, fmt.Errorf("min_connections: %w", err)
+		}
+	}
+	if v := query.Get("since"); v != "" {
+		if c.Since, err = report.ParseTime(v, now); err != nil {
+			return c, fmt.Errorf("since: %w", err)
+		}
+	}
+	if c.Until, err = report.ParseTime(query.Get("until"), now); err != nil {
+		return c, fmt.Errorf("until: %w", err)
+	}
+	return c, nil
+}
+
+// splitList 展开重复参数和逗号分隔的值
+func splitList(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
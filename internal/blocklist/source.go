@@ -0,0 +1,91 @@
+package blocklist
+
+import (
+	"context"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/limiter"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/report"
+	"fake-mc-server/internal/store"
+)
+
+// LimiterState 提供限流器 IP 状态快照，*limiter.RateLimiter 满足该接口
+type LimiterState interface {
+	IPStates() []limiter.IPState
+}
+
+// Source 封禁列表的数据来源，为零值的字段跳过
+type Source struct {
+	Store   *store.Store // SQLite 事件库，优先于 LogPath
+	LogPath string       // 未配置事件库时读取的蜜罐日志，包含轮转备份
+	Files   []string     // 直接读取的日志文件，设置后忽略 LogPath
+	Limiter LimiterState // 运行中的限流器
+	Exclude []string     // 永不列入的地址和网段
+}
+
+// NewSource 按配置创建数据来源：配置了 sqlite 投递目标时查询事件库，否则读取蜜罐日志文件
+// IP 白名单中的地址不会被列入；states 为 nil 时不使用限流器状态
+func NewSource(cfg *config.Config, states LimiterState) (*Source, error) {
+	source := &Source{Limiter: states, Exclude: cfg.Security.IPWhitelist}
+	if path := store.ConfiguredPath(&cfg.HoneypotLogging); path != "" {
+		db, err := store.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		source.Store = db
+	} else if cfg.HoneypotLogging.Enabled {
+		source.LogPath = cfg.HoneypotLogging.FilePath
+	}
+	return source, nil
+}
+
+// Collect 按条件生成封禁列表
+func (s *Source) Collect(ctx context.Context, c Criteria) ([]Entry, error) {
+	b, err := NewBuilder(c, s.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	add := func(e *logger.HoneypotEvent) error {
+		b.AddEvent(e)
+		return ctx.Err()
+	}
+
+	switch {
+	case s.Store != nil:
+		filter := store.Filter{Since: c.Since, Until: c.Until}
+		// 标签可能出现在任意类型的事件上，按标签筛选时不能只查指定类型
+		if len(c.Labels) == 0 {
+			filter.Types = c.EventTypes
+		}
+		if err := s.Store.Each(ctx, filter, add); err != nil {
+			return nil, err
+		}
+	case len(s.Files) > 0 || s.LogPath != "":
+		files := s.Files
+		if len(files) == 0 {
+			// 尚未产生日志时视为没有事件
+			files, _ = report.LogFiles(s.LogPath)
+		}
+		for _, file := range files {
+			if err := report.ReadFile(file, add); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s.Limiter != nil {
+		for _, state := range s.Limiter.IPStates() {
+			b.AddLimiterState(state)
+		}
+	}
+	return b.Build(), nil
+}
+
+// Close 关闭事件库
+func (s *Source) Close() error {
+	if s.Store == nil {
+		return nil
+	}
+	return s.Store.Close()
+}
@@ -0,0 +1,433 @@
+// Package capture 按连接抓取原始数据包
+//
+// 每个被采样的连接在结束时写入一条记录，记录中包含两个方向各自的前 N 个字节及其时间戳。
+// 记录是自描述的（以魔数开头），轮转后的每个文件都可以单独解析。所有整数均为大端序：
+//
+//	magic     [4]byte  "FMCP"
+//	version   uint8    当前为 1
+//	length    uint32   之后剩余的字节数
+//	start     int64    连接建立时间（Unix 纳秒）
+//	conn_id   uint16 长度 + 字节
+//	client_ip uint16 长度 + 字节
+//	reason    uint16 长度 + 字节（采样原因：all / violation / cidr / percentage）
+//	flags     uint8    bit0: 客户端方向被截断，bit1: 服务端方向被截断
+//	count     uint32   数据段数量
+//	segments  count 个数据段：
+//	  direction uint8  0 = 客户端 -> 服务器，1 = 服务器 -> 客户端
+//	  offset    int64  相对连接建立时间的纳秒数
+//	  length    uint32 数据长度
+//	  data      []byte
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/netutil"
+)
+
+// Direction 数据方向
+type Direction uint8
+
+const (
+	Inbound  Direction = 0 // 客户端 -> 服务器
+	Outbound Direction = 1 // 服务器 -> 客户端
+)
+
+// 采样模式
+const (
+	ModeAll        = "all"        // 抓取所有连接
+	ModeViolations = "violations" // 只保存出现协议违规的连接
+	ModeCIDRs      = "cidrs"      // 只抓取指定网段
+	ModePercentage = "percentage" // 按比例随机抓取
+)
+
+// 记录标志位
+const (
+	FlagInboundTruncated  = 1 << 0
+	FlagOutboundTruncated = 1 << 1
+)
+
+const (
+	recordVersion = 1
+
+	// MaxBytesLimit max_bytes 的上限
+	MaxBytesLimit = 1 << 20
+	// maxRecordSize 记录主体的最大长度，读取时超过此长度视为损坏，避免按错误的长度分配内存
+	// 每个方向最多 MaxBytesLimit 个数据段，每段 13 字节头部，再加上三个字符串
+	maxRecordSize = 32 << 20
+)
+
+var recordMagic = [4]byte{'F', 'M', 'C', 'P'}
+
+// ErrBadRecord 记录格式错误
+var ErrBadRecord = errors.New("无效的抓包记录")
+
+// Segment 一次读取或写入的数据
+type Segment struct {
+	Direction Direction
+	Offset    time.Duration // 相对连接建立的时间
+	Data      []byte
+}
+
+// Record 单个连接的抓包记录
+type Record struct {
+	Start    time.Time
+	ConnID   string
+	ClientIP string
+	Reason   string
+	Flags    uint8
+	Segments []Segment
+}
+
+// Capturer 抓包写入器，为 nil 时所有方法都是空操作
+type Capturer struct {
+	config   *config.CaptureConfig
+	writer   io.Writer
+	prefixes []netip.Prefix
+	mutex    sync.Mutex
+}
+
+// NewCapturer 创建抓包写入器，未启用时返回 nil
+func NewCapturer(cfg *config.CaptureConfig) (*Capturer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	if cfg.MaxBytes <= 0 || cfg.MaxBytes > MaxBytesLimit {
+		return nil, fmt.Errorf("抓包 max_bytes 必须在 1 到 %d 之间: %d", MaxBytesLimit, cfg.MaxBytes)
+	}
+
+	c := &Capturer{config: cfg}
+
+	switch cfg.Mode {
+	case ModeAll, ModeViolations, ModePercentage:
+	case ModeCIDRs:
+		for _, s := range cfg.CIDRs {
+			prefix, err := netutil.ParsePrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("抓包网段无效 %q: %w", s, err)
+			}
+			c.prefixes = append(c.prefixes, prefix)
+		}
+	default:
+		return nil, fmt.Errorf("未知的抓包采样模式: %s", cfg.Mode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0755); err != nil {
+		return nil, fmt.Errorf("创建抓包目录失败: %w", err)
+	}
+	c.writer = &lumberjack.Logger{
+		Filename:   cfg.FilePath,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
+	}
+	return c, nil
+}
+
+// Start 为新连接创建记录器，不需要抓取时返回 nil
+func (c *Capturer) Start(connID, clientIP string, start time.Time) *Recorder {
+	if c == nil {
+		return nil
+	}
+
+	reason := c.config.Mode
+	switch c.config.Mode {
+	case ModeCIDRs:
+		addr, err := netip.ParseAddr(clientIP)
+		if err != nil || !c.containsAddr(addr.Unmap()) {
+			return nil
+		}
+	case ModePercentage:
+		if rand.Float64()*100 >= c.config.Percentage {
+			return nil
+		}
+	case ModeViolations:
+		reason = "violation"
+	}
+
+	return &Recorder{
+		capturer: c,
+		record: Record{
+			Start:    start,
+			ConnID:   connID,
+			ClientIP: clientIP,
+			Reason:   reason,
+		},
+	}
+}
+
+// containsAddr 检查地址是否位于配置的网段中
+func (c *Capturer) containsAddr(addr netip.Addr) bool {
+	for _, prefix := range c.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// write 写入一条完整的记录
+func (c *Capturer) write(record *Record) error {
+	data := record.MarshalBinary()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, err := c.writer.Write(data)
+	return err
+}
+
+// Close 关闭抓包文件
+func (c *Capturer) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if closer, ok := c.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Recorder 单个连接的抓包记录器，为 nil 时所有方法都是空操作
+// 陷阱世界中读写位于不同协程，因此需要加锁
+type Recorder struct {
+	capturer *Capturer
+	mutex    sync.Mutex
+	record   Record
+	inbound  int
+	outbound int
+}
+
+// Record 记录一段数据，超过每个方向的上限后只设置截断标志
+func (r *Recorder) Record(dir Direction, data []byte) {
+	if r == nil || len(data) == 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	limit := r.capturer.config.MaxBytes
+	used, flag := &r.inbound, uint8(FlagInboundTruncated)
+	if dir == Outbound {
+		used, flag = &r.outbound, FlagOutboundTruncated
+	}
+
+	n := min(len(data), limit-*used)
+	if n < len(data) {
+		r.record.Flags |= flag
+	}
+	if n <= 0 {
+		return
+	}
+	*used += n
+	r.record.Segments = append(r.record.Segments, Segment{
+		Direction: dir,
+		Offset:    time.Since(r.record.Start),
+		Data:      bytes.Clone(data[:n]),
+	})
+}
+
+// Reader 包装读取器，记录客户端发送的数据
+func (r *Recorder) Reader(rd io.Reader) io.Reader {
+	if r == nil {
+		return rd
+	}
+	return &recordingReader{r: rd, rec: r}
+}
+
+// Writer 包装写入器，记录发送给客户端的数据
+func (r *Recorder) Writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return &recordingWriter{w: w, rec: r}
+}
+
+// Finish 连接结束时写入记录，violations 模式下只有出现违规才写入
+func (r *Recorder) Finish(violation bool) error {
+	if r == nil {
+		return nil
+	}
+	if r.capturer.config.Mode == ModeViolations && !violation {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.capturer.write(&r.record)
+}
+
+// recordingReader 记录读取字节的读取器
+type recordingReader struct {
+	r   io.Reader
+	rec *Recorder
+}
+
+// Read 实现 io.Reader
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if n > 0 {
+		rr.rec.Record(Inbound, p[:n])
+	}
+	return n, err
+}
+
+// recordingWriter 记录写入字节的写入器
+type recordingWriter struct {
+	w   io.Writer
+	rec *Recorder
+}
+
+// Write 实现 io.Writer
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	n, err := rw.w.Write(p)
+	if n > 0 {
+		rw.rec.Record(Outbound, p[:n])
+	}
+	return n, err
+}
+
+// MarshalBinary 按包注释中的格式编码记录
+func (rec *Record) MarshalBinary() []byte {
+	var body bytes.Buffer
+	binary.Write(&body, binary.BigEndian, rec.Start.UnixNano())
+	writeString(&body, rec.ConnID)
+	writeString(&body, rec.ClientIP)
+	writeString(&body, rec.Reason)
+	body.WriteByte(rec.Flags)
+	binary.Write(&body, binary.BigEndian, uint32(len(rec.Segments)))
+	for _, seg := range rec.Segments {
+		body.WriteByte(byte(seg.Direction))
+		binary.Write(&body, binary.BigEndian, int64(seg.Offset))
+		binary.Write(&body, binary.BigEndian, uint32(len(seg.Data)))
+		body.Write(seg.Data)
+	}
+
+	out := make([]byte, 0, 9+body.Len())
+	out = append(out, recordMagic[:]...)
+	out = append(out, recordVersion)
+	out = binary.BigEndian.AppendUint32(out, uint32(body.Len()))
+	return append(out, body.Bytes()...)
+}
+
+// ReadRecord 从抓包文件中读取下一条记录，文件结束时返回 io.EOF
+func ReadRecord(r io.Reader) (*Record, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrBadRecord
+		}
+		return nil, err
+	}
+	if [4]byte(header[:4]) != recordMagic || header[4] != recordVersion {
+		return nil, ErrBadRecord
+	}
+
+	length := binary.BigEndian.Uint32(header[5:])
+	if length > maxRecordSize {
+		return nil, fmt.Errorf("%w: 记录长度 %d 超过上限", ErrBadRecord, length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, ErrBadRecord
+	}
+	rec, err := unmarshalBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadRecord, err)
+	}
+	return rec, nil
+}
+
+// unmarshalBody 解析记录主体
+func unmarshalBody(body []byte) (*Record, error) {
+	br := bytes.NewReader(body)
+	rec := &Record{}
+
+	var start int64
+	if err := binary.Read(br, binary.BigEndian, &start); err != nil {
+		return nil, err
+	}
+	rec.Start = time.Unix(0, start)
+
+	for _, s := range []*string{&rec.ConnID, &rec.ClientIP, &rec.Reason} {
+		v, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		*s = v
+	}
+
+	var count uint32
+	if err := binary.Read(br, binary.BigEndian, &rec.Flags); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(br, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	for range count {
+		var (
+			dir    uint8
+			offset int64
+			length uint32
+		)
+		if err := binary.Read(br, binary.BigEndian, &dir); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(br, binary.BigEndian, &offset); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(br, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if int64(length) > int64(br.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		data := make([]byte, length)
+		br.Read(data)
+		rec.Segments = append(rec.Segments, Segment{
+			Direction: Direction(dir),
+			Offset:    time.Duration(offset),
+			Data:      data,
+		})
+	}
+	return rec, nil
+}
+
+// writeString 写入带 uint16 长度前缀的字符串
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// readString 读取带 uint16 长度前缀的字符串
+func readString(br *bytes.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(br, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	if int(length) > br.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+	data := make([]byte, length)
+	br.Read(data)
+	return string(data), nil
+}
@@ -0,0 +1,135 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fake-mc-server/internal/config"
+)
+
+func newTestCapturer(t *testing.T, mode string) (*Capturer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "capture.fmcp")
+	c, err := NewCapturer(&config.CaptureConfig{
+		Enabled:  true,
+		FilePath: path,
+		MaxBytes: 8,
+		Mode:     mode,
+		CIDRs:    []string{"10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatalf("NewCapturer() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, path
+}
+
+func readAll(t *testing.T, path string) []*Record {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("读取抓包文件失败: %v", err)
+	}
+	var records []*Record
+	r := bytes.NewReader(data)
+	for {
+		rec, err := ReadRecord(r)
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("ReadRecord() error = %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	c, path := newTestCapturer(t, ModeAll)
+
+	start := time.Now()
+	rec := c.Start("conn-1", "192.0.2.1", start)
+	rec.Reader(bytes.NewReader([]byte{0x10, 0x00, 0xFD, 0x05})).Read(make([]byte, 4))
+	rec.Writer(io.Discard).Write([]byte("0123456789"))
+	rec.Record(Inbound, []byte("abcdef"))
+	if err := rec.Finish(false); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	records := readAll(t, path)
+	if len(records) != 1 {
+		t.Fatalf("读取到 %d 条记录, want 1", len(records))
+	}
+	got := records[0]
+	if got.ConnID != "conn-1" || got.ClientIP != "192.0.2.1" || got.Reason != ModeAll || !got.Start.Equal(time.Unix(0, start.UnixNano())) {
+		t.Errorf("记录头 = %+v", got)
+	}
+	if got.Flags != FlagInboundTruncated|FlagOutboundTruncated {
+		t.Errorf("Flags = %b, want 两个方向都被截断", got.Flags)
+	}
+
+	want := []struct {
+		dir  Direction
+		data string
+	}{
+		{Inbound, "\x10\x00\xFD\x05"},
+		{Outbound, "01234567"},
+		{Inbound, "abcd"},
+	}
+	if len(got.Segments) != len(want) {
+		t.Fatalf("数据段数量 = %d, want %d", len(got.Segments), len(want))
+	}
+	for i, w := range want {
+		seg := got.Segments[i]
+		if seg.Direction != w.dir || string(seg.Data) != w.data {
+			t.Errorf("数据段 %d = (%d, %q), want (%d, %q)", i, seg.Direction, seg.Data, w.dir, w.data)
+		}
+	}
+}
+
+// TestReadRecordRejectsOversized 损坏的长度字段不会导致按该长度分配内存
+func TestReadRecordRejectsOversized(t *testing.T) {
+	data := (&Record{Start: time.Now(), ConnID: "conn-1"}).MarshalBinary()
+	binary.BigEndian.PutUint32(data[5:], 0xFFFFFFFF)
+	if _, err := ReadRecord(bytes.NewReader(data)); !errors.Is(err, ErrBadRecord) {
+		t.Errorf("ReadRecord() error = %v, want ErrBadRecord", err)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	t.Run("violations", func(t *testing.T) {
+		c, path := newTestCapturer(t, ModeViolations)
+		c.Start("clean", "192.0.2.1", time.Now()).Finish(false)
+		c.Start("bad", "192.0.2.1", time.Now()).Finish(true)
+
+		records := readAll(t, path)
+		if len(records) != 1 || records[0].ConnID != "bad" || records[0].Reason != "violation" {
+			t.Errorf("records = %+v, want 只有 bad", records)
+		}
+	})
+
+	t.Run("cidrs", func(t *testing.T) {
+		c, _ := newTestCapturer(t, ModeCIDRs)
+		if c.Start("a", "10.1.2.3", time.Now()) == nil {
+			t.Error("网段内的地址应被抓取")
+		}
+		if c.Start("b", "192.0.2.1", time.Now()) != nil {
+			t.Error("网段外的地址不应被抓取")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var c *Capturer
+		rec := c.Start("a", "10.1.2.3", time.Now())
+		rec.Record(Inbound, []byte{1})
+		if err := rec.Finish(true); err != nil {
+			t.Errorf("nil Recorder Finish() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"fake-mc-server/internal/blocklist"
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/report"
+	"fake-mc-server/internal/store"
+)
+
+// runBlocklist 从事件库或蜜罐日志导出封禁列表
+func runBlocklist(args []string) error {
+	fs := flag.NewFlagSet("blocklist", flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yml", "配置文件路径，提供默认条件、IP 白名单和事件来源")
+	dbPath := fs.String("db", "", "事件库路径，默认使用配置中的 sqlite 投递目标，未配置时读取蜜罐日志")
+	format := fs.String("format", "plain", "输出格式: "+strings.Join(blocklist.Formats, ", "))
+	minEvents := fs.Int("min-events", -1, "最少事件数，默认使用配置中的 blocklist.min_events")
+	types := fs.String("type", "", "只统计这些事件类型，多个用逗号分隔")
+	labels := fs.String("label", "", "命中任一扫描器特征标签，多个用逗号分隔")
+	since := fs.String("since", "", "起始时间：时长、RFC3339 或 2006-01-02，默认使用配置中的 blocklist.window")
+	until := fs.String("until", "", "结束时间，格式同 since")
+	setName := fs.String("set-name", "", "ipset/nftables 集合名，默认使用配置中的 blocklist.set_name")
+	output := fs.String("o", "", "输出文件，默认输出到标准输出")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: blocklist [选项] [日志文件...]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+
+	now := time.Now()
+	criteria := blocklist.Criteria{
+		MinEvents:  cfg.Blocklist.MinEvents,
+		EventTypes: splitComma(*types),
+		Labels:     splitComma(*labels),
+		Since:      now.Add(-cfg.Blocklist.Window),
+	}
+	if *minEvents >= 0 {
+		criteria.MinEvents = *minEvents
+	}
+	if *since != "" {
+		if criteria.Since, err = report.ParseTime(*since, now); err != nil {
+			return fmt.Errorf("since: %w", err)
+		}
+	}
+	if criteria.Until, err = report.ParseTime(*until, now); err != nil {
+		return fmt.Errorf("until: %w", err)
+	}
+	if *setName == "" {
+		*setName = cfg.Blocklist.SetName
+	}
+
+	var source *blocklist.Source
+	switch {
+	case fs.NArg() > 0:
+		source = &blocklist.Source{Files: fs.Args(), Exclude: cfg.Security.IPWhitelist}
+	case *dbPath != "":
+		db, err := store.Open(*dbPath)
+		if err != nil {
+			return err
+		}
+		source = &blocklist.Source{Store: db, Exclude: cfg.Security.IPWhitelist}
+	default:
+		if source, err = blocklist.NewSource(cfg, nil); err != nil {
+			return err
+		}
+	}
+	defer source.Close()
+
+	entries, err := source.Collect(context.Background(), criteria)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := blocklist.Write(out, *format, entries, *setName); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "共 %d 个 IP\n", len(entries))
+	return nil
+}
+
+// splitComma 拆分逗号分隔的参数
+func splitComma(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+// Package cli 实现服务器二进制附带的离线子命令
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// commands 所有子命令
+var commands = []command{
+	{"replay", "将抓包文件中的连接回放给协议处理器", runReplay},
+	{"query", "按条件查询 SQLite 事件库", runQuery},
+	{"report", "汇总蜜罐日志生成攻击报告 (text/json/html)", runReport},
+	{"blocklist", "根据观察到的攻击 IP 导出封禁列表", runBlocklist},
+}
+
+// IsCommand 判断参数是否为子命令
+func IsCommand(name string) bool {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Run 执行子命令并返回进程退出码
+func Run(name string, args []string) int {
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n%s", name, Usage())
+	return 2
+}
+
+// Usage 子命令列表
+func Usage() string {
+	var b strings.Builder
+	b.WriteString("子命令:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	return b.String()
+}
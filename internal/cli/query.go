@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/report"
+	"fake-mc-server/internal/store"
+)
+
+// runQuery 按条件查询 SQLite 事件库
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yml", "配置文件路径，未指定 -db 时使用其中第一个 sqlite 投递目标")
+	dbPath := fs.String("db", "", "事件库路径")
+	ip := fs.String("ip", "", "客户端IP")
+	since := fs.String("since", "", "起始时间：时长（如 24h 表示最近一天）、RFC3339 或 2006-01-02")
+	until := fs.String("until", "", "结束时间，格式同 since")
+	types := fs.String("type", "", "事件类型，多个用逗号分隔")
+	usernameLike := fs.String("username-like", "", "用户名匹配（SQL LIKE，不含通配符时按包含匹配）")
+	hostnameLike := fs.String("hostname-like", "", "握手中的服务器地址匹配，规则同 username-like")
+	limit := fs.Int("limit", 100, "最多返回的事件数，0 不限制")
+	format := fs.String("format", "table", "输出格式: table, json, csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var filter store.Filter
+	filter.IP = *ip
+	filter.UsernameLike = *usernameLike
+	filter.HostLike = *hostnameLike
+	filter.Limit = *limit
+	filter.Types = splitComma(*types)
+	now := time.Now()
+	var err error
+	if filter.Since, err = report.ParseTime(*since, now); err != nil {
+		return fmt.Errorf("since: %w", err)
+	}
+	if filter.Until, err = report.ParseTime(*until, now); err != nil {
+		return fmt.Errorf("until: %w", err)
+	}
+
+	var write func(io.Writer, []*logger.HoneypotEvent) error
+	switch *format {
+	case "table":
+		write = writeEventTable
+	case "json":
+		write = writeEventJSON
+	case "csv":
+		write = writeEventCSV
+	default:
+		return fmt.Errorf("未知的输出格式: %s", *format)
+	}
+
+	path := *dbPath
+	if path == "" {
+		if path, err = sqliteSinkPath(*configPath); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("事件库不存在: %w", err)
+	}
+
+	db, err := store.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	events, err := db.Query(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	return write(os.Stdout, events)
+}
+
+// sqliteSinkPath 从配置中找到第一个 sqlite 投递目标的数据库路径
+func sqliteSinkPath(configPath string) (string, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return "", fmt.Errorf("加载配置失败: %w", err)
+	}
+	if path := store.ConfiguredPath(&cfg.HoneypotLogging); path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("配置中没有 sqlite 投递目标，请使用 -db 指定事件库")
+}
+
+// writeEventTable 以对齐的表格输出常用字段
+func writeEventTable(w io.Writer, events []*logger.HoneypotEvent) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tIP\tTYPE\tUSERNAME\tHOSTNAME\tCOUNTRY\tDETAIL")
+	for _, e := range events {
+		detail := e.ErrorMessage
+		if detail == "" {
+			detail = e.Message
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Timestamp.Local().Format(time.DateTime), e.ClientIP, e.EventType,
+			e.Username, e.ServerAddress, e.Country, detail)
+	}
+	return tw.Flush()
+}
+
+// writeEventJSON 每行输出一个事件 (JSON Lines)
+func writeEventJSON(w io.Writer, events []*logger.HoneypotEvent) error {
+	for _, e := range events {
+		data, err := sonic.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEventCSV 以与 CSV 日志相同的列输出
+func writeEventCSV(w io.Writer, events []*logger.HoneypotEvent) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(logger.EventCSVHeader()); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := cw.Write(logger.EventCSVRecord(e)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/capture"
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/network"
+	"fake-mc-server/internal/protocol"
+	"fake-mc-server/internal/signature"
+)
+
+// connectionHandler 回放使用的协议处理器
+type connectionHandler interface {
+	HandleConnection(ctx context.Context, conn *network.Connection) error
+}
+
+// replayOptions 回放参数
+type replayOptions struct {
+	speed float64       // 时间倍率，1 为原始节奏，0 为不等待
+	quiet time.Duration // 客户端数据发送完毕后，等待服务器静默多久再断开
+}
+
+// replayLimiter 回放时不限流也不延迟，保证结果只取决于抓包内容
+type replayLimiter struct{}
+
+func (replayLimiter) Allow(string) bool                   { return true }
+func (replayLimiter) CalculateDelay(string) time.Duration { return 0 }
+func (replayLimiter) GetIPFrequency(string) float64       { return 0 }
+
+// lockedWriter 让响应转储与蜜罐事件交替写入时不互相穿插
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// runReplay 读取抓包文件，将每条连接的客户端数据重新喂给协议处理器
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yml", "配置文件路径")
+	file := fs.String("file", "logs/capture.fmcp", "抓包文件路径")
+	handlerName := fs.String("handler", "gomc", "协议处理器: gomc 或 fast")
+	speed := fs.Float64("speed", 0, "时间倍率：1 按原始节奏发送，2 为两倍速，0 不等待")
+	connID := fs.String("conn", "", "只回放指定连接ID")
+	clientIP := fs.String("ip", "", "只回放指定客户端IP")
+	quiet := fs.Duration("wait", 500*time.Millisecond, "客户端数据发送完毕后等待服务器响应的静默时间")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *speed < 0 {
+		return fmt.Errorf("speed 不能为负数")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+
+	out := &lockedWriter{w: os.Stdout}
+	log := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.TimeOnly}).With().Timestamp().Logger()
+	honeypotLogger := logger.NewHoneypotWriterLogger(out)
+	defer honeypotLogger.Close()
+
+	var signatures *signature.Database
+	if cfg.Signature.Enabled {
+		signatures, err = signature.NewDatabase(cfg.Signature.RulesPath, log)
+		if err != nil {
+			return fmt.Errorf("加载扫描器特征库失败: %w", err)
+		}
+	}
+
+	var handler connectionHandler
+	switch *handlerName {
+	case "gomc":
+		handler = protocol.NewGoMCHandler(cfg, log, nil, honeypotLogger, replayLimiter{}, signatures, nil, nil, nil)
+	case "fast":
+		handler = protocol.NewFastHandler(cfg, log, nil, replayLimiter{}, honeypotLogger, signatures, nil, nil, nil)
+	default:
+		return fmt.Errorf("未知的协议处理器: %s", *handlerName)
+	}
+
+	opts := replayOptions{speed: *speed, quiet: *quiet}
+	replayed := 0
+	err = readCaptures(*file, func(rec *capture.Record) error {
+		if (*connID != "" && rec.ConnID != *connID) || (*clientIP != "" && rec.ClientIP != *clientIP) {
+			return nil
+		}
+
+		replayed++
+		fmt.Fprintf(out, "=== 连接 %s (%s) 开始于 %s, 原因: %s, %d 个数据段\n",
+			rec.ConnID, rec.ClientIP, rec.Start.Format(time.RFC3339), rec.Reason, len(rec.Segments))
+
+		got, err := replay(context.Background(), handler, rec, opts, out, log)
+		honeypotLogger.Flush()
+		if err != nil {
+			fmt.Fprintf(out, "处理器返回错误: %v\n", err)
+		}
+		want := outboundBytes(rec)
+		if rec.Flags&capture.FlagOutboundTruncated != 0 && len(got) > len(want) {
+			got = got[:len(want)]
+		}
+		if bytes.Equal(got, want) {
+			fmt.Fprintf(out, "=== 响应与抓包一致 (%d 字节)\n\n", len(want))
+		} else {
+			fmt.Fprintf(out, "=== 响应与抓包不一致: 回放 %d 字节, 抓包 %d 字节\n\n", len(got), len(want))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "共回放 %d 条连接\n", replayed)
+	return nil
+}
+
+// readCaptures 依次读取抓包文件中的连接记录，.gz 文件自动解压
+func readCaptures(path string, fn func(*capture.Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开抓包文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("解压抓包文件失败: %w", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	r := bufio.NewReader(src)
+	for {
+		rec, err := capture.ReadRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取抓包记录失败: %w", err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// replay 通过内存管道回放一条连接，返回处理器发回的全部数据
+func replay(ctx context.Context, handler connectionHandler, rec *capture.Record, opts replayOptions, out io.Writer, log zerolog.Logger) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	serverSide, clientSide := net.Pipe()
+	conn := network.NewMemoryConnection(rec.ConnID, rec.ClientIP, serverSide, log)
+
+	handlerErr := make(chan error, 1)
+	go func() {
+		err := handler.HandleConnection(ctx, conn)
+		serverSide.Close()
+		handlerErr <- err
+	}()
+
+	// 读取服务器响应；每收到一段数据通知一次，用于判断服务器是否已静默
+	var responses bytes.Buffer
+	activity := make(chan struct{}, 1)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := clientSide.Read(buf)
+			if n > 0 {
+				responses.Write(buf[:n])
+				fmt.Fprintf(out, "<- 服务器 %d 字节\n%s", n, hex.Dump(buf[:n]))
+				select {
+				case activity <- struct{}{}:
+				default:
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var err error
+	finished := false
+	start := time.Now()
+	for _, seg := range rec.Segments {
+		if seg.Direction != capture.Inbound {
+			continue
+		}
+		if opts.speed > 0 {
+			due := start.Add(time.Duration(float64(seg.Offset) / opts.speed))
+			select {
+			case <-time.After(time.Until(due)):
+			case err = <-handlerErr:
+				finished = true
+			}
+		}
+		if finished {
+			break
+		}
+		fmt.Fprintf(out, "-> 客户端 %d 字节 (+%s)\n%s", len(seg.Data), seg.Offset, hex.Dump(seg.Data))
+		if _, werr := clientSide.Write(seg.Data); werr != nil {
+			// 服务器已关闭连接，剩余数据无人读取
+			break
+		}
+	}
+
+	// 等待处理器自行结束，或服务器在静默期内不再发送数据
+	if !finished {
+		timer := time.NewTimer(opts.quiet)
+	wait:
+		for {
+			select {
+			case err = <-handlerErr:
+				finished = true
+				break wait
+			case <-activity:
+				timer.Reset(opts.quiet)
+			case <-timer.C:
+				break wait
+			}
+		}
+		timer.Stop()
+	}
+
+	clientSide.Close()
+	if !finished {
+		cancel()
+		err = <-handlerErr
+	}
+	<-readDone
+
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return responses.Bytes(), err
+}
+
+// outboundBytes 拼接抓包中服务器发出的数据
+func outboundBytes(rec *capture.Record) []byte {
+	var buf bytes.Buffer
+	for _, seg := range rec.Segments {
+		if seg.Direction == capture.Outbound {
+			buf.Write(seg.Data)
+		}
+	}
+	return buf.Bytes()
+}
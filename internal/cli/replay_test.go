@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/capture"
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/protocol"
+)
+
+func TestReplayStatusPing(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.MaxPacketSize = 1 << 20
+	var out bytes.Buffer
+	lw := &lockedWriter{w: &out}
+	honeypotLogger := logger.NewHoneypotWriterLogger(lw)
+	defer honeypotLogger.Close()
+	h := protocol.NewFastHandler(cfg, zerolog.Nop(), nil, replayLimiter{}, honeypotLogger, nil, nil, nil, nil)
+
+	// 1.20.4 状态查询握手 + 状态请求
+	handshake := []byte{0x10, 0x00, 0xFD, 0x05, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', 0x63, 0xDD, 0x01}
+	rec := &capture.Record{
+		ConnID:   "192.0.2.1-1",
+		ClientIP: "192.0.2.1",
+		Segments: []capture.Segment{
+			{Direction: capture.Inbound, Data: handshake},
+			{Direction: capture.Inbound, Offset: time.Millisecond, Data: []byte{0x01, 0x00}},
+		},
+	}
+
+	got, err := replay(context.Background(), h, rec, replayOptions{quiet: 200 * time.Millisecond}, lw, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("replay() error = %v", err)
+	}
+	honeypotLogger.Flush()
+	if len(got) == 0 || !bytes.Contains(got, []byte(`"version"`)) {
+		t.Errorf("回放响应 = %q, want 状态 JSON", got)
+	}
+	if !strings.Contains(out.String(), "-> 客户端 17 字节") || !strings.Contains(out.String(), `"event_type"`) {
+		t.Errorf("输出缺少客户端数据或蜜罐事件:\n%s", out.String())
+	}
+}
+
+func TestReadCapturesGzip(t *testing.T) {
+	var raw bytes.Buffer
+	for _, id := range []string{"192.0.2.1-1", "192.0.2.2-2"} {
+		rec := &capture.Record{ConnID: id, Start: time.Unix(1_700_000_000, 0), Segments: []capture.Segment{{Direction: capture.Inbound, Data: []byte{0x01, 0x00}}}}
+		raw.Write(rec.MarshalBinary())
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(raw.Bytes())
+	gz.Close()
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{"capture.fmcp": raw.Bytes(), "capture.fmcp.gz": compressed.Bytes()} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		err := readCaptures(path, func(rec *capture.Record) error {
+			ids = append(ids, rec.ConnID)
+			return nil
+		})
+		if err != nil || strings.Join(ids, ",") != "192.0.2.1-1,192.0.2.2-2" {
+			t.Errorf("readCaptures(%s) = %v, %v", name, ids, err)
+		}
+	}
+}
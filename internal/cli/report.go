@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/report"
+)
+
+// runReport 读取蜜罐日志（含轮转备份）生成攻击报告
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yml", "配置文件路径，未指定日志文件时读取其中的蜜罐日志及其轮转备份")
+	since := fs.String("since", "", "统计起始时间：时长（如 24h 表示最近一天）、RFC3339 或 2006-01-02")
+	until := fs.String("until", "", "统计结束时间，格式同 since")
+	topN := fs.Int("top", 10, "各排行榜保留的条目数")
+	format := fs.String("format", "text", "输出格式: text, json, html")
+	output := fs.String("o", "", "输出文件，默认输出到标准输出")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: report [选项] [日志文件...]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, *report.Report) error
+	switch *format {
+	case "text":
+		write = report.WriteText
+	case "json":
+		write = report.WriteJSON
+	case "html":
+		write = report.WriteHTML
+	default:
+		return fmt.Errorf("未知的输出格式: %s", *format)
+	}
+
+	opts := report.Options{Top: *topN}
+	now := time.Now()
+	var err error
+	if opts.Since, err = report.ParseTime(*since, now); err != nil {
+		return fmt.Errorf("since: %w", err)
+	}
+	if opts.Until, err = report.ParseTime(*until, now); err != nil {
+		return fmt.Errorf("until: %w", err)
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return fmt.Errorf("加载配置失败: %w", err)
+		}
+		if files, err = report.LogFiles(cfg.HoneypotLogging.FilePath); err != nil {
+			return err
+		}
+	}
+
+	builder := report.NewBuilder(opts)
+	for _, file := range files {
+		if err := report.ReadFile(file, func(event *logger.HoneypotEvent) error {
+			builder.Add(event)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	r := builder.Report()
+	r.Files = files
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	return write(out, r)
+}
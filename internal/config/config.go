@@ -3,9 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"fake-mc-server/internal/limiter/strategy"
 )
 
 // Config 主配置结构
@@ -19,6 +22,15 @@ type Config struct {
 	HoneypotLogging HoneypotLoggingConfig `yaml:"honeypot_logging"`
 	Monitoring      MonitoringConfig      `yaml:"monitoring"`
 	Security        SecurityConfig        `yaml:"security"`
+	Signature       SignatureConfig       `yaml:"signature"`
+	Login           LoginConfig           `yaml:"login"`
+	Capture         CaptureConfig         `yaml:"capture"`
+	Aggregation     AggregationConfig     `yaml:"aggregation"`
+	GeoIP           GeoIPConfig           `yaml:"geoip"`
+	ReverseDNS      ReverseDNSConfig      `yaml:"reverse_dns"`
+	Blocklist       BlocklistConfig       `yaml:"blocklist"`
+	AutoBan         AutoBanConfig         `yaml:"auto_ban"`
+	Tarpit          TarpitConfig          `yaml:"tarpit"`
 }
 
 // ServerConfig 服务器配置
@@ -44,10 +56,32 @@ type UpstreamConfig struct {
 
 // RateLimitConfig 限流配置
 type RateLimitConfig struct {
-	IPLimit         int           `yaml:"ip_limit"`
-	GlobalLimit     int           `yaml:"global_limit"`
+	Strategy        string        `yaml:"strategy"`     // 限流算法: token_bucket, sliding_log, sliding_window, gcra
+	IPLimit         int           `yaml:"ip_limit"`     // 单 IP 每个窗口的请求上限
+	GlobalLimit     int           `yaml:"global_limit"` // 全局每个窗口的请求上限
 	Window          time.Duration `yaml:"window"`
 	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+	MaxTrackedIPs   int           `yaml:"max_tracked_ips"` // 最多跟踪的 IP 数，超过后淘汰最久未访问的 IP，并辅以近似计数
+
+	// 限流器状态快照，重启后恢复每个 IP 的历史；state_path 为空时不保存
+	StatePath        string        `yaml:"state_path"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"` // 定期保存的间隔，退出时另外保存一次
+	StateMaxAge      time.Duration `yaml:"state_max_age"`     // 加载时丢弃闲置超过该时长的 IP
+
+	// 按网段和 ASN 聚合的限流层级，拦截轮换同一网段内大量地址、单个 IP 始终不超限的扫描
+	IPv4Subnet LimitTierConfig `yaml:"ipv4_subnet"`
+	IPv6Subnet LimitTierConfig `yaml:"ipv6_subnet"`
+	ASN        LimitTierConfig `yaml:"asn"` // 需要启用 geoip 并配置 ASN 数据库
+}
+
+// LimitTierConfig 聚合限流层级配置：同一网段或 ASN 下的所有 IP 共享限额，
+// 最近的请求速率超过限制速率时按超出的比例追加惩罚延迟
+type LimitTierConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Prefix         int           `yaml:"prefix"`          // 网段前缀长度，ASN 层级不使用
+	Limit          int           `yaml:"limit"`           // 每个窗口的请求上限
+	MaxPenalty     time.Duration `yaml:"max_penalty"`     // 最大惩罚延迟
+	RateMultiplier float64       `yaml:"rate_multiplier"` // 惩罚延迟 = (最近速率 / 限制速率 - 1) × 倍数 × 基础延迟
 }
 
 // DelayConfig 延迟配置
@@ -59,6 +93,7 @@ type DelayConfig struct {
 	GlobalLoadFactor     float64       `yaml:"global_load_factor"`
 	IPRateMultiplier     float64       `yaml:"ip_rate_multiplier"`
 	GlobalRateMultiplier float64       `yaml:"global_rate_multiplier"`
+	RateHalfLife         time.Duration `yaml:"rate_half_life"` // 请求速率估计的半衰期，越短越快反映最近的请求
 }
 
 // MessagesConfig 消息配置
@@ -93,6 +128,42 @@ type HoneypotLoggingConfig struct {
 	MaxAge     int    `yaml:"max_age"`
 	Compress   bool   `yaml:"compress"`
 	Format     string `yaml:"format"` // json, csv
+
+	CommandsFilePath string `yaml:"commands_file_path"` // 陷阱世界聊天/命令日志，默认与 file_path 同目录
+
+	Sinks []EventSinkConfig `yaml:"sinks"` // 事件同时投递到的其他目标
+
+	QueueSize  int    `yaml:"queue_size"`  // 异步写入队列容量，向上取整到 2 的幂
+	DropPolicy string `yaml:"drop_policy"` // 队列满时: drop_oldest, drop_newest, block
+	BatchSize  int    `yaml:"batch_size"`  // 后台每批最多写入的事件数，每批刷新一次文件
+}
+
+// EventSinkConfig 蜜罐事件投递目标配置
+type EventSinkConfig struct {
+	Type       string `yaml:"type"`        // syslog, webhook, unix, sqlite
+	BufferSize int    `yaml:"buffer_size"` // 每个目标独立的事件缓冲数量，写满后丢弃新事件
+
+	// syslog
+	Network  string `yaml:"network"`  // udp, tcp, unix
+	Address  string `yaml:"address"`  // host:port 或 unix 套接字路径
+	Facility string `yaml:"facility"` // 如 local0, daemon
+	Tag      string `yaml:"tag"`      // APP-NAME 字段
+
+	// webhook
+	URL           string            `yaml:"url"`
+	Headers       map[string]string `yaml:"headers"`
+	BatchSize     int               `yaml:"batch_size"`     // 每次 POST 的最大事件数
+	FlushInterval time.Duration     `yaml:"flush_interval"` // 未攒满一批时的最长等待时间
+	MaxRetries    int               `yaml:"max_retries"`
+	Timeout       time.Duration     `yaml:"timeout"`
+	SpoolPath     string            `yaml:"spool_path"`      // 投递失败的批次暂存文件，恢复后补发
+	MaxSpoolBytes int64             `yaml:"max_spool_bytes"` // 暂存文件大小上限，超过后丢弃最早的批次
+
+	// unix, sqlite
+	Path string `yaml:"path"` // unix: 监听的套接字路径，连接上的客户端接收 JSON Lines 事件流; sqlite: 数据库文件
+
+	// sqlite
+	Retention time.Duration `yaml:"retention"` // 事件保留时长，0 表示永久保留
 }
 
 // MonitoringConfig 监控配置
@@ -111,6 +182,156 @@ type SecurityConfig struct {
 	IPBlacklist       []string      `yaml:"ip_blacklist"`
 	MaxPacketSize     int           `yaml:"max_packet_size"`
 	ConnectionTimeout time.Duration `yaml:"connection_timeout"`
+	DenyLabels        []string      `yaml:"deny_labels"` // 命中这些特征标签的连接直接断开
+}
+
+// SignatureConfig 扫描器特征库配置
+type SignatureConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	RulesPath      string        `yaml:"rules_path"`
+	ReloadInterval time.Duration `yaml:"reload_interval"` // 检查规则文件变化的间隔
+}
+
+// LoginConfig 登录流程配置
+type LoginConfig struct {
+	Encryption bool            `yaml:"encryption"` // 踢出前先完成伪造的正版加密握手
+	TrapWorld  TrapWorldConfig `yaml:"trap_world"`
+}
+
+// TrapWorldConfig 陷阱世界配置
+type TrapWorldConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Duration time.Duration `yaml:"duration"` // 进入世界后多久断开连接
+}
+
+// CaptureConfig 原始数据包抓取配置
+type CaptureConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	FilePath   string   `yaml:"file_path"`
+	MaxBytes   int      `yaml:"max_bytes"`  // 每个方向最多记录的字节数
+	Mode       string   `yaml:"mode"`       // all, violations, cidrs, percentage
+	CIDRs      []string `yaml:"cidrs"`      // mode 为 cidrs 时抓取的网段
+	Percentage float64  `yaml:"percentage"` // mode 为 percentage 时的抓取比例 (0-100)
+	MaxSize    int      `yaml:"max_size"`
+	MaxBackups int      `yaml:"max_backups"`
+	MaxAge     int      `yaml:"max_age"`
+	Compress   bool     `yaml:"compress"`
+}
+
+// AggregationConfig 按 IP 聚合攻击会话的配置
+type AggregationConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	SessionGap   time.Duration `yaml:"session_gap"`   // 同一 IP 超过该间隔没有事件则结束会话
+	EmitInterval time.Duration `yaml:"emit_interval"` // 活跃会话输出阶段性摘要的间隔
+	MaxSessions  int           `yaml:"max_sessions"`  // 同时跟踪的最大会话数，超出时提前结束最久未活动的会话
+	MaxDistinct  int           `yaml:"max_distinct"`  // 每个会话最多记录的不同主机名/用户名/协议数
+}
+
+// GeoIPConfig 离线 GeoIP/ASN 数据库配置
+type GeoIPConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	CityPath       string        `yaml:"city_path"`       // GeoLite2-City、DB-IP City Lite 等 mmdb 文件
+	ASNPath        string        `yaml:"asn_path"`        // GeoLite2-ASN、DB-IP ASN Lite 等 mmdb 文件
+	ReloadInterval time.Duration `yaml:"reload_interval"` // 检查文件变化的间隔
+	CacheSize      int           `yaml:"cache_size"`      // 按 IP 缓存的查询结果数
+}
+
+// ReverseDNSConfig 客户端 IP 反向解析配置
+type ReverseDNSConfig struct {
+	Enabled     bool           `yaml:"enabled"`
+	Workers     int            `yaml:"workers"`      // 并发解析协程数
+	RateLimit   float64        `yaml:"rate_limit"`   // 每秒最多发出的 PTR 查询数
+	Timeout     time.Duration  `yaml:"timeout"`      // 单次查询超时
+	CacheTTL    time.Duration  `yaml:"cache_ttl"`    // 解析成功的缓存时间
+	NegativeTTL time.Duration  `yaml:"negative_ttl"` // 无 PTR 记录或查询失败的缓存时间
+	CacheSize   int            `yaml:"cache_size"`
+	QueueSize   int            `yaml:"queue_size"` // 待解析队列长度，写满时跳过
+	Providers   []ProviderRule `yaml:"providers"`  // 按顺序匹配，第一条命中的规则生效
+}
+
+// ProviderRule PTR 主机名分类规则，Pattern 为 path.Match 通配符（如 *.amazonaws.com）
+type ProviderRule struct {
+	Pattern  string `yaml:"pattern"`
+	Provider string `yaml:"provider"`
+}
+
+// BlocklistConfig 封禁列表导出配置
+type BlocklistConfig struct {
+	Enabled   bool          `yaml:"enabled"`    // 在监控端口提供 HTTP 导出
+	Path      string        `yaml:"path"`       // HTTP 路径
+	Token     string        `yaml:"token"`      // 必填，请求需携带 Authorization: Bearer <token>
+	MinEvents int           `yaml:"min_events"` // 默认的最少事件数，可被查询参数覆盖
+	Window    time.Duration `yaml:"window"`     // 默认只统计最近这段时间内的事件，可被查询参数覆盖
+	SetName   string        `yaml:"set_name"`   // ipset/nftables 集合名，IPv6 集合追加后缀
+}
+
+// AutoBanConfig 自动封禁配置，各阈值为窗口内的次数上限，负数表示不按该项封禁
+type AutoBanConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Window           time.Duration `yaml:"window"`              // 统计违规次数的时间窗口
+	MaxViolations    int           `yaml:"max_violations"`      // 协议违规
+	MaxLoginAttempts int           `yaml:"max_login_attempts"`  // 登录尝试
+	MaxRateLimitHits int           `yaml:"max_rate_limit_hits"` // 触发 IP 限流
+	BanDuration      time.Duration `yaml:"ban_duration"`        // 首次封禁时长
+	Multiplier       float64       `yaml:"multiplier"`          // 再次封禁时的时长倍数
+	MaxBanDuration   time.Duration `yaml:"max_ban_duration"`    // 封禁时长上限
+	ForgetAfter      time.Duration `yaml:"forget_after"`        // 封禁结束后超过该时间未再犯，重新按首次封禁计算
+	StatePath        string        `yaml:"state_path"`          // 封禁记录持久化文件，重启后恢复
+	APIPath          string        `yaml:"api_path"`            // 监控端口上的管理接口路径
+	Token            string        `yaml:"token"`               // 必填，管理接口要求 Authorization: Bearer <token>
+	MaxTrackedIPs    int           `yaml:"max_tracked_ips"`     // 最多同时计数的 IP 数，超过后淘汰最久未违规的 IP
+}
+
+// TarpitConfig 焦油坑配置：计算出的延迟达到阈值的连接不再占用协程等待，
+// 而是由共享的时间轮每隔 interval 写出 chunk_size 字节响应，写完或超时后关闭
+type TarpitConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	DelayThreshold time.Duration `yaml:"delay_threshold"` // 延迟达到该值的 IP 进入焦油坑
+	Interval       time.Duration `yaml:"interval"`        // 每次写出的间隔
+	ChunkSize      int           `yaml:"chunk_size"`      // 每次写出的字节数
+	MaxDuration    time.Duration `yaml:"max_duration"`    // 单个连接最长保持时间
+	MaxConnections int           `yaml:"max_connections"` // 同时处于焦油坑的连接数上限
+	MaxPerIP       int           `yaml:"max_per_ip"`      // 每个 IP 同时处于焦油坑的连接数上限
+}
+
+// defaultProviderRules 未配置分类规则时使用的常见云厂商、扫描器组织和家庭宽带规则
+var defaultProviderRules = []ProviderRule{
+	{"*.amazonaws.com", "aws"},
+	{"*.googleusercontent.com", "gcp"},
+	{"*.cloudapp.azure.com", "azure"},
+	{"*.linodeusercontent.com", "linode"},
+	{"*.members.linode.com", "linode"},
+	{"*.vultrusercontent.com", "vultr"},
+	{"*.your-server.de", "hetzner"},
+	{"*.ovh.net", "ovh"},
+	{"*.contaboserver.net", "contabo"},
+	{"*.aliyun.com", "aliyun"},
+	{"*.tencentcloud.com", "tencent"},
+	{"*.shodan.io", "shodan"},
+	{"*.censys-scanner.com", "censys"},
+	{"*.binaryedge.ninja", "binaryedge"},
+	{"*.shadowserver.org", "shadowserver"},
+	{"*.internet-measurement.com", "internet-measurement"},
+	{"*.stretchoid.com", "stretchoid"},
+	// 家庭宽带关键字只按完整的标签或以连字符分隔的片段匹配，避免 whirlpool、dslreports 之类的误判
+	{"dsl-*", "residential"},
+	{"*-dsl-*", "residential"},
+	{"*.dsl.*", "residential"},
+	{"dynamic-*", "residential"},
+	{"*-dynamic-*", "residential"},
+	{"*.dynamic.*", "residential"},
+	{"dhcp-*", "residential"},
+	{"*-dhcp-*", "residential"},
+	{"*.dhcp.*", "residential"},
+	{"pool-*", "residential"},
+	{"*-pool-*", "residential"},
+	{"*.pool.*", "residential"},
+	{"cable-*", "residential"},
+	{"*-cable-*", "residential"},
+	{"*.cable.*", "residential"},
+	{"broadband-*", "residential"},
+	{"*-broadband-*", "residential"},
+	{"*.broadband.*", "residential"},
 }
 
 // Load 从文件加载配置
@@ -154,6 +375,9 @@ func setDefaults(config *Config) {
 		config.Server.IdleTimeout = 10 * time.Minute
 	}
 
+	if config.RateLimit.Strategy == "" {
+		config.RateLimit.Strategy = strategy.TokenBucket
+	}
 	if config.RateLimit.IPLimit == 0 {
 		config.RateLimit.IPLimit = 5
 	}
@@ -166,6 +390,18 @@ func setDefaults(config *Config) {
 	if config.RateLimit.CleanupInterval == 0 {
 		config.RateLimit.CleanupInterval = time.Minute
 	}
+	if config.RateLimit.MaxTrackedIPs == 0 {
+		config.RateLimit.MaxTrackedIPs = 100000
+	}
+	if config.RateLimit.SnapshotInterval == 0 {
+		config.RateLimit.SnapshotInterval = time.Minute
+	}
+	if config.RateLimit.StateMaxAge == 0 {
+		config.RateLimit.StateMaxAge = 24 * time.Hour
+	}
+	setTierDefaults(&config.RateLimit.IPv4Subnet, 24, 20, 3*time.Second)
+	setTierDefaults(&config.RateLimit.IPv6Subnet, 64, 20, 3*time.Second)
+	setTierDefaults(&config.RateLimit.ASN, 0, 100, 2*time.Second)
 
 	if config.Delay.BaseDelay == 0 {
 		config.Delay.BaseDelay = 100 * time.Millisecond
@@ -188,6 +424,9 @@ func setDefaults(config *Config) {
 	if config.Delay.GlobalRateMultiplier == 0 {
 		config.Delay.GlobalRateMultiplier = 1.5
 	}
+	if config.Delay.RateHalfLife == 0 {
+		config.Delay.RateHalfLife = 10 * time.Second
+	}
 
 	if config.Messages.MOTD == "" {
 		config.Messages.MOTD = "§6Welcome to the Fake Minecraft Server!"
@@ -221,6 +460,201 @@ func setDefaults(config *Config) {
 	if config.Security.ConnectionTimeout == 0 {
 		config.Security.ConnectionTimeout = 30 * time.Second
 	}
+
+	if config.HoneypotLogging.QueueSize == 0 {
+		config.HoneypotLogging.QueueSize = 65536
+	}
+	if config.HoneypotLogging.DropPolicy == "" {
+		config.HoneypotLogging.DropPolicy = "drop_oldest"
+	}
+	if config.HoneypotLogging.BatchSize == 0 {
+		config.HoneypotLogging.BatchSize = 256
+	}
+	for i := range config.HoneypotLogging.Sinks {
+		setSinkDefaults(&config.HoneypotLogging.Sinks[i])
+	}
+
+	if config.Signature.RulesPath == "" {
+		config.Signature.RulesPath = "config/signatures.yml"
+	}
+	if config.Signature.ReloadInterval == 0 {
+		config.Signature.ReloadInterval = 30 * time.Second
+	}
+
+	if config.Capture.FilePath == "" {
+		config.Capture.FilePath = "logs/capture.fmcp"
+	}
+	if config.Capture.MaxBytes == 0 {
+		config.Capture.MaxBytes = 4096
+	}
+	if config.Capture.Mode == "" {
+		config.Capture.Mode = "violations"
+	}
+	if config.Capture.MaxSize == 0 {
+		config.Capture.MaxSize = 100
+	}
+
+	if config.Aggregation.SessionGap == 0 {
+		config.Aggregation.SessionGap = 10 * time.Minute
+	}
+	if config.Aggregation.EmitInterval == 0 {
+		config.Aggregation.EmitInterval = time.Minute
+	}
+	if config.Aggregation.MaxSessions == 0 {
+		config.Aggregation.MaxSessions = 100000
+	}
+	if config.Aggregation.MaxDistinct == 0 {
+		config.Aggregation.MaxDistinct = 50
+	}
+
+	if config.GeoIP.ReloadInterval == 0 {
+		config.GeoIP.ReloadInterval = time.Minute
+	}
+	if config.GeoIP.CacheSize == 0 {
+		config.GeoIP.CacheSize = 65536
+	}
+
+	if config.ReverseDNS.Workers == 0 {
+		config.ReverseDNS.Workers = 4
+	}
+	if config.ReverseDNS.RateLimit == 0 {
+		config.ReverseDNS.RateLimit = 20
+	}
+	if config.ReverseDNS.Timeout == 0 {
+		config.ReverseDNS.Timeout = 2 * time.Second
+	}
+	if config.ReverseDNS.CacheTTL == 0 {
+		config.ReverseDNS.CacheTTL = 6 * time.Hour
+	}
+	if config.ReverseDNS.NegativeTTL == 0 {
+		config.ReverseDNS.NegativeTTL = 30 * time.Minute
+	}
+	if config.ReverseDNS.CacheSize == 0 {
+		config.ReverseDNS.CacheSize = 65536
+	}
+	if config.ReverseDNS.QueueSize == 0 {
+		config.ReverseDNS.QueueSize = 1024
+	}
+	if config.ReverseDNS.Providers == nil {
+		config.ReverseDNS.Providers = slices.Clone(defaultProviderRules)
+	}
+
+	if config.Monitoring.MetricsPort == 0 {
+		config.Monitoring.MetricsPort = 8080
+	}
+	if config.Monitoring.HealthCheckPath == "" {
+		config.Monitoring.HealthCheckPath = "/health"
+	}
+
+	if config.Blocklist.Path == "" {
+		config.Blocklist.Path = "/blocklist"
+	}
+	if config.Blocklist.MinEvents == 0 {
+		config.Blocklist.MinEvents = 5
+	}
+	if config.Blocklist.Window == 0 {
+		config.Blocklist.Window = 24 * time.Hour
+	}
+	if config.Blocklist.SetName == "" {
+		config.Blocklist.SetName = "fakemc_blocklist"
+	}
+
+	if config.AutoBan.Window == 0 {
+		config.AutoBan.Window = 10 * time.Minute
+	}
+	if config.AutoBan.MaxViolations == 0 {
+		config.AutoBan.MaxViolations = 10
+	}
+	if config.AutoBan.MaxLoginAttempts == 0 {
+		config.AutoBan.MaxLoginAttempts = 20
+	}
+	if config.AutoBan.MaxRateLimitHits == 0 {
+		config.AutoBan.MaxRateLimitHits = 50
+	}
+	if config.AutoBan.BanDuration == 0 {
+		config.AutoBan.BanDuration = 10 * time.Minute
+	}
+	if config.AutoBan.Multiplier == 0 {
+		config.AutoBan.Multiplier = 4
+	}
+	if config.AutoBan.MaxBanDuration == 0 {
+		config.AutoBan.MaxBanDuration = 7 * 24 * time.Hour
+	}
+	if config.AutoBan.ForgetAfter == 0 {
+		config.AutoBan.ForgetAfter = 30 * 24 * time.Hour
+	}
+	if config.AutoBan.StatePath == "" {
+		config.AutoBan.StatePath = "data/bans.json"
+	}
+	if config.AutoBan.APIPath == "" {
+		config.AutoBan.APIPath = "/bans"
+	}
+	if config.AutoBan.MaxTrackedIPs == 0 {
+		config.AutoBan.MaxTrackedIPs = 100000
+	}
+
+	if config.Tarpit.DelayThreshold == 0 {
+		config.Tarpit.DelayThreshold = 3 * time.Second
+	}
+	if config.Tarpit.Interval == 0 {
+		config.Tarpit.Interval = 2 * time.Second
+	}
+	if config.Tarpit.ChunkSize == 0 {
+		config.Tarpit.ChunkSize = 1
+	}
+	if config.Tarpit.MaxDuration == 0 {
+		config.Tarpit.MaxDuration = 10 * time.Minute
+	}
+	if config.Tarpit.MaxConnections == 0 {
+		config.Tarpit.MaxConnections = 1000
+	}
+	if config.Tarpit.MaxPerIP == 0 {
+		config.Tarpit.MaxPerIP = 2
+	}
+
+	if config.Login.TrapWorld.Duration == 0 {
+		config.Login.TrapWorld.Duration = 5 * time.Minute
+	}
+}
+
+// setSinkDefaults 设置事件投递目标的默认值
+func setSinkDefaults(sink *EventSinkConfig) {
+	if sink.BufferSize == 0 {
+		sink.BufferSize = 1024
+	}
+	if sink.BatchSize == 0 {
+		if sink.Type == "webhook" || sink.Type == "sqlite" {
+			sink.BatchSize = 100
+		} else {
+			sink.BatchSize = 1
+		}
+	}
+	if sink.FlushInterval == 0 {
+		sink.FlushInterval = 5 * time.Second
+	}
+
+	switch sink.Type {
+	case "syslog":
+		if sink.Network == "" {
+			sink.Network = "udp"
+		}
+		if sink.Facility == "" {
+			sink.Facility = "local0"
+		}
+		if sink.Tag == "" {
+			sink.Tag = "fake-mc-server"
+		}
+	case "webhook":
+		if sink.MaxRetries == 0 {
+			sink.MaxRetries = 3
+		}
+		if sink.Timeout == 0 {
+			sink.Timeout = 10 * time.Second
+		}
+		if sink.MaxSpoolBytes == 0 {
+			sink.MaxSpoolBytes = 64 << 20 // 64MB
+		}
+	}
 }
 
 // validate 验证配置
@@ -233,6 +667,14 @@ func validate(config *Config) error {
 		return fmt.Errorf("最大连接数必须大于 0")
 	}
 
+	if config.Security.MaxPacketSize < 1 {
+		return fmt.Errorf("最大数据包大小必须大于 0")
+	}
+
+	if config.RateLimit.Strategy != "" && !strategy.Valid(config.RateLimit.Strategy) {
+		return fmt.Errorf("不支持的限流算法: %s", config.RateLimit.Strategy)
+	}
+
 	if config.RateLimit.IPLimit < 1 {
 		return fmt.Errorf("IP 限流值必须大于 0")
 	}
@@ -241,6 +683,32 @@ func validate(config *Config) error {
 		return fmt.Errorf("全局限流值必须大于 0")
 	}
 
+	if config.RateLimit.Window < 0 {
+		return fmt.Errorf("限流窗口不能为负数")
+	}
+
+	if config.RateLimit.MaxTrackedIPs < 0 {
+		return fmt.Errorf("最多跟踪的 IP 数不能为负数")
+	}
+
+	if config.RateLimit.StatePath != "" && config.RateLimit.SnapshotInterval <= 0 {
+		return fmt.Errorf("限流器状态保存间隔必须大于 0")
+	}
+
+	if tier := config.RateLimit.IPv4Subnet; tier.Enabled && (tier.Prefix < 1 || tier.Prefix > 32) {
+		return fmt.Errorf("IPv4 网段前缀长度必须在 1 到 32 之间")
+	}
+
+	if tier := config.RateLimit.IPv6Subnet; tier.Enabled && (tier.Prefix < 1 || tier.Prefix > 128) {
+		return fmt.Errorf("IPv6 网段前缀长度必须在 1 到 128 之间")
+	}
+
+	for _, tier := range []LimitTierConfig{config.RateLimit.IPv4Subnet, config.RateLimit.IPv6Subnet, config.RateLimit.ASN} {
+		if tier.Enabled && tier.Limit < 1 {
+			return fmt.Errorf("网段和 ASN 限流值必须大于 0")
+		}
+	}
+
 	if config.Delay.IPFrequencyFactor <= 0 {
 		return fmt.Errorf("IP 频率因子必须大于 0")
 	}
@@ -249,10 +717,98 @@ func validate(config *Config) error {
 		return fmt.Errorf("全局负载因子必须大于 0")
 	}
 
+	if config.Delay.RateHalfLife < 0 {
+		return fmt.Errorf("请求速率半衰期不能为负数")
+	}
+
 	if config.Messages.ProtocolVersion < 1 {
 		return fmt.Errorf("协议版本必须大于 0")
 	}
 
+	switch config.HoneypotLogging.DropPolicy {
+	case "", "drop_oldest", "drop_newest", "block":
+	default:
+		return fmt.Errorf("不支持的蜜罐日志丢弃策略: %s", config.HoneypotLogging.DropPolicy)
+	}
+
+	if config.Blocklist.Enabled && !config.Monitoring.Enabled {
+		return fmt.Errorf("封禁列表 HTTP 导出需要启用 monitoring")
+	}
+	if config.Blocklist.Enabled && config.Blocklist.Token == "" {
+		return fmt.Errorf("封禁列表 HTTP 导出需要配置 token")
+	}
+
+	if config.RateLimit.ASN.Enabled && (!config.GeoIP.Enabled || config.GeoIP.ASNPath == "") {
+		return fmt.Errorf("ASN 限流需要启用 geoip 并配置 asn_path")
+	}
+
+	if config.AutoBan.Enabled && config.AutoBan.Multiplier < 1 {
+		return fmt.Errorf("自动封禁时长倍数不能小于 1")
+	}
+	if config.AutoBan.Enabled && config.AutoBan.Token == "" {
+		return fmt.Errorf("自动封禁管理接口需要配置 token")
+	}
+	// 协议违规和登录尝试通过蜜罐事件计数
+	if config.AutoBan.Enabled && !config.HoneypotLogging.Enabled &&
+		(config.AutoBan.MaxViolations >= 0 || config.AutoBan.MaxLoginAttempts >= 0) {
+		return fmt.Errorf("按协议违规或登录尝试自动封禁需要启用 honeypot_logging")
+	}
+	if config.AutoBan.MaxTrackedIPs < 0 {
+		return fmt.Errorf("自动封禁最多计数的 IP 数不能为负数")
+	}
+
+	for i, sink := range config.HoneypotLogging.Sinks {
+		if err := validateSink(sink); err != nil {
+			return fmt.Errorf("蜜罐事件投递目标 #%d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// setTierDefaults 设置聚合限流层级的默认值
+func setTierDefaults(tier *LimitTierConfig, prefix, limit int, maxPenalty time.Duration) {
+	if tier.Prefix == 0 {
+		tier.Prefix = prefix
+	}
+	if tier.Limit == 0 {
+		tier.Limit = limit
+	}
+	if tier.MaxPenalty == 0 {
+		tier.MaxPenalty = maxPenalty
+	}
+	if tier.RateMultiplier == 0 {
+		tier.RateMultiplier = 2.0
+	}
+}
+
+// validateSink 验证事件投递目标配置
+func validateSink(sink EventSinkConfig) error {
+	switch sink.Type {
+	case "syslog":
+		if sink.Address == "" {
+			return fmt.Errorf("syslog 需要配置 address")
+		}
+		if sink.Network != "udp" && sink.Network != "tcp" && sink.Network != "unix" {
+			return fmt.Errorf("不支持的 syslog 网络类型: %s", sink.Network)
+		}
+	case "webhook":
+		if sink.URL == "" {
+			return fmt.Errorf("webhook 需要配置 url")
+		}
+		if sink.MaxSpoolBytes < 0 {
+			return fmt.Errorf("webhook 暂存文件大小上限不能为负数")
+		}
+	case "unix", "sqlite":
+		if sink.Path == "" {
+			return fmt.Errorf("%s 需要配置 path", sink.Type)
+		}
+	default:
+		return fmt.Errorf("不支持的类型: %q", sink.Type)
+	}
+	if sink.BufferSize < 1 || sink.BatchSize < 1 {
+		return fmt.Errorf("buffer_size 和 batch_size 必须大于 0")
+	}
 	return nil
 }
 
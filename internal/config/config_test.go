@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path"
 	"testing"
 	"time"
 )
@@ -104,6 +105,9 @@ func TestValidate(t *testing.T) {
 				Messages: MessagesConfig{
 					ProtocolVersion: 766,
 				},
+				Security: SecurityConfig{
+					MaxPacketSize: 1048576,
+				},
 			},
 			wantErr: false,
 		},
@@ -125,6 +129,33 @@ func TestValidate(t *testing.T) {
 				Messages: MessagesConfig{
 					ProtocolVersion: 766,
 				},
+				Security: SecurityConfig{
+					MaxPacketSize: 1048576,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "无效数据包大小",
+			config: &Config{
+				Server: ServerConfig{
+					Port:           25565,
+					MaxConnections: 1000,
+				},
+				RateLimit: RateLimitConfig{
+					IPLimit:     5,
+					GlobalLimit: 100,
+				},
+				Delay: DelayConfig{
+					IPFrequencyFactor: 1.5,
+					GlobalLoadFactor:  1.2,
+				},
+				Messages: MessagesConfig{
+					ProtocolVersion: 766,
+				},
+				Security: SecurityConfig{
+					MaxPacketSize: -1,
+				},
 			},
 			wantErr: true,
 		},
@@ -176,4 +207,34 @@ func TestSetDefaults(t *testing.T) {
 	if cfg.Delay.BaseDelay != 100*time.Millisecond {
 		t.Errorf("期望默认 base_delay 为 100ms，实际为 %v", cfg.Delay.BaseDelay)
 	}
+
+	if cfg.Security.MaxPacketSize != 1048576 {
+		t.Errorf("期望默认 max_packet_size 为 1048576，实际为 %d", cfg.Security.MaxPacketSize)
+	}
+}
+
+func TestDefaultProviderRules(t *testing.T) {
+	classify := func(hostname string) string {
+		for _, rule := range defaultProviderRules {
+			if ok, _ := path.Match(rule.Pattern, hostname); ok {
+				return rule.Provider
+			}
+		}
+		return ""
+	}
+
+	for hostname, want := range map[string]string{
+		"pool-71-1-2-3.nycmny.fios.verizon.net":  "residential",
+		"adsl-99-1-2-3.dsl.sfldmi.sbcglobal.net": "residential",
+		"host-1-2-3-4-dynamic-ip.example.net":    "residential",
+		"1-2-3-4.cable.virginm.net":              "residential",
+		"ec2-192-0-2-1.compute-1.amazonaws.com":  "aws",
+		"mail.whirlpool.com":                     "",
+		"www.dslreports.com":                     "",
+		"carpool.example.org":                    "",
+	} {
+		if got := classify(hostname); got != want {
+			t.Errorf("classify(%s) = %q, want %q", hostname, got, want)
+		}
+	}
 }
@@ -0,0 +1,229 @@
+// Package delay 延迟调度器：延迟响应由共享的分层时间轮定时写出，不为每个慢速客户端占用协程或定时器
+package delay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/timewheel"
+)
+
+// 时间轮参数：10ms 精度，三层覆盖约 46 小时
+const (
+	wheelTick   = 10 * time.Millisecond
+	wheelSlots  = 256
+	wheelLevels = 3
+
+	writeTimeout = time.Second // 单次写出的超时，避免阻塞时间轮协程
+)
+
+var (
+	errStopped = errors.New("延迟调度器已停止")
+	errClosed  = errors.New("连接已关闭")
+)
+
+// Conn 延迟调度器接管的连接，*network.Connection 满足该接口
+type Conn interface {
+	io.WriteCloser
+	SetWriteDeadline(t time.Time) error
+	OnClose(fn func()) // 连接关闭时回调，回调可能执行不止一次
+}
+
+// entry 一个等待中的延迟：写出后关闭连接，或唤醒等待的协程
+type entry struct {
+	timer *timewheel.Timer
+	conn  Conn
+	data  []byte
+	done  chan struct{} // 非 nil 时为 Wait
+	err   error
+}
+
+// Scheduler 延迟调度器
+type Scheduler struct {
+	logger zerolog.Logger
+	wheel  *timewheel.Wheel
+
+	mu      sync.Mutex
+	pending map[*entry]struct{}
+	writes  int // 等待写出的响应数
+	closed  bool
+
+	delayed   atomic.Int64 // 累计按时写出的响应数
+	cancelled atomic.Int64 // 因连接关闭、ctx 取消或停止而放弃的延迟数
+}
+
+// NewScheduler 创建延迟调度器，需调用 Run 驱动
+func NewScheduler(logger zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		logger:  logger.With().Str("component", "delay_scheduler").Logger(),
+		wheel:   timewheel.New(wheelTick, wheelSlots, wheelLevels),
+		pending: make(map[*entry]struct{}),
+	}
+}
+
+// WriteAndClose 在 d 之后写出 data（可以为空）并关闭连接，连接提前关闭时放弃
+// 调用后调用方不能再读写或关闭 conn；s 为 nil 时使用独立的定时器
+func (s *Scheduler) WriteAndClose(conn Conn, d time.Duration, data []byte) {
+	if s == nil {
+		time.AfterFunc(d, func() { writeAndClose(conn, data) })
+		return
+	}
+	e := &entry{conn: conn, data: data}
+	if !s.add(e, d) {
+		conn.Close()
+		return
+	}
+	conn.OnClose(func() { s.cancel(e, errClosed) })
+}
+
+// Wait 等待 d，ctx 取消、连接关闭或调度器停止时提前返回错误
+func (s *Scheduler) Wait(ctx context.Context, conn Conn, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if s == nil {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	e := &entry{done: make(chan struct{})}
+	if !s.add(e, d) {
+		return errStopped
+	}
+	conn.OnClose(func() { s.cancel(e, errClosed) })
+	select {
+	case <-e.done:
+		return e.err
+	case <-ctx.Done():
+		s.cancel(e, ctx.Err())
+		<-e.done
+		return e.err
+	}
+}
+
+// add 登记延迟并放到时间轮上，调度器已停止时返回 false
+func (s *Scheduler) add(e *entry, d time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.pending[e] = struct{}{}
+	if e.done == nil {
+		s.writes++
+	}
+	// 回调需要获取 s.mu，不会在 timer 赋值之前执行
+	e.timer = s.wheel.Schedule(d, func() { s.fire(e) })
+	return true
+}
+
+// take 取出等待中的延迟，已处理过时返回 false
+func (s *Scheduler) take(e *entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[e]; !ok {
+		return false
+	}
+	delete(s.pending, e)
+	if e.done == nil {
+		s.writes--
+	}
+	return true
+}
+
+// fire 延迟到期
+func (s *Scheduler) fire(e *entry) {
+	if !s.take(e) {
+		return
+	}
+	if e.done != nil {
+		close(e.done)
+		return
+	}
+	s.delayed.Add(1)
+	writeAndClose(e.conn, e.data)
+}
+
+// cancel 放弃延迟：等待方返回 err，待写出的连接直接关闭
+func (s *Scheduler) cancel(e *entry, err error) {
+	if !s.take(e) {
+		return
+	}
+	e.timer.Stop()
+	s.cancelled.Add(1)
+	if e.done != nil {
+		e.err = err
+		close(e.done)
+		return
+	}
+	e.conn.Close()
+}
+
+// writeAndClose 写出数据并关闭连接
+func writeAndClose(conn Conn, data []byte) {
+	if len(data) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		conn.Write(data)
+	}
+	conn.Close()
+}
+
+// Run 驱动时间轮，ctx 取消后放弃所有等待中的延迟并关闭对应的连接
+func (s *Scheduler) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	s.wheel.Run(ctx)
+
+	s.mu.Lock()
+	s.closed = true
+	entries := make([]*entry, 0, len(s.pending))
+	for e := range s.pending {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+	for _, e := range entries {
+		s.cancel(e, errStopped)
+	}
+	if len(entries) > 0 {
+		s.logger.Debug().Int("count", len(entries)).Msg("已放弃未到期的延迟")
+	}
+}
+
+// Pending 返回等待写出的延迟响应数
+func (s *Scheduler) Pending() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+// GetStats 获取统计信息
+func (s *Scheduler) GetStats() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	writes, waits := s.writes, len(s.pending)-s.writes
+	s.mu.Unlock()
+	return map[string]interface{}{
+		"pending_responses":   writes,
+		"waiting_connections": waits,
+		"delayed_responses":   s.delayed.Load(),
+		"cancelled_delays":    s.cancelled.Load(),
+	}
+}
@@ -0,0 +1,125 @@
+package delay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeConn 记录写入的数据，Close 时执行关闭回调
+type fakeConn struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	onClose []func()
+	closed  chan struct{}
+}
+
+func newFakeConn() *fakeConn { return &fakeConn{closed: make(chan struct{})} }
+
+func (c *fakeConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		return nil
+	default:
+	}
+	close(c.closed)
+	callbacks := c.onClose
+	c.mu.Unlock()
+	for _, fn := range callbacks {
+		fn()
+	}
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *fakeConn) OnClose(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onClose = append(c.onClose, fn)
+}
+
+func (c *fakeConn) written() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(zerolog.Nop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	// 到期后写出并关闭
+	conn := newFakeConn()
+	start := time.Now()
+	s.WriteAndClose(conn, 50*time.Millisecond, []byte("kick"))
+	if s.Pending() != 1 {
+		t.Errorf("Pending() = %d, want 1", s.Pending())
+	}
+	<-conn.closed
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || conn.written() != "kick" {
+		t.Errorf("%s 后写出 %q", elapsed, conn.written())
+	}
+
+	// 连接提前关闭时放弃写出
+	closedEarly := newFakeConn()
+	s.WriteAndClose(closedEarly, time.Hour, []byte("kick"))
+	closedEarly.Close()
+	if s.Pending() != 0 || closedEarly.written() != "" {
+		t.Errorf("连接关闭后 Pending() = %d, written = %q", s.Pending(), closedEarly.written())
+	}
+
+	// Wait 按时返回，连接关闭或 ctx 取消时提前返回
+	if err := s.Wait(context.Background(), newFakeConn(), 20*time.Millisecond); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+	waiting := newFakeConn()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		waiting.Close()
+	}()
+	if err := s.Wait(context.Background(), waiting, time.Hour); !errors.Is(err, errClosed) {
+		t.Errorf("连接关闭时 Wait() = %v", err)
+	}
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer waitCancel()
+	if err := s.Wait(waitCtx, newFakeConn(), time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx 取消时 Wait() = %v", err)
+	}
+
+	// 停止时关闭所有等待写出的连接
+	pending := newFakeConn()
+	s.WriteAndClose(pending, time.Hour, []byte("kick"))
+	cancel()
+	<-done
+	select {
+	case <-pending.closed:
+	default:
+		t.Error("停止后应关闭等待写出的连接")
+	}
+	stats := s.GetStats()
+	if stats["pending_responses"] != 0 || stats["delayed_responses"] != int64(1) || stats["cancelled_delays"] != int64(4) {
+		t.Errorf("GetStats() = %v", stats)
+	}
+	if err := s.Wait(context.Background(), newFakeConn(), time.Second); !errors.Is(err, errStopped) {
+		t.Errorf("停止后 Wait() = %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package fingerprint
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 客户端分类
+const (
+	ClientVanilla      = "vanilla"       // 原版客户端
+	ClientForge        = "forge"         // Forge/NeoForge 客户端
+	ClientFabric       = "fabric"        // Fabric 客户端
+	ClientMCStatus     = "mcstatus"      // mcstatus 等状态查询库
+	ClientNmap         = "nmap"          // nmap 服务探测
+	ClientBannerGrab   = "banner_grab"   // masscan 式的横幅抓取
+	ClientBotFramework = "bot_framework" // mineflayer 等机器人框架
+	ClientUnknown      = "unknown"       // 无法识别
+)
+
+const (
+	maxFirstBytes   = 16 // 记录首包的前 N 个字节
+	maxPacketRecord = 32 // 最多记录的数据包数量
+	nmapProbeDelay  = 5 * time.Second
+	epochTolerance  = 24 * time.Hour
+	scriptedGap     = 2 * time.Millisecond
+	javaUptimeLimit = 1 << 40 // Java Util.getMillis() 基于 nanoTime，通常远小于 Unix 毫秒时间戳
+)
+
+// Packet 观察到的数据包
+type Packet struct {
+	ID   int32
+	Size int
+	At   time.Duration // 相对连接建立的时间
+}
+
+// Result 分类结果
+type Result struct {
+	Client  string   // 客户端分类
+	Detail  string   // 细分（如具体的框架或模组加载器）
+	Signals []string // 命中的特征
+}
+
+// String 返回紧凑的特征摘要，写入蜜罐事件
+func (r Result) String() string {
+	if r.Detail == "" {
+		return strings.Join(r.Signals, ",")
+	}
+	return r.Detail + ";" + strings.Join(r.Signals, ",")
+}
+
+// Tracker 单个连接的指纹采集器
+// 陷阱世界中读取与记录事件可能位于不同协程，因此所有方法都加锁
+type Tracker struct {
+	mu         sync.Mutex
+	start      time.Time
+	firstBytes []byte
+	firstByte  time.Duration
+	totalBytes int
+	packets    []Packet
+
+	handshakeSeen bool
+	protocol      int
+	host          string
+	port          uint16
+	nextState     int
+	modLoader     string
+
+	pingSeen    bool
+	pingPayload int64
+	pingAt      time.Time
+
+	username string
+	zeroUUID bool
+	brand    string
+}
+
+// NewTracker 创建指纹采集器
+func NewTracker(start time.Time) *Tracker {
+	return &Tracker{
+		start:     start,
+		firstByte: -1,
+	}
+}
+
+// ObserveBytes 记录从客户端读取的原始字节
+func (t *Tracker) ObserveBytes(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(data) == 0 {
+		return
+	}
+	if t.firstByte < 0 {
+		t.firstByte = time.Since(t.start)
+	}
+	if len(t.firstBytes) < maxFirstBytes {
+		n := min(maxFirstBytes-len(t.firstBytes), len(data))
+		t.firstBytes = append(t.firstBytes, data[:n]...)
+	}
+	t.totalBytes += len(data)
+}
+
+// ObservePacket 记录解析出的数据包
+func (t *Tracker) ObservePacket(id int32, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.packets) >= maxPacketRecord {
+		return
+	}
+	t.packets = append(t.packets, Packet{ID: id, Size: size, At: time.Since(t.start)})
+}
+
+// ObserveHandshake 记录握手字段
+func (t *Tracker) ObserveHandshake(protocol int, host string, port uint16, nextState int, modLoader string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handshakeSeen = true
+	t.protocol = protocol
+	t.host = host
+	t.port = port
+	t.nextState = nextState
+	t.modLoader = modLoader
+}
+
+// ObservePing 记录 Ping 包载荷
+func (t *Tracker) ObservePing(payload int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pingSeen = true
+	t.pingPayload = payload
+	t.pingAt = time.Now()
+}
+
+// ObserveLogin 记录登录开始包
+func (t *Tracker) ObserveLogin(username string, id [16]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.username = username
+	t.zeroUUID = id == [16]byte{}
+}
+
+// ObserveBrand 记录客户端通过 minecraft:brand 声明的品牌
+func (t *Tracker) ObserveBrand(brand string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.brand = brand
+}
+
+// Reader 包装读取器，将读取到的字节交给采集器
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	return &trackingReader{r: r, t: t}
+}
+
+// trackingReader 记录读取字节的读取器
+type trackingReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+// Read 实现 io.Reader
+func (tr *trackingReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		tr.t.ObserveBytes(p[:n])
+	}
+	return n, err
+}
+
+// Classify 根据已采集的特征给出分类
+func (t *Tracker) Classify() Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var signals []string
+
+	// 1. 完全没有发送数据：典型的端口扫描/横幅抓取
+	if t.totalBytes == 0 {
+		return Result{Client: ClientBannerGrab, Signals: []string{"no_payload"}}
+	}
+
+	// 2. 首包不是 Minecraft 协议
+	if probe := t.detectForeignProbe(); probe != "" {
+		signals = append(signals, "probe="+probe)
+		if t.firstByte >= nmapProbeDelay {
+			// nmap 的 NULL 探测会先等待约 6 秒再发送其他探测
+			signals = append(signals, "delayed_probe")
+			return Result{Client: ClientNmap, Detail: probe, Signals: signals}
+		}
+		if probe == "http" || probe == "tls" || probe == "crlf" {
+			return Result{Client: ClientNmap, Detail: probe, Signals: signals}
+		}
+		return Result{Client: ClientBannerGrab, Detail: probe, Signals: signals}
+	}
+
+	if !t.handshakeSeen {
+		return Result{Client: ClientUnknown, Signals: append(signals, "no_handshake")}
+	}
+
+	signals = append(signals, fmt.Sprintf("proto=%d", t.protocol), "seq="+t.sequence())
+
+	// 3. 客户端品牌和模组加载器标记
+	switch brand := strings.ToLower(t.brand); {
+	case strings.Contains(brand, "fabric"), strings.Contains(brand, "quilt"):
+		return Result{Client: ClientFabric, Detail: t.brand, Signals: append(signals, "brand="+t.brand)}
+	case strings.Contains(brand, "forge"):
+		return Result{Client: ClientForge, Detail: t.brand, Signals: append(signals, "brand="+t.brand)}
+	}
+	if t.modLoader != "" {
+		return Result{Client: ClientForge, Detail: t.modLoader, Signals: append(signals, "marker="+t.modLoader)}
+	}
+
+	// 4. Ping 载荷的取值规律
+	pingKind := ""
+	if t.pingSeen {
+		pingKind = t.classifyPingPayload()
+		signals = append(signals, "ping="+pingKind)
+	}
+
+	scripted := t.isScripted()
+	if scripted {
+		signals = append(signals, "burst")
+	}
+	if t.zeroUUID {
+		signals = append(signals, "zero_uuid")
+	}
+
+	switch {
+	case t.protocol == 47 && t.nextState == 1 && pingKind == "random":
+		// mcstatus 默认使用 47 协议并发送随机 Ping 令牌
+		return Result{Client: ClientMCStatus, Signals: signals}
+	case pingKind == "epoch_ms":
+		// node-minecraft-protocol（mineflayer）使用 Date.now() 作为 Ping 载荷
+		return Result{Client: ClientBotFramework, Detail: "node-minecraft-protocol", Signals: signals}
+	case t.nextState == 2 && t.zeroUUID:
+		return Result{Client: ClientBotFramework, Signals: signals}
+	case t.protocol <= 0 || t.port == 0:
+		return Result{Client: ClientBannerGrab, Detail: "handshake_only", Signals: signals}
+	case pingKind == "java_uptime" || strings.EqualFold(t.brand, "vanilla"):
+		return Result{Client: ClientVanilla, Signals: signals}
+	case t.nextState == 1 && !t.pingSeen && scripted:
+		// 只取状态不发 Ping：大规模扫描器的常见行为
+		return Result{Client: ClientBannerGrab, Detail: "status_only", Signals: signals}
+	}
+
+	return Result{Client: ClientUnknown, Signals: signals}
+}
+
+// detectForeignProbe 识别非 Minecraft 协议的探测载荷
+func (t *Tracker) detectForeignProbe() string {
+	b := t.firstBytes
+	switch {
+	case len(b) == 0:
+		return ""
+	case b[0] == 0xFE:
+		// 1.6 及更早版本的旧式服务器列表 Ping
+		return ""
+	case b[0] == 0x16 && len(b) > 1 && b[1] == 0x03:
+		return "tls"
+	case bytes.HasPrefix(b, []byte("GET ")), bytes.HasPrefix(b, []byte("HEAD ")),
+		bytes.HasPrefix(b, []byte("POST ")), bytes.HasPrefix(b, []byte("OPTIONS ")):
+		return "http"
+	case bytes.HasPrefix(b, []byte("\r\n")), bytes.HasPrefix(b, []byte("\n")):
+		return "crlf"
+	case t.handshakeSeen:
+		return ""
+	case bytes.IndexFunc(b, func(r rune) bool { return r < 0x20 && r != '\r' && r != '\n' }) < 0:
+		return "text"
+	}
+	return ""
+}
+
+// classifyPingPayload 判断 Ping 载荷的生成方式
+func (t *Tracker) classifyPingPayload() string {
+	payload := t.pingPayload
+	now := t.pingAt
+	switch {
+	case payload > 0 && absInt64(payload-now.UnixMilli()) < epochTolerance.Milliseconds():
+		return "epoch_ms"
+	case payload > 0 && absInt64(payload-now.Unix()) < int64(epochTolerance.Seconds()):
+		return "epoch_s"
+	case payload >= 0 && payload < 256:
+		return "small"
+	case payload > 0 && payload < javaUptimeLimit:
+		return "java_uptime"
+	default:
+		return "random"
+	}
+}
+
+// isScripted 判断数据包是否以脚本化的速度连续发送
+func (t *Tracker) isScripted() bool {
+	if len(t.packets) < 2 {
+		return false
+	}
+	for i := 1; i < len(t.packets); i++ {
+		if t.packets[i].At-t.packets[i-1].At > scriptedGap {
+			return false
+		}
+	}
+	return true
+}
+
+// sequence 返回数据包 ID 序列的紧凑表示
+func (t *Tracker) sequence() string {
+	ids := make([]string, 0, len(t.packets))
+	for _, p := range t.packets {
+		ids = append(ids, fmt.Sprintf("%02x", p.ID))
+	}
+	return strings.Join(ids, ">")
+}
+
+// absInt64 取绝对值
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package fingerprint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name    string
+		observe func(tr *Tracker)
+		want    string
+	}{
+		{
+			name:    "无载荷",
+			observe: func(tr *Tracker) {},
+			want:    ClientBannerGrab,
+		},
+		{
+			name: "HTTP 探测",
+			observe: func(tr *Tracker) {
+				tr.ObserveBytes([]byte("GET / HTTP/1.0\r\n\r\n"))
+			},
+			want: ClientNmap,
+		},
+		{
+			name: "Forge 标记",
+			observe: func(tr *Tracker) {
+				tr.ObserveBytes([]byte{0x10, 0x00})
+				tr.ObserveHandshake(763, "mc.example.com", 25565, 1, "FML3")
+			},
+			want: ClientForge,
+		},
+		{
+			name: "Fabric 品牌",
+			observe: func(tr *Tracker) {
+				tr.ObserveBytes([]byte{0x10, 0x00})
+				tr.ObserveHandshake(765, "mc.example.com", 25565, 2, "")
+				tr.ObserveBrand("fabric")
+			},
+			want: ClientFabric,
+		},
+		{
+			name: "mcstatus 默认参数",
+			observe: func(tr *Tracker) {
+				tr.ObserveBytes([]byte{0x10, 0x00})
+				tr.ObserveHandshake(47, "mc.example.com", 25565, 1, "")
+				tr.ObservePing(6148914691236517205)
+			},
+			want: ClientMCStatus,
+		},
+		{
+			name: "node-minecraft-protocol Ping",
+			observe: func(tr *Tracker) {
+				tr.ObserveBytes([]byte{0x10, 0x00})
+				tr.ObserveHandshake(765, "mc.example.com", 25565, 1, "")
+				tr.ObservePing(time.Now().UnixMilli())
+			},
+			want: ClientBotFramework,
+		},
+		{
+			name: "原版客户端 Ping",
+			observe: func(tr *Tracker) {
+				tr.ObserveBytes([]byte{0x10, 0x00})
+				tr.ObserveHandshake(765, "mc.example.com", 25565, 1, "")
+				tr.ObservePing(83_456_789)
+			},
+			want: ClientVanilla,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTracker(time.Now())
+			tt.observe(tr)
+			if got := tr.Classify().Client; got != tt.want {
+				t.Errorf("Classify() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// Package geoip 基于本地 MaxMind / DB-IP 的 mmdb 文件查询 IP 的地理位置与 ASN
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+// Info IP 的地理位置与 ASN 信息，查不到的字段留空
+type Info struct {
+	Country     string `json:"country,omitempty"` // ISO 3166-1 国家代码
+	CountryName string `json:"country_name,omitempty"`
+	City        string `json:"city,omitempty"`
+	ASN         uint32 `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+// Location 国家与城市，如 "GB/London"
+func (i Info) Location() string {
+	if i.City == "" {
+		return i.Country
+	}
+	return i.Country + "/" + i.City
+}
+
+// IsZero 是否没有任何信息
+func (i Info) IsZero() bool {
+	return i == Info{}
+}
+
+// cityRecord GeoIP2/GeoLite2 City、Country 以及 DB-IP City Lite 的公共字段
+type cityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// asnRecord GeoLite2 ASN 与 DB-IP ASN Lite 的字段
+type asnRecord struct {
+	Number       uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// mmdbFile 可热替换的单个 mmdb 文件
+type mmdbFile struct {
+	path    string
+	reader  atomic.Pointer[maxminddb.Reader]
+	modTime time.Time
+}
+
+// load 读取整个文件到内存后解析；不使用 mmap，替换后旧的 Reader 由 GC 回收，查询中无需加锁
+func (f *mmdbFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("读取 mmdb 文件失败: %w", err)
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("读取 mmdb 文件失败: %w", err)
+	}
+	reader, err := maxminddb.OpenBytes(data)
+	if err != nil {
+		return fmt.Errorf("解析 mmdb 文件 %s 失败: %w", f.path, err)
+	}
+	f.reader.Store(reader)
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// changed 文件修改时间是否变化
+func (f *mmdbFile) changed() bool {
+	info, err := os.Stat(f.path)
+	return err == nil && !info.ModTime().Equal(f.modTime)
+}
+
+// Database GeoIP/ASN 查询，结果按 IP 缓存，文件变化时自动重新加载
+type Database struct {
+	city      *mmdbFile
+	asn       *mmdbFile
+	logger    zerolog.Logger
+	cacheSize int
+
+	mu    sync.RWMutex
+	cache map[netip.Addr]Info
+}
+
+// NewDatabase 加载配置的 mmdb 文件，未启用时返回 nil
+func NewDatabase(cfg *config.GeoIPConfig, logger zerolog.Logger) (*Database, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.CityPath == "" && cfg.ASNPath == "" {
+		return nil, fmt.Errorf("至少需要配置 city_path 或 asn_path")
+	}
+
+	db := &Database{
+		logger:    logger.With().Str("component", "geoip").Logger(),
+		cacheSize: cfg.CacheSize,
+		cache:     make(map[netip.Addr]Info),
+	}
+	if cfg.CityPath != "" {
+		db.city = &mmdbFile{path: cfg.CityPath}
+	}
+	if cfg.ASNPath != "" {
+		db.asn = &mmdbFile{path: cfg.ASNPath}
+	}
+
+	for _, f := range db.files() {
+		if err := f.load(); err != nil {
+			return nil, err
+		}
+		db.logger.Info().
+			Str("path", f.path).
+			Str("type", f.reader.Load().Metadata.DatabaseType).
+			Msg("加载 GeoIP 数据库")
+	}
+	return db, nil
+}
+
+// files 已配置的 mmdb 文件
+func (db *Database) files() []*mmdbFile {
+	var files []*mmdbFile
+	for _, f := range []*mmdbFile{db.city, db.asn} {
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Watch 定期检查 mmdb 文件的修改时间，变化时重新加载并清空缓存
+func (db *Database) Watch(ctx context.Context, interval time.Duration) {
+	if db == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded := false
+			for _, f := range db.files() {
+				if !f.changed() {
+					continue
+				}
+				if err := f.load(); err != nil {
+					db.logger.Error().Err(err).Msg("重新加载 GeoIP 数据库失败，继续使用旧数据")
+					continue
+				}
+				db.logger.Info().Str("path", f.path).Msg("重新加载 GeoIP 数据库")
+				reloaded = true
+			}
+			if reloaded {
+				db.mu.Lock()
+				clear(db.cache)
+				db.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Lookup 查询 IP 的地理位置与 ASN
+// db 为 nil 或地址无法解析时返回空 Info，方便调用方在未启用时直接调用
+func (db *Database) Lookup(ip string) Info {
+	if db == nil {
+		return Info{}
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Info{}
+	}
+	addr = addr.Unmap()
+
+	db.mu.RLock()
+	info, ok := db.cache[addr]
+	db.mu.RUnlock()
+	if ok {
+		return info
+	}
+
+	info = db.lookup(addr)
+
+	db.mu.Lock()
+	// 缓存写满时整体清空，攻击流量的 IP 集中，很快会重新热起来
+	if len(db.cache) >= db.cacheSize {
+		clear(db.cache)
+	}
+	db.cache[addr] = info
+	db.mu.Unlock()
+	return info
+}
+
+// lookup 直接查询 mmdb
+func (db *Database) lookup(addr netip.Addr) Info {
+	var info Info
+	if db.city != nil {
+		var rec cityRecord
+		if err := db.city.reader.Load().Lookup(addr).Decode(&rec); err == nil {
+			info.Country = rec.Country.ISOCode
+			info.CountryName = rec.Country.Names["en"]
+			info.City = rec.City.Names["en"]
+		}
+	}
+	if db.asn != nil {
+		var rec asnRecord
+		if err := db.asn.reader.Load().Lookup(addr).Decode(&rec); err == nil {
+			info.ASN = rec.Number
+			info.ASOrg = strings.TrimSpace(rec.Organization)
+		}
+	}
+	return info
+}
+
+// Enrich 为蜜罐事件补充地理位置与 ASN，注册为 HoneypotLogger 的补充函数
+func (db *Database) Enrich(event *logger.HoneypotEvent) {
+	if db == nil || event.ClientIP == "" {
+		return
+	}
+	info := db.Lookup(event.ClientIP)
+	event.GeoLocation = info.Location()
+	event.Country = info.Country
+	event.ASN = info.ASN
+	event.ASOrg = info.ASOrg
+}
@@ -0,0 +1,99 @@
+package geoip
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+// testdata 中的 mmdb 由 mmdbwriter 生成:
+//
+//	city.mmdb: 81.2.69.0/24 GB/London, 192.0.2.0/24 US（无城市）, 2001:db8::/32 JP/Tokyo
+//	asn.mmdb:  81.2.69.0/24 AS20712, 192.0.2.0/24 AS64496
+func newTestDatabase(t *testing.T, cityPath string) *Database {
+	t.Helper()
+	db, err := NewDatabase(&config.GeoIPConfig{
+		Enabled:   true,
+		CityPath:  cityPath,
+		ASNPath:   "testdata/asn.mmdb",
+		CacheSize: 16,
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	return db
+}
+
+func TestLookup(t *testing.T) {
+	db := newTestDatabase(t, "testdata/city.mmdb")
+
+	tests := []struct {
+		ip   string
+		want Info
+	}{
+		{"81.2.69.142", Info{Country: "GB", CountryName: "United Kingdom", City: "London", ASN: 20712, ASOrg: "Andrews & Arnold Ltd"}},
+		{"::ffff:192.0.2.7", Info{Country: "US", CountryName: "United States", ASN: 64496, ASOrg: "Example Hosting"}},
+		{"2001:db8::1", Info{Country: "JP", CountryName: "Japan", City: "Tokyo"}},
+		{"198.51.100.1", Info{}},
+		{"not-an-ip", Info{}},
+	}
+	for _, tt := range tests {
+		if got := db.Lookup(tt.ip); got != tt.want {
+			t.Errorf("Lookup(%q) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+
+	event := &logger.HoneypotEvent{ClientIP: "192.0.2.7"}
+	db.Enrich(event)
+	if event.GeoLocation != "US" || event.Country != "US" || event.ASN != 64496 {
+		t.Errorf("Enrich() = %+v", event)
+	}
+
+	var nilDB *Database
+	if got := nilDB.Lookup("81.2.69.142"); !got.IsZero() {
+		t.Errorf("nil Database Lookup() = %+v", got)
+	}
+}
+
+func TestWatchReloadsChangedFile(t *testing.T) {
+	// 先用 ASN 库冒充城市库，替换为真正的城市库后应能查到城市
+	cityPath := filepath.Join(t.TempDir(), "city.mmdb")
+	copyFile(t, "testdata/asn.mmdb", cityPath)
+	db := newTestDatabase(t, cityPath)
+	if got := db.Lookup("81.2.69.142"); got.City != "" {
+		t.Fatalf("替换前 City = %q, want 空", got.City)
+	}
+
+	copyFile(t, "testdata/city.mmdb", cityPath)
+	os.Chtimes(cityPath, time.Now(), time.Now().Add(time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Watch(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for db.Lookup("81.2.69.142").City != "London" {
+		if time.Now().After(deadline) {
+			t.Fatal("文件替换后未重新加载")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
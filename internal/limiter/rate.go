@@ -0,0 +1,45 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// defaultRateHalfLife 未配置 delay.rate_half_life 时使用的半衰期
+const defaultRateHalfLife = 10 * time.Second
+
+// rateEstimator 指数加权移动平均（EWMA）的请求速率估计，单位为次/秒
+// 每次请求贡献 1/tau，此后按 e^(-t/tau) 衰减，越近的请求权重越高；没有新请求时速率逐渐回落
+// 不加锁，由调用方保护
+type rateEstimator struct {
+	rate float64
+	last time.Time
+}
+
+// observe 记录 now 时刻的一次请求，tau 为时间常数（秒）
+func (e *rateEstimator) observe(now time.Time, tau float64) {
+	e.rate = e.at(now, tau) + 1/tau
+	if now.After(e.last) {
+		e.last = now
+	}
+}
+
+// at 返回 now 时刻的速率估计
+func (e *rateEstimator) at(now time.Time, tau float64) float64 {
+	if e.last.IsZero() {
+		return 0
+	}
+	dt := now.Sub(e.last).Seconds()
+	if dt <= 0 {
+		return e.rate
+	}
+	return e.rate * math.Exp(-dt/tau)
+}
+
+// rateTau 由半衰期换算时间常数（秒）
+func rateTau(halfLife time.Duration) float64 {
+	if halfLife <= 0 {
+		halfLife = defaultRateHalfLife
+	}
+	return halfLife.Seconds() / math.Ln2
+}
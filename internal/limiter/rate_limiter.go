@@ -1,56 +1,86 @@
 package limiter
 
 import (
+	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
-	"golang.org/x/time/rate"
 
 	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/geoip"
 )
 
-// RateLimiter 限流器
+// RateLimiter 限流器，限流算法由 rate_limit.strategy 选择
 type RateLimiter struct {
 	config        *config.Config
 	logger        zerolog.Logger
-	globalLimiter *rate.Limiter
-	ipLimiters    sync.Map // map[string]*IPLimiterInfo
+	newBucket     newBucketFunc
+	globalLimiter Bucket
+	ipLimiters    *lruStore[*IPLimiterInfo] // 容量有限，超过 max_tracked_ips 时淘汰最久未访问的 IP
+	sketch        *windowSketch             // 所有 IP 的近似请求数，状态表已满时补充检查
+	tiers         []*tier                   // 启用的网段和 ASN 限流层级
 	mu            sync.RWMutex
+	geo           *geoip.Database // 可选，为 IP 统计补充地理位置与 ASN
+	onReject      func(ip string) // 可选，IP 限流触发时调用
+	rateTau       float64         // 速率估计的时间常数（秒）
 
 	// 统计信息
 	globalRequests int64
 	totalRequests  int64
+	approximate    atomic.Int64  // 状态表已满时由近似计数拒绝的请求数
+	globalRate     rateEstimator // 最近的全局请求速率（含被限流的请求）
 	startTime      time.Time
 }
 
 // IPLimiterInfo IP 限流器信息
 type IPLimiterInfo struct {
-	Limiter      *rate.Limiter
+	Limiter      Bucket
 	RequestCount int64
 	FirstRequest time.Time
 	LastRequest  time.Time
+	rate         rateEstimator // 最近的请求速率（含被限流的请求）
 	mu           sync.RWMutex
 }
 
-// NewRateLimiter 创建限流器
-func NewRateLimiter(cfg *config.Config, logger zerolog.Logger) *RateLimiter {
-	return &RateLimiter{
-		config: cfg,
-		logger: logger.With().Str("component", "rate_limiter").Logger(),
-		globalLimiter: rate.NewLimiter(
-			rate.Limit(cfg.RateLimit.GlobalLimit),
-			cfg.RateLimit.GlobalLimit,
-		),
-		startTime: time.Now(),
+// NewRateLimiter 创建限流器，geo 为 nil 时不补充地理信息
+func NewRateLimiter(cfg *config.Config, logger zerolog.Logger, geo *geoip.Database) (*RateLimiter, error) {
+	newBucket, ok := strategies[cfg.RateLimit.Strategy]
+	if !ok {
+		return nil, fmt.Errorf("不支持的限流算法: %s", cfg.RateLimit.Strategy)
 	}
+	return &RateLimiter{
+		config:        cfg,
+		geo:           geo,
+		logger:        logger.With().Str("component", "rate_limiter").Logger(),
+		newBucket:     newBucket,
+		globalLimiter: newBucket(cfg.RateLimit.GlobalLimit, cfg.RateLimit.Window),
+		ipLimiters:    newLRUStore[*IPLimiterInfo](maxTracked(cfg.RateLimit.MaxTrackedIPs)),
+		sketch:        newWindowSketch(cfg.RateLimit.Window),
+		tiers:         newTiers(&cfg.RateLimit, geo, maxTracked(cfg.RateLimit.MaxTrackedIPs)),
+		rateTau:       rateTau(cfg.Delay.RateHalfLife),
+		startTime:     time.Now(),
+	}, nil
+}
+
+// SetRejectHook 设置 IP 限流触发时的回调（如自动封禁计数），需在处理连接之前调用
+// 全局限流不是单个 IP 造成的，不触发回调
+func (rl *RateLimiter) SetRejectHook(hook func(ip string)) {
+	rl.onReject = hook
 }
 
 // Allow 检查是否允许请求
 func (rl *RateLimiter) Allow(ip string) bool {
+	now := time.Now()
+	recent := rl.sketch.add(ip, now)
+	ipLimiter := rl.getOrCreateIPLimiter(ip)
+	matches := rl.matchTiers(ip)
+	rl.observe(now, ipLimiter, matches)
+
 	// 检查全局限流
-	if !rl.globalLimiter.Allow() {
+	if !rl.globalLimiter.Allow(now) {
 		rl.logger.Debug().
 			Str("ip", ip).
 			Msg("全局限流触发")
@@ -58,14 +88,43 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	}
 
 	// 检查 IP 限流
-	ipLimiter := rl.getOrCreateIPLimiter(ip)
-	if !ipLimiter.Limiter.Allow() {
+	if !ipLimiter.Limiter.Allow(now) {
 		rl.logger.Debug().
 			Str("ip", ip).
 			Msg("IP 限流触发")
+		if rl.onReject != nil {
+			rl.onReject(ip)
+		}
 		return false
 	}
 
+	// 状态表已满时 IP 的状态可能刚被淘汰后重建、丢失了之前的计数，再按近似计数检查一次
+	if rl.ipLimiters.full() && recent > float64(rl.config.RateLimit.IPLimit) {
+		rl.approximate.Add(1)
+		rl.logger.Debug().
+			Str("ip", ip).
+			Float64("recent", recent).
+			Msg("IP 限流触发（近似计数）")
+		if rl.onReject != nil {
+			rl.onReject(ip)
+		}
+		return false
+	}
+
+	// 检查网段和 ASN 限流，与全局限流一样不是单个 IP 造成的，不触发回调
+	// 放在 IP 限流之后，被 IP 限流拒绝的请求不消耗网段和 ASN 的限额
+	for _, m := range matches {
+		if !m.entry.limiter.Allow(now) {
+			m.tier.rejected.Add(1)
+			rl.logger.Debug().
+				Str("ip", ip).
+				Str("tier", m.tier.name).
+				Str("key", m.key).
+				Msg("聚合限流触发")
+			return false
+		}
+	}
+
 	// 更新统计信息
 	rl.updateStats(ip, ipLimiter)
 
@@ -74,14 +133,11 @@ func (rl *RateLimiter) Allow(ip string) bool {
 
 // CalculateDelay 计算延迟时间
 func (rl *RateLimiter) CalculateDelay(ip string) time.Duration {
-	// 获取 IP 限流器信息
-	ipLimiter := rl.getOrCreateIPLimiter(ip)
-
-	// 计算 IP 频率因子
-	ipFrequency := rl.calculateIPFrequency(ipLimiter)
-
-	// 计算全局负载因子
-	globalLoad := rl.calculateGlobalLoad()
+	// 按最近的请求速率计算 IP 频率因子和全局负载因子
+	now := time.Now()
+	ipFrequency := rl.calculateIPFrequency(ip, now)
+	globalLoad := rl.calculateGlobalLoad(now)
+	tierPenalty := rl.calculateTierPenalty(ip, now)
 
 	// 改进的延迟计算公式
 	baseDelay := float64(rl.config.Delay.BaseDelay.Nanoseconds())
@@ -99,7 +155,7 @@ func (rl *RateLimiter) CalculateDelay(ip string) time.Duration {
 	)
 
 	// 总延迟
-	totalDelay := time.Duration(baseDelay + ipPenalty + globalPenalty)
+	totalDelay := time.Duration(baseDelay+ipPenalty+globalPenalty) + tierPenalty
 
 	rl.logger.Debug().
 		Str("ip", ip).
@@ -108,77 +164,133 @@ func (rl *RateLimiter) CalculateDelay(ip string) time.Duration {
 		Dur("base_delay", rl.config.Delay.BaseDelay).
 		Dur("ip_penalty", time.Duration(ipPenalty)).
 		Dur("global_penalty", time.Duration(globalPenalty)).
+		Dur("tier_penalty", tierPenalty).
 		Dur("total_delay", totalDelay).
 		Msg("计算延迟")
 
 	return totalDelay
 }
 
-// getOrCreateIPLimiter 获取或创建 IP 限流器
-func (rl *RateLimiter) getOrCreateIPLimiter(ip string) *IPLimiterInfo {
-	if value, ok := rl.ipLimiters.Load(ip); ok {
-		return value.(*IPLimiterInfo)
+// maxTracked 未配置 max_tracked_ips 时使用默认容量
+func maxTracked(n int) int {
+	if n <= 0 {
+		return 100000
 	}
+	return n
+}
+
+// getOrCreateIPLimiter 获取或创建 IP 限流器，状态表已满时淘汰最久未访问的 IP
+func (rl *RateLimiter) getOrCreateIPLimiter(ip string) *IPLimiterInfo {
+	ipLimiter, created := rl.ipLimiters.getOrCreate(ip, func() *IPLimiterInfo {
+		now := time.Now()
+		return &IPLimiterInfo{
+			Limiter:      rl.newBucket(rl.config.RateLimit.IPLimit, rl.config.RateLimit.Window),
+			FirstRequest: now,
+			LastRequest:  now,
+		}
+	})
 
-	// 创建新的 IP 限流器
-	ipLimiter := &IPLimiterInfo{
-		Limiter: rate.NewLimiter(
-			rate.Limit(rl.config.RateLimit.IPLimit),
-			rl.config.RateLimit.IPLimit,
-		),
-		FirstRequest: time.Now(),
-		LastRequest:  time.Now(),
+	if created {
+		rl.logger.Debug().
+			Str("ip", ip).
+			Msg("创建新的 IP 限流器")
 	}
 
-	// 尝试存储，如果已存在则使用已存在的
-	if actual, loaded := rl.ipLimiters.LoadOrStore(ip, ipLimiter); loaded {
-		return actual.(*IPLimiterInfo)
+	return ipLimiter
+}
+
+// tierMatch IP 在某个聚合限流层级中所属的键
+type tierMatch struct {
+	tier  *tier
+	key   string
+	entry *tierEntry
+}
+
+// matchTiers 返回 IP 所属的网段和 ASN 限流状态
+func (rl *RateLimiter) matchTiers(ip string) []tierMatch {
+	var matches []tierMatch
+	for _, t := range rl.tiers {
+		if key, ok := t.key(ip); ok {
+			matches = append(matches, tierMatch{tier: t, key: key, entry: t.entry(key, rl.newBucket, rl.config.RateLimit.Window)})
+		}
 	}
+	return matches
+}
 
-	rl.logger.Debug().
-		Str("ip", ip).
-		Msg("创建新的 IP 限流器")
+// observe 记录一次请求到 IP、所属网段和 ASN 以及全局的速率估计
+func (rl *RateLimiter) observe(now time.Time, ipLimiter *IPLimiterInfo, matches []tierMatch) {
+	ipLimiter.mu.Lock()
+	ipLimiter.rate.observe(now, rl.rateTau)
+	ipLimiter.mu.Unlock()
 
-	return ipLimiter
+	for _, m := range matches {
+		m.entry.mu.Lock()
+		m.entry.rate.observe(now, rl.rateTau)
+		m.entry.mu.Unlock()
+	}
+
+	rl.mu.Lock()
+	rl.globalRate.observe(now, rl.rateTau)
+	rl.mu.Unlock()
 }
 
-// calculateIPFrequency 计算 IP 频率因子
-func (rl *RateLimiter) calculateIPFrequency(ipLimiter *IPLimiterInfo) float64 {
+// ipRate 返回 IP 最近的请求速率（次/秒），已被淘汰的 IP 按近似计数估算
+func (rl *RateLimiter) ipRate(ip string, now time.Time) float64 {
+	ipLimiter, ok := rl.ipLimiters.peek(ip)
+	if !ok {
+		return rl.sketch.count(ip, now) / rl.config.RateLimit.Window.Seconds()
+	}
 	ipLimiter.mu.RLock()
 	defer ipLimiter.mu.RUnlock()
+	return ipLimiter.rate.at(now, rl.rateTau)
+}
 
-	// 计算时间窗口内的请求频率
-	duration := time.Since(ipLimiter.FirstRequest)
-	if duration == 0 {
-		return 1.0
-	}
+// globalRequestRate 返回最近的全局请求速率（次/秒）
+func (rl *RateLimiter) globalRequestRate(now time.Time) float64 {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.globalRate.at(now, rl.rateTau)
+}
 
-	// 每秒请求数
-	requestsPerSecond := float64(ipLimiter.RequestCount) / duration.Seconds()
+// perSecond 将每个窗口的限额换算为每秒
+func (rl *RateLimiter) perSecond(limit int) float64 {
+	return float64(limit) / rl.config.RateLimit.Window.Seconds()
+}
 
-	// 频率因子 = 实际频率 / 限制频率
-	frequencyFactor := requestsPerSecond / float64(rl.config.RateLimit.IPLimit)
+// calculateIPFrequency 计算 IP 频率因子
+func (rl *RateLimiter) calculateIPFrequency(ip string, now time.Time) float64 {
+	// 频率因子 = 最近的请求速率 / 限制速率
+	frequencyFactor := rl.ipRate(ip, now) / rl.perSecond(rl.config.RateLimit.IPLimit)
 
 	// 应用配置的频率因子
 	return math.Max(1.0, frequencyFactor*rl.config.Delay.IPFrequencyFactor)
 }
 
-// calculateGlobalLoad 计算全局负载因子
-func (rl *RateLimiter) calculateGlobalLoad() float64 {
-	rl.mu.RLock()
-	defer rl.mu.RUnlock()
-
-	// 计算全局请求频率
-	duration := time.Since(rl.startTime)
-	if duration == 0 {
-		return 1.0
+// calculateTierPenalty 计算 IP 所属网段和 ASN 的惩罚延迟之和
+func (rl *RateLimiter) calculateTierPenalty(ip string, now time.Time) time.Duration {
+	var total time.Duration
+	for _, t := range rl.tiers {
+		// 只读取已有的状态，计算延迟不创建键，也不改变淘汰顺序
+		key, ok := t.key(ip)
+		if !ok {
+			continue
+		}
+		e, ok := t.entries.peek(key)
+		if !ok {
+			continue
+		}
+		e.mu.Lock()
+		rate := e.rate.at(now, rl.rateTau)
+		e.mu.Unlock()
+		total += t.penalty(rate, rl.perSecond(t.config.Limit), rl.config.Delay.BaseDelay)
 	}
+	return total
+}
 
-	// 每秒请求数
-	requestsPerSecond := float64(rl.totalRequests) / duration.Seconds()
-
-	// 负载因子 = 实际频率 / 限制频率
-	loadFactor := requestsPerSecond / float64(rl.config.RateLimit.GlobalLimit)
+// calculateGlobalLoad 计算全局负载因子
+func (rl *RateLimiter) calculateGlobalLoad(now time.Time) float64 {
+	// 负载因子 = 最近的全局请求速率 / 限制速率
+	loadFactor := rl.globalRequestRate(now) / rl.perSecond(rl.config.RateLimit.GlobalLimit)
 
 	// 应用配置的负载因子
 	return math.Max(1.0, loadFactor*rl.config.Delay.GlobalLoadFactor)
@@ -206,10 +318,7 @@ func (rl *RateLimiter) CleanupExpiredLimiters() {
 	now := time.Now()
 	expiredIPs := make([]string, 0)
 
-	rl.ipLimiters.Range(func(key, value any) bool {
-		ip := key.(string)
-		ipLimiter := value.(*IPLimiterInfo)
-
+	rl.ipLimiters.rangeAll(func(ip string, ipLimiter *IPLimiterInfo) bool {
 		ipLimiter.mu.RLock()
 		lastRequest := ipLimiter.LastRequest
 		ipLimiter.mu.RUnlock()
@@ -224,7 +333,7 @@ func (rl *RateLimiter) CleanupExpiredLimiters() {
 
 	// 删除过期的限流器
 	for _, ip := range expiredIPs {
-		rl.ipLimiters.Delete(ip)
+		rl.ipLimiters.delete(ip)
 	}
 
 	if len(expiredIPs) > 0 {
@@ -232,6 +341,15 @@ func (rl *RateLimiter) CleanupExpiredLimiters() {
 			Int("count", len(expiredIPs)).
 			Msg("清理过期的 IP 限流器")
 	}
+
+	for _, t := range rl.tiers {
+		if removed := t.cleanup(now, rl.config.RateLimit.CleanupInterval); removed > 0 {
+			rl.logger.Debug().
+				Str("tier", t.name).
+				Int("count", removed).
+				Msg("清理过期的聚合限流器")
+		}
+	}
 }
 
 // StartCleanupRoutine 启动清理协程
@@ -252,72 +370,119 @@ func (rl *RateLimiter) GetStats() map[string]any {
 	defer rl.mu.RUnlock()
 
 	// 计算活跃 IP 数量
-	activeIPs := 0
-	rl.ipLimiters.Range(func(key, value any) bool {
-		activeIPs++
-		return true
-	})
+	activeIPs := rl.ipLimiters.len()
 
 	// 计算平均请求频率
-	duration := time.Since(rl.startTime)
+	now := time.Now()
+	duration := now.Sub(rl.startTime)
 	avgRequestsPerSecond := float64(rl.totalRequests) / duration.Seconds()
 
-	return map[string]any{
+	stats := map[string]any{
 		"total_requests":          rl.totalRequests,
 		"global_requests":         rl.globalRequests,
 		"active_ip_count":         activeIPs,
+		"max_tracked_ips":         rl.ipLimiters.capacity,
+		"evicted_ips":             rl.ipLimiters.evicted.Load(),
+		"approximate_rejections":  rl.approximate.Load(),
 		"avg_requests_per_second": avgRequestsPerSecond,
+		"requests_per_second":     rl.globalRate.at(now, rl.rateTau),
 		"uptime":                  duration,
 		"global_limit":            rl.config.RateLimit.GlobalLimit,
 		"ip_limit":                rl.config.RateLimit.IPLimit,
+		"strategy":                rl.config.RateLimit.Strategy,
 	}
+	for _, t := range rl.tiers {
+		stats[t.name] = t.stats()
+	}
+	return stats
 }
 
 // GetIPStats 获取指定 IP 的统计信息
 func (rl *RateLimiter) GetIPStats(ip string) map[string]any {
-	if value, ok := rl.ipLimiters.Load(ip); ok {
-		ipLimiter := value.(*IPLimiterInfo)
+	stats := rl.getIPStats(ip)
+	if info := rl.geo.Lookup(ip); !info.IsZero() {
+		stats["geo"] = info
+	}
+	return stats
+}
+
+// getIPStats 限流器记录的 IP 统计
+func (rl *RateLimiter) getIPStats(ip string) map[string]any {
+	now := time.Now()
+	if ipLimiter, ok := rl.ipLimiters.peek(ip); ok {
 		ipLimiter.mu.RLock()
 		defer ipLimiter.mu.RUnlock()
 
-		duration := time.Since(ipLimiter.FirstRequest)
-		requestsPerSecond := float64(ipLimiter.RequestCount) / duration.Seconds()
-
-		return map[string]any{
-			"ip":                  ip,
-			"request_count":       ipLimiter.RequestCount,
-			"first_request":       ipLimiter.FirstRequest,
-			"last_request":        ipLimiter.LastRequest,
-			"duration":            duration,
-			"requests_per_second": requestsPerSecond,
-			"current_tokens":      ipLimiter.Limiter.Tokens(),
+		duration := now.Sub(ipLimiter.FirstRequest)
+		avgRequestsPerSecond := float64(ipLimiter.RequestCount) / duration.Seconds()
+
+		stats := map[string]any{
+			"ip":                      ip,
+			"request_count":           ipLimiter.RequestCount,
+			"first_request":           ipLimiter.FirstRequest,
+			"last_request":            ipLimiter.LastRequest,
+			"duration":                duration,
+			"avg_requests_per_second": avgRequestsPerSecond,
+			"requests_per_second":     ipLimiter.rate.at(now, rl.rateTau),
+			"current_tokens":          ipLimiter.Limiter.Remaining(now),
+		}
+		for _, t := range rl.tiers {
+			key, ok := t.key(ip)
+			if !ok {
+				continue
+			}
+			tierStats := map[string]any{"key": key}
+			if e, ok := t.entries.peek(key); ok {
+				e.mu.Lock()
+				tierStats["requests_per_second"] = e.rate.at(now, rl.rateTau)
+				e.mu.Unlock()
+				tierStats["current_tokens"] = e.limiter.Remaining(now)
+			}
+			stats[t.name] = tierStats
 		}
+		return stats
 	}
 
+	// 已被淘汰的 IP 只有近似计数
 	return map[string]any{
-		"ip":    ip,
-		"found": false,
+		"ip":                  ip,
+		"found":               false,
+		"requests_per_second": rl.ipRate(ip, now),
 	}
 }
 
-// GetIPFrequency 获取IP访问频率
-func (rl *RateLimiter) GetIPFrequency(ip string) float64 {
-	if limiterInfo, ok := rl.ipLimiters.Load(ip); ok {
-		ipLimiter := limiterInfo.(*IPLimiterInfo)
+// IPState 限流器记录的单个 IP 状态快照
+type IPState struct {
+	IP           string
+	RequestCount int64
+	FirstRequest time.Time
+	LastRequest  time.Time
+}
+
+// IPStates 返回当前跟踪的所有 IP 的状态快照
+func (rl *RateLimiter) IPStates() []IPState {
+	var states []IPState
+	rl.ipLimiters.rangeAll(func(ip string, ipLimiter *IPLimiterInfo) bool {
 		ipLimiter.mu.RLock()
-		defer ipLimiter.mu.RUnlock()
+		states = append(states, IPState{
+			IP:           ip,
+			RequestCount: ipLimiter.RequestCount,
+			FirstRequest: ipLimiter.FirstRequest,
+			LastRequest:  ipLimiter.LastRequest,
+		})
+		ipLimiter.mu.RUnlock()
+		return true
+	})
+	return states
+}
 
-		duration := time.Since(ipLimiter.FirstRequest)
-		if duration.Seconds() == 0 {
-			return 0
-		}
-		return float64(ipLimiter.RequestCount) / duration.Seconds()
-	}
-	return 0
+// GetIPFrequency 获取IP最近的访问频率（次/秒），按 delay.rate_half_life 衰减
+func (rl *RateLimiter) GetIPFrequency(ip string) float64 {
+	return rl.ipRate(ip, time.Now())
 }
 
 // IsCircuitBreakerTriggered 检查熔断器是否触发
 func (rl *RateLimiter) IsCircuitBreakerTriggered() bool {
-	// 简单的熔断逻辑：如果全局限流器的令牌数为 0，则触发熔断
-	return rl.globalLimiter.Tokens() == 0
+	// 简单的熔断逻辑：如果全局限流器已无法再允许请求，则触发熔断
+	return rl.globalLimiter.Remaining(time.Now()) < 1
 }
@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/limiter/strategy"
+)
+
+func TestRateEstimator(t *testing.T) {
+	tau := rateTau(10 * time.Second)
+	start := time.Unix(1_700_000_000, 0)
+
+	// 稳定的每秒 5 次请求，估计值收敛到 5
+	var e rateEstimator
+	now := start
+	for range 5 * 120 {
+		now = now.Add(200 * time.Millisecond)
+		e.observe(now, tau)
+	}
+	if got := e.at(now, tau); math.Abs(got-5) > 0.5 {
+		t.Errorf("稳定速率 = %.2f, want 约 5", got)
+	}
+
+	// 停止请求后每个半衰期减半
+	if got, want := e.at(now.Add(10*time.Second), tau), e.at(now, tau)/2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("一个半衰期后速率 = %.3f, want %.3f", got, want)
+	}
+}
+
+// TestCalculateDelayRecent 长时间空闲后的突发请求也应立即提高延迟，停止后延迟回落
+func TestCalculateDelayRecent(t *testing.T) {
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{Strategy: strategy.TokenBucket, IPLimit: 5, GlobalLimit: 100, Window: time.Second},
+		Delay: config.DelayConfig{
+			BaseDelay:            100 * time.Millisecond,
+			MaxIPPenalty:         5 * time.Second,
+			MaxGlobalPenalty:     2 * time.Second,
+			IPFrequencyFactor:    1.5,
+			GlobalLoadFactor:     1.2,
+			IPRateMultiplier:     2,
+			GlobalRateMultiplier: 1.5,
+			RateHalfLife:         time.Second,
+		},
+	}
+	rl := newTestLimiter(t, cfg, nil)
+	// 模拟服务器已运行一天
+	rl.startTime = time.Now().Add(-24 * time.Hour)
+
+	idle := rl.CalculateDelay("192.0.2.1")
+	for range 50 {
+		rl.Allow("192.0.2.1")
+	}
+	flood := rl.CalculateDelay("192.0.2.1")
+	if flood <= idle {
+		t.Errorf("突发后延迟 %s 应大于空闲时的 %s", flood, idle)
+	}
+	if rate := rl.GetIPFrequency("192.0.2.1"); rate < 20 {
+		t.Errorf("GetIPFrequency() = %.2f, want >= 20", rate)
+	}
+
+	// 估计值随时间衰减，模拟经过 10 个半衰期
+	info, _ := rl.ipLimiters.peek("192.0.2.1")
+	info.rate.last = info.rate.last.Add(-10 * time.Second)
+	rl.globalRate.last = rl.globalRate.last.Add(-10 * time.Second)
+	if recovered := rl.CalculateDelay("192.0.2.1"); recovered != idle {
+		t.Errorf("衰减后延迟 %s, want %s", recovered, idle)
+	}
+}
@@ -0,0 +1,92 @@
+package limiter
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+// 计数草图参数：4 行，每行 65536 个计数器，两代共占用 2MB
+const (
+	sketchDepth = 4
+	sketchWidth = 1 << 16
+)
+
+// windowSketch 按窗口滚动的 count-min 草图，以固定内存估算任意多个 IP 最近一个窗口内的请求数
+// 与滑动窗口计数一样按上一个窗口已过去的比例加权；哈希冲突只会使估计值偏大
+type windowSketch struct {
+	mu       sync.Mutex
+	seed     maphash.Seed
+	window   int64
+	start    int64    // 当前窗口起点
+	current  []uint32 // sketchDepth 行，每行 sketchWidth 个计数器
+	previous []uint32
+}
+
+func newWindowSketch(window time.Duration) *windowSketch {
+	return &windowSketch{
+		seed:     maphash.MakeSeed(),
+		window:   int64(window),
+		current:  make([]uint32, sketchDepth*sketchWidth),
+		previous: make([]uint32, sketchDepth*sketchWidth),
+	}
+}
+
+// indexes 计算键在每一行中的位置
+func (s *windowSketch) indexes(key string) [sketchDepth]int {
+	h := maphash.String(s.seed, key)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	var idx [sketchDepth]int
+	for i := range idx {
+		idx[i] = i*sketchWidth + int((h1+uint32(i)*h2)%sketchWidth)
+	}
+	return idx
+}
+
+// roll 滚动到 now 所在的窗口，返回上一个窗口的权重，调用方需持有锁
+func (s *windowSketch) roll(now int64) float64 {
+	start := now - now%s.window
+	switch {
+	case start == s.start:
+	case start-s.start == s.window:
+		s.previous, s.current = s.current, s.previous
+		clear(s.current)
+	default:
+		clear(s.previous)
+		clear(s.current)
+	}
+	s.start = start
+	return 1 - float64(now-start)/float64(s.window)
+}
+
+// estimate 按各行中的最小值估算，调用方需持有锁
+func (s *windowSketch) estimate(idx [sketchDepth]int, weight float64) float64 {
+	est := math.Inf(1)
+	for _, i := range idx {
+		est = math.Min(est, float64(s.previous[i])*weight+float64(s.current[i]))
+	}
+	return est
+}
+
+// add 记录一次请求，返回包括本次在内的估计值
+func (s *windowSketch) add(key string, now time.Time) float64 {
+	idx := s.indexes(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	weight := s.roll(now.UnixNano())
+	for _, i := range idx {
+		if s.current[i] < math.MaxUint32 {
+			s.current[i]++
+		}
+	}
+	return s.estimate(idx, weight)
+}
+
+// count 返回键最近一个窗口内请求数的估计值
+func (s *windowSketch) count(key string, now time.Time) float64 {
+	idx := s.indexes(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.estimate(idx, s.roll(now.UnixNano()))
+}
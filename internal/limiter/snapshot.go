@@ -0,0 +1,384 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// 限流器状态快照，重启后恢复每个 IP 的历史，已知的滥用 IP 不会被当作新 IP。
+// 快照只保存与限流算法无关的状态（计数、首次和最近访问时间、决定惩罚延迟的速率估计），
+// 任何 rate_limit.strategy 都可以加载；各算法窗口内的令牌或计数很快就会恢复，不保存。
+// 封禁记录由 auto_ban.state_path 单独保存。
+//
+// 文件格式（定长整数为大端序，时间均为相对 saved 之前的毫秒数）：
+//
+//	magic    [4]byte  "FMLS"
+//	version  uint8    当前为 1
+//	saved    int64    保存时间（Unix 纳秒）
+//	global   float64 速率 + varint 时间
+//	ips      uvarint 数量，每个 IP：
+//	  addr     uint8 长度 + 字节（netip 二进制形式）
+//	  count    uvarint  请求数
+//	  first    varint   首次请求时间
+//	  last     varint   最近请求时间
+//	  rate     float64  请求速率估计
+//	  rate_at  varint   速率估计的更新时间
+//	tiers    uint8 数量，每个层级：
+//	  name     uint8 长度 + 字节
+//	  entries  uvarint 数量，每个键：key（uint8 长度 + 字节）、rate float64、rate_at varint
+//	crc      uint32   之前所有字节的 CRC-32（IEEE）
+const stateVersion = 1
+
+var stateMagic = [4]byte{'F', 'M', 'L', 'S'}
+
+// ErrBadState 快照格式错误
+var ErrBadState = errors.New("无效的限流器状态快照")
+
+// ipSnapshot 单个 IP 的快照
+type ipSnapshot struct {
+	addr  netip.Addr
+	count int64
+	first time.Time
+	last  time.Time
+	rate  rateEstimator
+}
+
+// tierSnapshot 一个网段或 ASN 的快照
+type tierSnapshot struct {
+	key  string
+	rate rateEstimator
+}
+
+// SaveState 将限流器状态写入 rate_limit.state_path，先写临时文件再替换；未配置路径时不做任何事
+func (rl *RateLimiter) SaveState() error {
+	path := rl.config.RateLimit.StatePath
+	if path == "" {
+		return nil
+	}
+	data := rl.marshalState(time.Now())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadState 从 rate_limit.state_path 恢复限流器状态，需在处理连接之前调用
+// 文件不存在时视为没有快照；速率估计按停机时长衰减，闲置超过 state_max_age 的 IP 不再恢复
+func (rl *RateLimiter) LoadState() error {
+	path := rl.config.RateLimit.StatePath
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取限流器状态失败: %w", err)
+	}
+	ips, err := rl.unmarshalState(data, time.Now())
+	if err != nil {
+		return fmt.Errorf("解析限流器状态失败: %w", err)
+	}
+	rl.logger.Info().Int("count", ips).Str("path", path).Msg("已恢复限流器状态")
+	return nil
+}
+
+// RunSnapshots 每隔 rate_limit.snapshot_interval 保存一次状态，直到 ctx 取消
+// 退出前的最后一次保存由调用方通过 SaveState 完成
+func (rl *RateLimiter) RunSnapshots(ctx context.Context) {
+	if rl.config.RateLimit.StatePath == "" {
+		return
+	}
+	ticker := time.NewTicker(rl.config.RateLimit.SnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := rl.SaveState(); err != nil {
+				rl.logger.Warn().Err(err).Msg("保存限流器状态失败")
+			}
+		}
+	}
+}
+
+// marshalState 按文件格式编码当前状态
+func (rl *RateLimiter) marshalState(now time.Time) []byte {
+	var ips []ipSnapshot
+	rl.ipLimiters.rangeAll(func(ip string, ipLimiter *IPLimiterInfo) bool {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return true
+		}
+		ipLimiter.mu.RLock()
+		ips = append(ips, ipSnapshot{
+			addr:  addr,
+			count: ipLimiter.RequestCount,
+			first: ipLimiter.FirstRequest,
+			last:  ipLimiter.LastRequest,
+			rate:  ipLimiter.rate,
+		})
+		ipLimiter.mu.RUnlock()
+		return true
+	})
+	// 按最近访问时间排序，加载到较小的状态表时最近访问的 IP 最后写入、不会被淘汰
+	slices.SortFunc(ips, func(a, b ipSnapshot) int { return a.last.Compare(b.last) })
+
+	buf := bytes.NewBuffer(make([]byte, 0, 64+len(ips)*40))
+	buf.Write(stateMagic[:])
+	buf.WriteByte(stateVersion)
+	binary.Write(buf, binary.BigEndian, now.UnixNano())
+
+	rl.mu.RLock()
+	writeRate(buf, now, rl.globalRate)
+	rl.mu.RUnlock()
+
+	writeUvarint(buf, uint64(len(ips)))
+	for _, s := range ips {
+		addr, _ := s.addr.MarshalBinary()
+		writeBytes(buf, addr)
+		writeUvarint(buf, uint64(s.count))
+		writeTime(buf, now, s.first)
+		writeTime(buf, now, s.last)
+		writeRate(buf, now, s.rate)
+	}
+
+	buf.WriteByte(uint8(len(rl.tiers)))
+	for _, t := range rl.tiers {
+		var entries []tierSnapshot
+		t.entries.rangeAll(func(key string, e *tierEntry) bool {
+			e.mu.Lock()
+			if !e.rate.last.IsZero() {
+				entries = append(entries, tierSnapshot{key: key, rate: e.rate})
+			}
+			e.mu.Unlock()
+			return true
+		})
+		slices.SortFunc(entries, func(a, b tierSnapshot) int { return a.rate.last.Compare(b.rate.last) })
+
+		writeBytes(buf, []byte(t.name))
+		writeUvarint(buf, uint64(len(entries)))
+		for _, e := range entries {
+			writeBytes(buf, []byte(e.key))
+			writeRate(buf, now, e.rate)
+		}
+	}
+
+	return binary.BigEndian.AppendUint32(buf.Bytes(), crc32.ChecksumIEEE(buf.Bytes()))
+}
+
+// unmarshalState 解析快照并恢复状态，返回恢复的 IP 数
+func (rl *RateLimiter) unmarshalState(data []byte, now time.Time) (int, error) {
+	if len(data) < 17 || [4]byte(data[:4]) != stateMagic || data[4] != stateVersion {
+		return 0, ErrBadState
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return 0, fmt.Errorf("%w: 校验和不匹配", ErrBadState)
+	}
+
+	r := &stateReader{data: body[5:]}
+	saved := time.Unix(0, int64(r.uint64()))
+	globalRate := r.rate(saved)
+
+	var ips []ipSnapshot
+	for range r.count() {
+		var s ipSnapshot
+		if err := s.addr.UnmarshalBinary(r.bytes()); err != nil && r.err == nil {
+			r.err = err
+		}
+		s.count = int64(r.uvarint())
+		s.first = r.time(saved)
+		s.last = r.time(saved)
+		s.rate = r.rate(saved)
+		ips = append(ips, s)
+	}
+
+	tiers := make(map[string][]tierSnapshot)
+	for range r.byte() {
+		name := string(r.bytes())
+		for range r.count() {
+			key := string(r.bytes())
+			tiers[name] = append(tiers[name], tierSnapshot{key: key, rate: r.rate(saved)})
+		}
+	}
+	if r.err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrBadState, r.err)
+	}
+
+	// 速率估计按记录的更新时间衰减，停机期间自然回落；计数和访问时间原样保留
+	rl.mu.Lock()
+	if globalRate.last.After(rl.globalRate.last) {
+		rl.globalRate = globalRate
+	}
+	rl.mu.Unlock()
+
+	maxAge := rl.config.RateLimit.StateMaxAge
+	restored := 0
+	for _, s := range ips {
+		if maxAge > 0 && now.Sub(s.last) > maxAge {
+			continue
+		}
+		ipLimiter := rl.getOrCreateIPLimiter(s.addr.String())
+		ipLimiter.mu.Lock()
+		ipLimiter.RequestCount += s.count
+		if s.first.Before(ipLimiter.FirstRequest) {
+			ipLimiter.FirstRequest = s.first
+		}
+		if s.last.After(ipLimiter.LastRequest) {
+			ipLimiter.LastRequest = s.last
+		}
+		if s.rate.last.After(ipLimiter.rate.last) {
+			ipLimiter.rate = s.rate
+		}
+		ipLimiter.mu.Unlock()
+		restored++
+	}
+
+	for _, t := range rl.tiers {
+		for _, s := range tiers[t.name] {
+			if maxAge > 0 && now.Sub(s.rate.last) > maxAge {
+				continue
+			}
+			e := t.entry(s.key, rl.newBucket, rl.config.RateLimit.Window)
+			e.mu.Lock()
+			if s.rate.last.After(e.rate.last) {
+				e.rate = s.rate
+			}
+			e.mu.Unlock()
+		}
+	}
+	return restored, nil
+}
+
+// writeUvarint 写入无符号变长整数
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	buf.Write(binary.AppendUvarint(nil, v))
+}
+
+// writeBytes 写入带 uint8 长度前缀的字节串，超过 255 字节的部分截断
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	b = b[:min(len(b), math.MaxUint8)]
+	buf.WriteByte(uint8(len(b)))
+	buf.Write(b)
+}
+
+// writeTime 写入 t 相对 saved 之前的毫秒数
+func writeTime(buf *bytes.Buffer, saved, t time.Time) {
+	buf.Write(binary.AppendVarint(nil, saved.Sub(t).Milliseconds()))
+}
+
+// writeRate 写入速率估计
+func writeRate(buf *bytes.Buffer, saved time.Time, e rateEstimator) {
+	binary.Write(buf, binary.BigEndian, math.Float64bits(e.rate))
+	if e.last.IsZero() {
+		e.last = saved
+	}
+	writeTime(buf, saved, e.last)
+}
+
+// stateReader 按文件格式读取字段，第一次出错后后续读取都返回零值
+type stateReader struct {
+	data []byte
+	err  error
+}
+
+func (r *stateReader) fail() {
+	if r.err == nil {
+		r.err = io.ErrUnexpectedEOF
+	}
+	r.data = nil
+}
+
+func (r *stateReader) byte() uint8 {
+	if len(r.data) < 1 {
+		r.fail()
+		return 0
+	}
+	v := r.data[0]
+	r.data = r.data[1:]
+	return v
+}
+
+func (r *stateReader) uint64() uint64 {
+	if len(r.data) < 8 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *stateReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *stateReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count 读取元素数量，数量不可能超过剩余字节数
+func (r *stateReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (r *stateReader) bytes() []byte {
+	n := int(r.byte())
+	if len(r.data) < n {
+		r.fail()
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *stateReader) time(saved time.Time) time.Time {
+	return saved.Add(-time.Duration(r.varint()) * time.Millisecond)
+}
+
+func (r *stateReader) rate(saved time.Time) rateEstimator {
+	rate := math.Float64frombits(r.uint64())
+	if math.IsNaN(rate) || math.IsInf(rate, 0) || rate < 0 {
+		rate = 0
+		if r.err == nil {
+			r.err = errors.New("无效的速率")
+		}
+	}
+	return rateEstimator{rate: rate, last: r.time(saved)}
+}
@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fake-mc-server/internal/config"
+)
+
+func TestSnapshot(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "limiter.state")
+	newLimiter := func(strategy string) *RateLimiter {
+		cfg := newTierTestConfig()
+		cfg.RateLimit.Strategy = strategy
+		cfg.RateLimit.StatePath = statePath
+		cfg.RateLimit.StateMaxAge = time.Hour
+		cfg.RateLimit.IPv4Subnet = config.LimitTierConfig{Enabled: true, Prefix: 24, Limit: 100, MaxPenalty: time.Second, RateMultiplier: 2}
+		return newTestLimiter(t, cfg, nil)
+	}
+
+	// 文件不存在时视为没有快照
+	before := newLimiter("token_bucket")
+	if err := before.LoadState(); err != nil {
+		t.Fatalf("LoadState() = %v", err)
+	}
+	for range 20 {
+		before.Allow("198.51.100.7")
+	}
+	before.Allow("2001:db8::1")
+	// 模拟很久以前访问过的 IP，加载时应被丢弃
+	stale, _ := before.ipLimiters.peek("2001:db8::1")
+	stale.LastRequest = time.Now().Add(-2 * time.Hour)
+
+	if err := before.SaveState(); err != nil {
+		t.Fatalf("SaveState() = %v", err)
+	}
+	saved, _ := before.ipLimiters.peek("198.51.100.7")
+
+	// 换一种限流算法加载，模拟停机 5 个半衰期
+	after := newLimiter("gcra")
+	data, _ := os.ReadFile(statePath)
+	if _, err := after.unmarshalState(data, time.Now().Add(5*time.Second)); err != nil {
+		t.Fatalf("unmarshalState() = %v", err)
+	}
+
+	restored, ok := after.ipLimiters.peek("198.51.100.7")
+	if !ok {
+		t.Fatal("未恢复 IP 状态")
+	}
+	if restored.RequestCount != 20 || restored.FirstRequest.Sub(saved.FirstRequest).Abs() > time.Millisecond {
+		t.Errorf("恢复的状态 = %+v, 保存时 = %+v", restored, saved)
+	}
+	if _, ok := after.ipLimiters.peek("2001:db8::1"); ok {
+		t.Error("闲置超过 state_max_age 的 IP 不应恢复")
+	}
+
+	// 速率估计按停机时长衰减：5 个半衰期后约为 1/32
+	now := time.Now().Add(5 * time.Second)
+	want := saved.rate.at(now, before.rateTau)
+	if got := restored.rate.at(now, after.rateTau); math.Abs(got-want) > want*0.01 || got > saved.rate.rate/30 {
+		t.Errorf("恢复后速率 = %.4f, want %.4f（停机前 %.4f）", got, want, saved.rate.rate)
+	}
+
+	// 网段层级的速率一起恢复
+	if e, ok := after.tiers[0].entries.peek("198.51.100.0/24"); !ok || e.rate.rate == 0 {
+		t.Error("未恢复网段状态")
+	}
+
+	// 损坏的文件不会被加载
+	data[len(data)/2] ^= 0xff
+	if _, err := newLimiter("token_bucket").unmarshalState(data, time.Now()); !errors.Is(err, ErrBadState) {
+		t.Errorf("损坏的快照 unmarshalState() = %v", err)
+	}
+	if _, err := newLimiter("token_bucket").unmarshalState(data[:10], time.Now()); !errors.Is(err, ErrBadState) {
+		t.Errorf("截断的快照 unmarshalState() = %v", err)
+	}
+}
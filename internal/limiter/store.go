@@ -0,0 +1,138 @@
+package limiter
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+// lruShards 分片数，降低锁竞争
+const lruShards = 64
+
+// lruStore 容量有限的分片 LRU，分片已满时淘汰该分片中最久未访问的键
+// 容量按分片平均分配并向上取整
+type lruStore[V any] struct {
+	seed     maphash.Seed
+	shards   [lruShards]lruShard[V]
+	capacity int
+	size     atomic.Int64 // 当前键数
+	evicted  atomic.Int64 // 累计淘汰的键数
+}
+
+type lruShard[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    list.List // 队首为最近访问
+}
+
+type lruItem[V any] struct {
+	key   string
+	value V
+}
+
+func newLRUStore[V any](capacity int) *lruStore[V] {
+	s := &lruStore[V]{seed: maphash.MakeSeed()}
+	perShard := max(1, (capacity+lruShards-1)/lruShards)
+	s.capacity = perShard * lruShards
+	for i := range s.shards {
+		s.shards[i].capacity = perShard
+		s.shards[i].items = make(map[string]*list.Element)
+	}
+	return s
+}
+
+func (s *lruStore[V]) shard(key string) *lruShard[V] {
+	return &s.shards[maphash.String(s.seed, key)%lruShards]
+}
+
+// get 查找键并标记为最近访问
+func (s *lruStore[V]) get(key string) (V, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if el, ok := sh.items[key]; ok {
+		sh.order.MoveToFront(el)
+		return el.Value.(*lruItem[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// peek 查找键，不改变访问顺序
+func (s *lruStore[V]) peek(key string) (V, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if el, ok := sh.items[key]; ok {
+		return el.Value.(*lruItem[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// getOrCreate 查找键，不存在时调用 create 创建，分片已满时先淘汰最久未访问的键
+// 返回的 bool 表示是否新建
+func (s *lruStore[V]) getOrCreate(key string, create func() V) (V, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if el, ok := sh.items[key]; ok {
+		sh.order.MoveToFront(el)
+		return el.Value.(*lruItem[V]).value, false
+	}
+	if sh.order.Len() >= sh.capacity {
+		oldest := sh.order.Back()
+		sh.order.Remove(oldest)
+		delete(sh.items, oldest.Value.(*lruItem[V]).key)
+		s.evicted.Add(1)
+	} else {
+		s.size.Add(1)
+	}
+	value := create()
+	sh.items[key] = sh.order.PushFront(&lruItem[V]{key: key, value: value})
+	return value, true
+}
+
+// delete 删除键
+func (s *lruStore[V]) delete(key string) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if el, ok := sh.items[key]; ok {
+		sh.order.Remove(el)
+		delete(sh.items, key)
+		s.size.Add(-1)
+	}
+}
+
+// rangeAll 遍历所有键，fn 返回 false 时停止；fn 在分片锁之外执行，可以调用 delete
+func (s *lruStore[V]) rangeAll(fn func(key string, value V) bool) {
+	var items []lruItem[V]
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		items = items[:0]
+		for el := sh.order.Front(); el != nil; el = el.Next() {
+			items = append(items, *el.Value.(*lruItem[V]))
+		}
+		sh.mu.Unlock()
+		for _, item := range items {
+			if !fn(item.key, item.value) {
+				return
+			}
+		}
+	}
+}
+
+// len 返回当前键数
+func (s *lruStore[V]) len() int {
+	return int(s.size.Load())
+}
+
+// full 键数是否接近容量（7/8），此后新建键很可能淘汰旧键
+// 各分片的键数不完全均匀，总数达到容量之前部分分片已经开始淘汰
+func (s *lruStore[V]) full() bool {
+	return s.size.Load() >= int64(s.capacity)*7/8
+}
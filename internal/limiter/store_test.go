@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLRUStore(t *testing.T) {
+	s := newLRUStore[int](100)
+	if s.capacity != 128 {
+		t.Fatalf("capacity = %d, want 128（按分片向上取整）", s.capacity)
+	}
+
+	s.getOrCreate("keep", func() int { return -1 })
+	for i := range 10000 {
+		s.getOrCreate(fmt.Sprint(i), func() int { return i })
+		s.get("keep") // 持续访问的键不会被淘汰
+	}
+
+	if v, ok := s.peek("keep"); !ok || v != -1 {
+		t.Errorf("持续访问的键被淘汰: %v, %v", v, ok)
+	}
+	if _, ok := s.peek("0"); ok {
+		t.Error("最久未访问的键应被淘汰")
+	}
+	if s.len() > s.capacity || !s.full() {
+		t.Errorf("len() = %d, capacity = %d", s.len(), s.capacity)
+	}
+	if got, want := s.evicted.Load(), int64(10001-s.len()); got != want {
+		t.Errorf("evicted = %d, want %d", got, want)
+	}
+
+	n := 0
+	s.rangeAll(func(key string, _ int) bool {
+		s.delete(key)
+		n++
+		return true
+	})
+	if n != 10001-int(s.evicted.Load()) || s.len() != 0 {
+		t.Errorf("遍历 %d 个键后 len() = %d", n, s.len())
+	}
+}
+
+// TestSpoofedFlood 大量一次性源地址不会让状态表无限增长，被挤出状态表的 IP 仍按近似计数限流
+func TestSpoofedFlood(t *testing.T) {
+	cfg := newTierTestConfig()
+	cfg.RateLimit.IPLimit = 3
+	cfg.RateLimit.MaxTrackedIPs = 640
+	cfg.RateLimit.GlobalLimit = 1_000_000
+	rl := newTestLimiter(t, cfg, nil)
+
+	const attacker = "203.0.113.9"
+	for range 3 {
+		if !rl.Allow(attacker) {
+			t.Fatal("前 3 次请求应允许")
+		}
+	}
+
+	rejected := 0
+	for i := range 20000 {
+		if !rl.Allow(fmt.Sprintf("10.%d.%d.%d", i>>16, i>>8&0xff, i&0xff)) {
+			rejected++
+		}
+	}
+	if rejected != 0 {
+		t.Errorf("一次性地址被拒绝 %d 次", rejected)
+	}
+
+	stats := rl.GetStats()
+	if active := stats["active_ip_count"].(int); active > 640 {
+		t.Errorf("active_ip_count = %d, 超过 max_tracked_ips", active)
+	}
+	if evicted := stats["evicted_ips"].(int64); evicted < 20000-640 {
+		t.Errorf("evicted_ips = %d", evicted)
+	}
+
+	// 攻击者的精确状态已被挤出，重建后的状态本会允许新的突发，近似计数仍记得它
+	if _, ok := rl.ipLimiters.peek(attacker); ok {
+		t.Fatal("攻击者的状态应已被淘汰")
+	}
+	if rl.Allow(attacker) {
+		t.Error("被淘汰后重新出现的攻击者应按近似计数拒绝")
+	}
+	if got := rl.GetStats()["approximate_rejections"]; got != int64(1) {
+		t.Errorf("approximate_rejections = %v, want 1", got)
+	}
+	if rate := rl.GetIPFrequency(attacker); rate <= 0 {
+		t.Errorf("GetIPFrequency() = %v", rate)
+	}
+}
@@ -0,0 +1,183 @@
+package limiter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"fake-mc-server/internal/limiter/strategy"
+)
+
+// Bucket 单个键（某个 IP 或全局）的限流状态，每个窗口最多允许 limit 次请求
+type Bucket interface {
+	// Allow 判断 now 时刻是否允许一次请求，允许时计入
+	Allow(now time.Time) bool
+	// Remaining 返回 now 时刻还能立即允许的请求数（可能为小数）
+	Remaining(now time.Time) float64
+}
+
+// newBucketFunc 创建指定算法的限流状态
+type newBucketFunc func(limit int, window time.Duration) Bucket
+
+// strategies 各限流算法的实现，算法名见 strategy 包
+var strategies = map[string]newBucketFunc{
+	strategy.TokenBucket:   newTokenBucket,
+	strategy.SlidingLog:    newSlidingLog,
+	strategy.SlidingWindow: newSlidingWindow,
+	strategy.GCRA:          newGCRA,
+}
+
+// Strategies 返回可选的限流算法名
+func Strategies() []string {
+	return strategy.Names()
+}
+
+// NewBucket 按算法名创建限流状态
+func NewBucket(name string, limit int, window time.Duration) (Bucket, error) {
+	newBucket, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的限流算法: %s", name)
+	}
+	return newBucket(limit, window), nil
+}
+
+// tokenBucket 令牌桶：容量 limit，每个窗口补满，允许突发
+type tokenBucket struct {
+	limiter *rate.Limiter
+}
+
+func newTokenBucket(limit int, window time.Duration) Bucket {
+	return &tokenBucket{
+		limiter: rate.NewLimiter(rate.Limit(float64(limit)/window.Seconds()), limit),
+	}
+}
+
+func (b *tokenBucket) Allow(now time.Time) bool {
+	return b.limiter.AllowN(now, 1)
+}
+
+func (b *tokenBucket) Remaining(now time.Time) float64 {
+	return max(0, b.limiter.TokensAt(now))
+}
+
+// slidingLog 滑动窗口日志：记录窗口内每次请求的时间，精确但每个键占用 O(limit) 内存
+type slidingLog struct {
+	mu     sync.Mutex
+	window time.Duration
+	times  []int64 // 环形缓冲区，按时间顺序保存最近 limit 次请求
+	head   int
+	count  int
+}
+
+func newSlidingLog(limit int, window time.Duration) Bucket {
+	return &slidingLog{window: window, times: make([]int64, limit)}
+}
+
+// expire 丢弃窗口外的记录，调用方需持有锁
+func (b *slidingLog) expire(now int64) {
+	cutoff := now - int64(b.window)
+	for b.count > 0 && b.times[b.head] <= cutoff {
+		b.head = (b.head + 1) % len(b.times)
+		b.count--
+	}
+}
+
+func (b *slidingLog) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ts := now.UnixNano()
+	b.expire(ts)
+	if b.count >= len(b.times) {
+		return false
+	}
+	b.times[(b.head+b.count)%len(b.times)] = ts
+	b.count++
+	return true
+}
+
+func (b *slidingLog) Remaining(now time.Time) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(now.UnixNano())
+	return float64(len(b.times) - b.count)
+}
+
+// slidingWindow 滑动窗口计数：按上一个固定窗口的计数和已过去的比例估算，每个键只需两个计数
+type slidingWindow struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	start    int64 // 当前固定窗口的起点
+	current  int
+	previous int
+}
+
+func newSlidingWindow(limit int, window time.Duration) Bucket {
+	return &slidingWindow{limit: limit, window: window}
+}
+
+// estimate 滚动固定窗口并估算最近一个窗口内的请求数，调用方需持有锁
+func (b *slidingWindow) estimate(now int64) float64 {
+	window := int64(b.window)
+	start := now - now%window
+	switch {
+	case start == b.start:
+	case start-b.start == window:
+		b.previous, b.current = b.current, 0
+	default:
+		b.previous, b.current = 0, 0
+	}
+	b.start = start
+	weight := 1 - float64(now-start)/float64(window)
+	return float64(b.previous)*weight + float64(b.current)
+}
+
+func (b *slidingWindow) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.estimate(now.UnixNano())+1 > float64(b.limit) {
+		return false
+	}
+	b.current++
+	return true
+}
+
+func (b *slidingWindow) Remaining(now time.Time) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(0, float64(b.limit)-b.estimate(now.UnixNano()))
+}
+
+// gcra 通用信元速率算法：只保存理论到达时间，请求间隔为 window/limit，最多突发 limit 次
+type gcra struct {
+	mu       sync.Mutex
+	interval int64 // 每次请求占用的时间
+	burst    int64 // 允许理论到达时间超前当前时间的最大值
+	tat      int64 // 理论到达时间
+}
+
+func newGCRA(limit int, window time.Duration) Bucket {
+	interval := int64(window) / int64(limit)
+	return &gcra{interval: interval, burst: int64(window) - interval}
+}
+
+func (b *gcra) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ts := now.UnixNano()
+	tat := max(b.tat, ts)
+	if tat-ts > b.burst {
+		return false
+	}
+	b.tat = tat + b.interval
+	return true
+}
+
+func (b *gcra) Remaining(now time.Time) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ahead := max(b.tat-now.UnixNano(), 0)
+	return max(0, float64(b.burst+b.interval-ahead)/float64(b.interval))
+}
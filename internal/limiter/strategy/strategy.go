@@ -0,0 +1,25 @@
+// Package strategy 限流算法名，配置校验和限流器共用同一份列表
+package strategy
+
+import "slices"
+
+// 可选的限流算法
+const (
+	TokenBucket   = "token_bucket"   // 令牌桶
+	SlidingLog    = "sliding_log"    // 滑动窗口日志
+	SlidingWindow = "sliding_window" // 滑动窗口计数
+	GCRA          = "gcra"           // 通用信元速率算法
+)
+
+// names 按字母序排列的算法名
+var names = []string{GCRA, SlidingLog, SlidingWindow, TokenBucket}
+
+// Names 返回可选的限流算法名（按字母序）
+func Names() []string {
+	return slices.Clone(names)
+}
+
+// Valid 判断算法名是否受支持
+func Valid(name string) bool {
+	return slices.Contains(names, name)
+}
@@ -0,0 +1,131 @@
+package limiter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+)
+
+func TestStrategies(t *testing.T) {
+	const (
+		limit  = 5
+		window = 10 * time.Second
+	)
+	start := time.Unix(1_700_000_000, 0)
+
+	for _, name := range Strategies() {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBucket(name, limit, window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.Remaining(start); got != limit {
+				t.Errorf("初始 Remaining() = %v, want %d", got, limit)
+			}
+
+			// 一个窗口内最多允许 limit 次
+			for i := range limit {
+				if !b.Allow(start) {
+					t.Fatalf("第 %d 次请求被拒绝", i+1)
+				}
+			}
+			if b.Allow(start) || b.Allow(start.Add(window/10)) {
+				t.Error("超过 limit 后应拒绝")
+			}
+			if got := b.Remaining(start); got >= 1 {
+				t.Errorf("用尽后 Remaining() = %v", got)
+			}
+
+			// 过了两个窗口后完全恢复（滑动窗口计数需要上一个固定窗口也过去）
+			later := start.Add(2 * window)
+			if got := b.Remaining(later); got != limit {
+				t.Errorf("恢复后 Remaining() = %v, want %d", got, limit)
+			}
+			for i := range limit {
+				if !b.Allow(later) {
+					t.Fatalf("恢复后第 %d 次请求被拒绝", i+1)
+				}
+			}
+		})
+	}
+
+	if _, err := NewBucket("fixed_window", limit, window); err == nil {
+		t.Error("未知算法应返回错误")
+	}
+	if len(strategies) != len(Strategies()) {
+		t.Errorf("实现了 %d 种算法，strategy 包列出 %d 种", len(strategies), len(Strategies()))
+	}
+	cfg := &config.Config{RateLimit: config.RateLimitConfig{Strategy: "fixed_window", IPLimit: limit, GlobalLimit: limit, Window: window}}
+	if _, err := NewRateLimiter(cfg, zerolog.Nop(), nil); err == nil {
+		t.Error("未知算法不应回退到令牌桶")
+	}
+}
+
+// TestStrategiesLongRun 均匀发送请求时，每种算法在长时间内允许的总数都约等于 limit/window
+func TestStrategiesLongRun(t *testing.T) {
+	const (
+		limit  = 10
+		window = time.Second
+		step   = 10 * time.Millisecond // 每个窗口 100 次请求，远超 limit
+		total  = 100 * window
+	)
+	start := time.Unix(1_700_000_000, 0)
+
+	for _, name := range Strategies() {
+		t.Run(name, func(t *testing.T) {
+			b, _ := NewBucket(name, limit, window)
+			allowed := 0
+			for d := time.Duration(0); d < total; d += step {
+				if b.Allow(start.Add(d)) {
+					allowed++
+				}
+			}
+			// 滑动窗口计数是近似算法，稳定状态下会略少于 limit，允许 20% 误差
+			want := limit * int(total/window)
+			if allowed < want*4/5 || allowed > want+limit {
+				t.Errorf("%s 内允许 %d 次, want 约 %d", total, allowed, want)
+			}
+		})
+	}
+}
+
+// BenchmarkStrategies 各算法共用的基准：单个键的判定开销，以及 RateLimiter 在大量 IP 下的并发开销
+func BenchmarkStrategies(b *testing.B) {
+	for _, name := range Strategies() {
+		b.Run(name+"/bucket", func(b *testing.B) {
+			bucket, _ := NewBucket(name, 100, time.Second)
+			now := time.Now()
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				bucket.Allow(now.Add(time.Duration(i) * time.Millisecond))
+			}
+		})
+
+		b.Run(name+"/limiter", func(b *testing.B) {
+			cfg := &config.Config{RateLimit: config.RateLimitConfig{
+				Strategy:    name,
+				IPLimit:     5,
+				GlobalLimit: 1_000_000, // sliding_log 按 limit 预分配日志，不能取过大的值
+				Window:      time.Second,
+			}}
+			rl := newTestLimiter(b, cfg, nil)
+			ips := make([]string, 4096)
+			for i := range ips {
+				ips[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+			}
+			var next atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					rl.Allow(ips[next.Add(1)%int64(len(ips))])
+				}
+			})
+		})
+	}
+}
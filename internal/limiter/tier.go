@@ -0,0 +1,134 @@
+package limiter
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/geoip"
+)
+
+// tier 聚合限流层级：按网段或 ASN 把多个 IP 归为同一个键，共享限额和速率估计
+type tier struct {
+	name     string
+	config   *config.LimitTierConfig
+	key      func(ip string) (string, bool) // 返回 IP 所属的键，不适用时返回 false
+	entries  *lruStore[*tierEntry]
+	rejected atomic.Int64
+}
+
+// tierEntry 一个网段或 ASN 的限流状态
+type tierEntry struct {
+	limiter Bucket
+	mu      sync.Mutex
+	rate    rateEstimator
+}
+
+// newTiers 按配置创建启用的聚合限流层级，每个层级最多跟踪 capacity 个键
+func newTiers(cfg *config.RateLimitConfig, geo *geoip.Database, capacity int) []*tier {
+	var tiers []*tier
+	if cfg.IPv4Subnet.Enabled {
+		bits := cfg.IPv4Subnet.Prefix
+		tiers = append(tiers, &tier{
+			name:   "ipv4_subnet",
+			config: &cfg.IPv4Subnet,
+			key: func(ip string) (string, bool) {
+				addr, err := netip.ParseAddr(ip)
+				if addr = addr.Unmap(); err != nil || !addr.Is4() {
+					return "", false
+				}
+				return prefixKey(addr, bits)
+			},
+		})
+	}
+	if cfg.IPv6Subnet.Enabled {
+		bits := cfg.IPv6Subnet.Prefix
+		tiers = append(tiers, &tier{
+			name:   "ipv6_subnet",
+			config: &cfg.IPv6Subnet,
+			key: func(ip string) (string, bool) {
+				addr, err := netip.ParseAddr(ip)
+				if addr = addr.Unmap(); err != nil || !addr.Is6() {
+					return "", false
+				}
+				return prefixKey(addr, bits)
+			},
+		})
+	}
+	if cfg.ASN.Enabled {
+		tiers = append(tiers, &tier{
+			name:   "asn",
+			config: &cfg.ASN,
+			key: func(ip string) (string, bool) {
+				asn := geo.Lookup(ip).ASN
+				if asn == 0 {
+					return "", false
+				}
+				return fmt.Sprintf("AS%d", asn), true
+			},
+		})
+	}
+	for _, t := range tiers {
+		t.entries = newLRUStore[*tierEntry](capacity)
+	}
+	return tiers
+}
+
+// prefixKey 返回地址所在网段
+func prefixKey(addr netip.Addr, bits int) (string, bool) {
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return "", false
+	}
+	return prefix.String(), true
+}
+
+// entry 获取或创建键的限流状态
+func (t *tier) entry(key string, newBucket newBucketFunc, window time.Duration) *tierEntry {
+	e, _ := t.entries.getOrCreate(key, func() *tierEntry {
+		return &tierEntry{limiter: newBucket(t.config.Limit, window)}
+	})
+	return e
+}
+
+// penalty 计算惩罚延迟：最近的速率超过限制速率时，按超出的倍数乘以基础延迟，不超过上限
+func (t *tier) penalty(rate, limitPerSecond float64, baseDelay time.Duration) time.Duration {
+	excess := rate/limitPerSecond - 1
+	if excess <= 0 {
+		return 0
+	}
+	return time.Duration(math.Min(
+		float64(t.config.MaxPenalty.Nanoseconds()),
+		excess*t.config.RateMultiplier*float64(baseDelay.Nanoseconds()),
+	))
+}
+
+// cleanup 删除超过 idle 没有请求的键
+func (t *tier) cleanup(now time.Time, idle time.Duration) int {
+	removed := 0
+	t.entries.rangeAll(func(key string, e *tierEntry) bool {
+		e.mu.Lock()
+		last := e.rate.last
+		e.mu.Unlock()
+		if now.Sub(last) > idle {
+			t.entries.delete(key)
+			removed++
+		}
+		return true
+	})
+	return removed
+}
+
+// stats 层级统计
+func (t *tier) stats() map[string]any {
+	return map[string]any{
+		"limit":    t.config.Limit,
+		"keys":     t.entries.len(),
+		"evicted":  t.entries.evicted.Load(),
+		"rejected": t.rejected.Load(),
+	}
+}
@@ -0,0 +1,142 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/geoip"
+	"fake-mc-server/internal/limiter/strategy"
+)
+
+func newTierTestConfig() *config.Config {
+	return &config.Config{
+		RateLimit: config.RateLimitConfig{Strategy: strategy.TokenBucket, IPLimit: 100, GlobalLimit: 10000, Window: time.Minute},
+		Delay: config.DelayConfig{
+			BaseDelay:            100 * time.Millisecond,
+			MaxIPPenalty:         5 * time.Second,
+			MaxGlobalPenalty:     2 * time.Second,
+			IPFrequencyFactor:    1.5,
+			GlobalLoadFactor:     1.2,
+			IPRateMultiplier:     2,
+			GlobalRateMultiplier: 1.5,
+			RateHalfLife:         time.Second,
+		},
+	}
+}
+
+func newTestLimiter(tb testing.TB, cfg *config.Config, geo *geoip.Database) *RateLimiter {
+	tb.Helper()
+	rl, err := NewRateLimiter(cfg, zerolog.Nop(), geo)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return rl
+}
+
+func TestTiers(t *testing.T) {
+	tier := config.LimitTierConfig{Enabled: true, Limit: 3, MaxPenalty: 3 * time.Second, RateMultiplier: 2}
+
+	tests := []struct {
+		name     string
+		setup    func(cfg *config.RateLimitConfig)
+		stat     string
+		rotating []string // 同一网段或 ASN 内轮换的地址，前 3 个允许，之后拒绝
+		other    string   // 不同网段或 ASN 的地址，不受影响
+	}{
+		{
+			name: "IPv4 网段",
+			setup: func(cfg *config.RateLimitConfig) {
+				cfg.IPv4Subnet = tier
+				cfg.IPv4Subnet.Prefix = 24
+			},
+			stat:     "ipv4_subnet",
+			rotating: []string{"198.51.100.1", "198.51.100.2", "::ffff:198.51.100.3", "198.51.100.4"},
+			other:    "198.51.101.1",
+		},
+		{
+			name: "IPv6 网段",
+			setup: func(cfg *config.RateLimitConfig) {
+				cfg.IPv6Subnet = tier
+				cfg.IPv6Subnet.Prefix = 64
+			},
+			stat:     "ipv6_subnet",
+			rotating: []string{"2001:db8:0:1::1", "2001:db8:0:1::2", "2001:db8:0:1:ffff::3", "2001:db8:0:1::4"},
+			other:    "2001:db8:0:2::1",
+		},
+		{
+			name: "ASN",
+			setup: func(cfg *config.RateLimitConfig) {
+				cfg.ASN = tier
+			},
+			stat:     "asn",
+			rotating: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"},
+			other:    "81.2.69.142",
+		},
+	}
+
+	// testdata 中 192.0.2.0/24 为 AS64496，81.2.69.0/24 为 AS20712
+	geo, err := geoip.NewDatabase(&config.GeoIPConfig{
+		Enabled:   true,
+		ASNPath:   "../geoip/testdata/asn.mmdb",
+		CacheSize: 16,
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTierTestConfig()
+			tt.setup(&cfg.RateLimit)
+			rl := newTestLimiter(t, cfg, geo)
+
+			calm := rl.CalculateDelay(tt.other)
+			for i, ip := range tt.rotating {
+				if got, want := rl.Allow(ip), i < 3; got != want {
+					t.Errorf("Allow(%s) = %v, want %v", ip, got, want)
+				}
+			}
+			if !rl.Allow(tt.other) {
+				t.Errorf("其他网段的 %s 不应受影响", tt.other)
+			}
+
+			// 网段内的速率远超每分钟 3 次，其中的新地址也会被追加延迟
+			if got := rl.CalculateDelay(tt.rotating[0]); got <= calm {
+				t.Errorf("网段内延迟 %s 应大于 %s", got, calm)
+			}
+			if got := rl.GetStats()[tt.stat].(map[string]any)["rejected"]; got != int64(1) {
+				t.Errorf("rejected = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestTierChargedAfterIPLimit(t *testing.T) {
+	cfg := newTierTestConfig()
+	cfg.RateLimit.IPLimit = 2
+	cfg.RateLimit.IPv4Subnet = config.LimitTierConfig{Enabled: true, Prefix: 24, Limit: 3, MaxPenalty: time.Second, RateMultiplier: 2}
+	rl := newTestLimiter(t, cfg, nil)
+	subnet := rl.tiers[0]
+
+	// 计算延迟是只读路径，不创建网段状态
+	rl.CalculateDelay("198.51.100.9")
+	if n := subnet.entries.len(); n != 0 {
+		t.Fatalf("CalculateDelay() 创建了 %d 个网段状态", n)
+	}
+
+	// 单个 IP 超过自身限额后的请求不消耗网段限额，同网段的其他地址不受影响
+	for i := range 5 {
+		if got, want := rl.Allow("198.51.100.1"), i < 2; got != want {
+			t.Errorf("Allow(198.51.100.1) #%d = %v, want %v", i+1, got, want)
+		}
+	}
+	if !rl.Allow("198.51.100.2") {
+		t.Error("网段限额不应被 IP 限流拒绝的请求消耗")
+	}
+	if got := subnet.rejected.Load(); got != 0 {
+		t.Errorf("rejected = %d, want 0", got)
+	}
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"fake-mc-server/internal/config"
+	"github.com/bytedance/sonic"
+)
+
+// CommandEvent 陷阱世界中客户端发送的聊天或命令
+type CommandEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	SessionID string    `json:"session_id"`
+	ClientIP  string    `json:"client_ip"`
+	Username  string    `json:"username"`
+	Kind      string    `json:"kind"`      // "chat", "command"
+	OffsetMs  int64     `json:"offset_ms"` // 距离进入世界的时间(毫秒)
+	Text      string    `json:"text"`      // 原始文本，命令不含开头的 /
+}
+
+// commandsFilePath 命令日志路径，未配置时与蜜罐日志放在同一目录
+func commandsFilePath(cfg *config.HoneypotLoggingConfig) string {
+	if cfg.CommandsFilePath != "" {
+		return cfg.CommandsFilePath
+	}
+	return filepath.Join(filepath.Dir(cfg.FilePath), "commands"+filepath.Ext(cfg.FilePath))
+}
+
+// writeCommandCSVHeader 写入命令日志的CSV表头
+func (hl *HoneypotLogger) writeCommandCSVHeader() error {
+	headers := []string{
+		"timestamp", "session_id", "client_ip", "username",
+		"kind", "offset_ms", "text",
+	}
+	return hl.commandCSVWriter.Write(headers)
+}
+
+// LogCommand 记录聊天/命令事件到独立的命令日志，与蜜罐事件共用异步队列
+func (hl *HoneypotLogger) LogCommand(event *CommandEvent) error {
+	if !hl.enabled {
+		return nil
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	snapshot := *event
+	return hl.enqueue(queueItem{command: &snapshot})
+}
+
+// writeCommand 写入一条命令事件，由后台协程调用
+func (hl *HoneypotLogger) writeCommand(event *CommandEvent) error {
+	if hl.commandCSVWriter != nil {
+		record := []string{
+			event.Timestamp.Format(time.RFC3339),
+			event.SessionID,
+			event.ClientIP,
+			event.Username,
+			event.Kind,
+			fmt.Sprintf("%d", event.OffsetMs),
+			event.Text,
+		}
+		return hl.commandCSVWriter.Write(record)
+	}
+
+	data, err := sonic.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化命令事件失败: %w", err)
+	}
+	hl.commandBuffer.Write(data)
+	return hl.commandBuffer.WriteByte('\n')
+}
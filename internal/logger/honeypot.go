@@ -1,17 +1,22 @@
 package logger
 
 import (
+	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog/log"
 	"fake-mc-server/internal/config"
 )
 
@@ -19,7 +24,7 @@ import (
 type HoneypotEvent struct {
 	Timestamp       time.Time `json:"timestamp"`
 	ClientIP        string    `json:"client_ip"`
-	EventType       string    `json:"event_type"` // "connection", "handshake", "login_attempt", "status_query", "protocol_violation"
+	EventType       string    `json:"event_type"` // "connection", "handshake", "login_attempt", "status_query", "protocol_violation", "fingerprint", "access_denied", "key_exchange", "trap_join", "trap_leave", "chat", "command", "plugin_message", "attack_session"
 	ProtocolVersion int       `json:"protocol_version,omitempty"`
 	ServerAddress   string    `json:"server_address,omitempty"`
 	ServerPort      uint16    `json:"server_port,omitempty"`
@@ -29,16 +34,93 @@ type HoneypotEvent struct {
 	IPFrequency     float64   `json:"ip_frequency,omitempty"`
 	ErrorMessage    string    `json:"error_message,omitempty"`
 	UserAgent       string    `json:"user_agent,omitempty"`
-	GeoLocation     string    `json:"geo_location,omitempty"` // 预留地理位置字段
+	GeoLocation     string    `json:"geo_location,omitempty"`   // 国家/城市，如 GB/London
+	ForwardedIP     string    `json:"forwarded_ip,omitempty"`   // BungeeCord 转发的真实客户端 IP
+	ForwardedUUID   string    `json:"forwarded_uuid,omitempty"` // BungeeCord 转发的玩家 UUID
+	ModLoader       string    `json:"mod_loader,omitempty"`     // Forge 等模组加载器标记
+	ClientType      string    `json:"client_type,omitempty"`    // 客户端指纹分类
+	Fingerprint     string    `json:"fingerprint,omitempty"`    // 命中的指纹特征摘要
+	Labels          []string  `json:"labels,omitempty"`         // 扫描器特征库命中的标签
+	KeyExchange     string    `json:"key_exchange,omitempty"`   // 伪造正版验证的密钥交换结果
+	Message         string    `json:"message,omitempty"`        // 陷阱世界中的聊天内容或命令
+	Channel         string    `json:"channel,omitempty"`        // 插件消息频道
+	PayloadSize     int       `json:"payload_size,omitempty"`   // 插件消息载荷大小
+	PayloadHash     string    `json:"payload_hash,omitempty"`   // 插件消息载荷的 SHA-256
+
+	Country string `json:"country,omitempty"` // ISO 国家代码
+	ASN     uint32 `json:"asn,omitempty"`
+	ASOrg   string `json:"as_org,omitempty"` // ASN 所属组织
+
+	ReverseDNS string `json:"reverse_dns,omitempty"` // 客户端 IP 的 PTR 主机名
+	Provider   string `json:"provider,omitempty"`    // 按 PTR 规则识别的来源，如 aws、shodan、residential
+
+	Session *AttackSession `json:"session,omitempty"` // attack_session 事件的会话摘要
+}
+
+// AttackSession 同一 IP 在一段连续活动时间内的行为摘要
+type AttackSession struct {
+	ID            string         `json:"id"` // 客户端 IP 与首次出现时间，用于关联同一会话的多条摘要
+	FirstSeen     time.Time      `json:"first_seen"`
+	LastSeen      time.Time      `json:"last_seen"`
+	Connections   int            `json:"connections"`
+	Events        int            `json:"events"`
+	EventCounts   map[string]int `json:"event_counts"`
+	Hostnames     []string       `json:"hostnames,omitempty"`
+	Usernames     []string       `json:"usernames,omitempty"`
+	Protocols     []int          `json:"protocols,omitempty"`
+	MaxConnPerMin int            `json:"max_conn_per_min"` // 单分钟内的最大连接数
+	TotalDelayMs  int64          `json:"total_delay_ms"`   // 累计施加的延迟(毫秒)
+	Ended         bool           `json:"ended"`            // false 为活跃会话的阶段性摘要
 }
 
+// 未配置时的异步队列参数
+const (
+	defaultQueueSize = 65536
+	defaultBatchSize = 256
+)
+
+// ErrEventDropped 队列已满且策略为丢弃新事件
+var ErrEventDropped = errors.New("蜜罐事件队列已满，事件被丢弃")
+
 // HoneypotLogger 蜜罐专用日志记录器
+// 调用方只负责把事件放入无锁队列，由后台协程批量写入文件并按批刷新
 type HoneypotLogger struct {
 	config    *config.HoneypotLoggingConfig
 	writer    io.Writer
+	buffer    *bufio.Writer
 	csvWriter *csv.Writer
-	mutex     sync.Mutex
 	enabled   bool
+
+	// 陷阱世界中的聊天和命令单独写入 commands 日志
+	commandWriter    io.Writer
+	commandBuffer    *bufio.Writer
+	commandCSVWriter *csv.Writer
+
+	// 事件同时投递到的其他目标，各自缓冲，不阻塞 LogEvent
+	sinks []*asyncSink
+
+	// 后台协程写入事件前依次调用的补充函数，以及写入后通知的观察者
+	enrichers  []func(*HoneypotEvent)
+	observers  []func(*HoneypotEvent)
+	observerMu sync.Mutex
+
+	// 异步写入队列
+	queue      *ringQueue
+	dropPolicy string
+	batchSize  int
+	wake       chan struct{}      // 有新事件入队
+	space      chan struct{}      // 后台协程取走了事件，block 策略的调用方可以重试
+	flushReq   chan chan struct{} // Flush 请求
+	stop       chan struct{}
+	done       chan struct{}
+	closed     atomic.Bool
+	closeOnce  sync.Once
+
+	// 统计
+	enqueued    atomic.Uint64
+	written     atomic.Uint64
+	dropped     atomic.Uint64
+	writeErrors atomic.Uint64
 }
 
 // NewHoneypotLogger 创建蜜罐日志记录器
@@ -54,56 +136,258 @@ func NewHoneypotLogger(cfg *config.HoneypotLoggingConfig) (*HoneypotLogger, erro
 	}
 
 	// 配置日志轮转
-	fileWriter := &lumberjack.Logger{
-		Filename:   cfg.FilePath,
-		MaxSize:    cfg.MaxSize,
-		MaxBackups: cfg.MaxBackups,
-		MaxAge:     cfg.MaxAge,
-		Compress:   cfg.Compress,
-	}
+	fileWriter := newRotatingWriter(cfg, cfg.FilePath)
+	commandWriter := newRotatingWriter(cfg, commandsFilePath(cfg))
 
-	logger := &HoneypotLogger{
-		config:  cfg,
-		writer:  fileWriter,
-		enabled: true,
-	}
+	logger := newHoneypotLogger(cfg, fileWriter, commandWriter)
 
 	// 如果是CSV格式，初始化CSV写入器并写入表头
 	if strings.ToLower(cfg.Format) == "csv" {
-		logger.csvWriter = csv.NewWriter(fileWriter)
+		logger.csvWriter = csv.NewWriter(logger.buffer)
 		if err := logger.writeCSVHeader(); err != nil {
 			return nil, fmt.Errorf("写入CSV表头失败: %w", err)
 		}
+		logger.commandCSVWriter = csv.NewWriter(logger.commandBuffer)
+		if err := logger.writeCommandCSVHeader(); err != nil {
+			return nil, fmt.Errorf("写入CSV表头失败: %w", err)
+		}
+		if err := logger.flush(); err != nil {
+			return nil, fmt.Errorf("写入CSV表头失败: %w", err)
+		}
 	}
 
+	for i, sinkCfg := range cfg.Sinks {
+		sink, err := newSink(sinkCfg)
+		if err != nil {
+			logger.closeSinks()
+			fileWriter.Close()
+			commandWriter.Close()
+			return nil, fmt.Errorf("创建蜜罐事件投递目标 #%d 失败: %w", i+1, err)
+		}
+		logger.sinks = append(logger.sinks, newAsyncSink(sinkCfg, sink))
+	}
+
+	go logger.run()
 	return logger, nil
 }
 
+// NewHoneypotWriterLogger 创建以 JSON 格式写入指定 io.Writer 的蜜罐日志记录器（不轮转）
+// 事件与命令写入同一个 Writer，用于回放等需要直接查看事件的场景
+func NewHoneypotWriterLogger(w io.Writer) *HoneypotLogger {
+	logger := newHoneypotLogger(&config.HoneypotLoggingConfig{Enabled: true, Format: "json"}, w, w)
+	go logger.run()
+	return logger
+}
+
+// newHoneypotLogger 初始化写入器和异步队列，由调用方启动后台协程
+func newHoneypotLogger(cfg *config.HoneypotLoggingConfig, writer, commandWriter io.Writer) *HoneypotLogger {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	dropPolicy := cfg.DropPolicy
+	if dropPolicy == "" {
+		dropPolicy = DropOldest
+	}
+
+	return &HoneypotLogger{
+		config:        cfg,
+		writer:        writer,
+		buffer:        bufio.NewWriterSize(writer, 64*1024),
+		enabled:       true,
+		commandWriter: commandWriter,
+		commandBuffer: bufio.NewWriter(commandWriter),
+		queue:         newRingQueue(queueSize),
+		dropPolicy:    dropPolicy,
+		batchSize:     batchSize,
+		wake:          make(chan struct{}, 1),
+		space:         make(chan struct{}, 1),
+		flushReq:      make(chan chan struct{}),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// newRotatingWriter 按蜜罐日志的轮转参数创建文件写入器
+func newRotatingWriter(cfg *config.HoneypotLoggingConfig, path string) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
+	}
+}
+
 // writeCSVHeader 写入CSV表头（优化版）
 func (hl *HoneypotLogger) writeCSVHeader() error {
-	headers := []string{
+	return hl.csvWriter.Write(EventCSVHeader())
+}
+
+// EventCSVHeader 蜜罐事件 CSV 表头，与 EventCSVRecord 的列一一对应
+func EventCSVHeader() []string {
+	return []string{
 		"timestamp", "client_ip", "event_type",
 		"protocol_version", "server_address", "server_port", "next_state",
 		"username", "delay_applied_ms", "ip_frequency",
 		"error_message", "user_agent", "geo_location",
+		"forwarded_ip", "forwarded_uuid", "mod_loader",
+		"client_type", "fingerprint", "labels", "key_exchange",
+		"message", "channel", "payload_size", "payload_hash",
+		"session", "country", "asn", "as_org",
+		"reverse_dns", "provider",
 	}
-	return hl.csvWriter.Write(headers)
 }
 
-// LogEvent 记录蜜罐事件
+// LogEvent 记录蜜罐事件；事件被复制后放入队列，调用方可继续复用
 func (hl *HoneypotLogger) LogEvent(event *HoneypotEvent) error {
 	if !hl.enabled {
 		return nil
 	}
 
-	hl.mutex.Lock()
-	defer hl.mutex.Unlock()
-
 	// 设置时间戳
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
 
+	snapshot := *event
+	return hl.enqueue(queueItem{event: &snapshot})
+}
+
+// enqueue 按丢弃策略入队
+func (hl *HoneypotLogger) enqueue(item queueItem) error {
+	if hl.closed.Load() {
+		return nil
+	}
+
+	for !hl.queue.push(item) {
+		switch hl.dropPolicy {
+		case DropNewest:
+			hl.dropped.Add(1)
+			return ErrEventDropped
+		case Block:
+			select {
+			case <-hl.space:
+			case <-hl.done:
+				return nil
+			}
+		default: // drop_oldest
+			if _, ok := hl.queue.pop(); ok {
+				hl.dropped.Add(1)
+			}
+		}
+	}
+	hl.enqueued.Add(1)
+
+	select {
+	case hl.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// run 后台写入协程
+func (hl *HoneypotLogger) run() {
+	defer close(hl.done)
+	for {
+		select {
+		case <-hl.wake:
+			hl.drain()
+		case req := <-hl.flushReq:
+			hl.drain()
+			close(req)
+		case <-hl.stop:
+			hl.drain()
+			return
+		}
+	}
+}
+
+// drain 取出队列中的全部事件，每批写入后刷新一次
+func (hl *HoneypotLogger) drain() {
+	batch := make([]queueItem, 0, hl.batchSize)
+	for {
+		batch = batch[:0]
+		for len(batch) < hl.batchSize {
+			item, ok := hl.queue.pop()
+			if !ok {
+				break
+			}
+			batch = append(batch, item)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		select {
+		case hl.space <- struct{}{}:
+		default:
+		}
+
+		hl.observerMu.Lock()
+		enrichers, observers := hl.enrichers, hl.observers
+		hl.observerMu.Unlock()
+
+		for _, item := range batch {
+			var err error
+			if item.event != nil {
+				for _, enrich := range enrichers {
+					enrich(item.event)
+				}
+				err = hl.writeEvent(item.event)
+				for _, sink := range hl.sinks {
+					sink.enqueue(item.event)
+				}
+				for _, observe := range observers {
+					observe(item.event)
+				}
+			} else {
+				err = hl.writeCommand(item.command)
+			}
+			if err != nil {
+				hl.recordWriteError(err)
+			}
+		}
+		if err := hl.flush(); err != nil {
+			hl.recordWriteError(err)
+		}
+		hl.written.Add(uint64(len(batch)))
+	}
+}
+
+// AddObserver 注册事件观察者，观察者在后台写入协程中调用，不应阻塞或修改事件
+func (hl *HoneypotLogger) AddObserver(observe func(*HoneypotEvent)) {
+	if !hl.enabled {
+		return
+	}
+	hl.observerMu.Lock()
+	defer hl.observerMu.Unlock()
+	hl.observers = append(slices.Clip(hl.observers), observe)
+}
+
+// AddEnricher 注册事件补充函数，在后台写入协程中、写入文件和通知观察者之前调用
+// 耗时的查询（如 GeoIP）放在这里，不占用连接处理协程
+func (hl *HoneypotLogger) AddEnricher(enrich func(*HoneypotEvent)) {
+	if !hl.enabled {
+		return
+	}
+	hl.observerMu.Lock()
+	defer hl.observerMu.Unlock()
+	hl.enrichers = append(slices.Clip(hl.enrichers), enrich)
+}
+
+// recordWriteError 统计写入错误，每 1000 次记录一条日志
+func (hl *HoneypotLogger) recordWriteError(err error) {
+	if n := hl.writeErrors.Add(1); n == 1 || n%1000 == 0 {
+		log.Error().Err(err).Uint64("write_errors", n).Msg("写入蜜罐日志失败")
+	}
+}
+
+// writeEvent 按配置格式写入一条事件
+func (hl *HoneypotLogger) writeEvent(event *HoneypotEvent) error {
 	switch strings.ToLower(hl.config.Format) {
 	case "csv":
 		return hl.writeCSV(event)
@@ -112,6 +396,53 @@ func (hl *HoneypotLogger) LogEvent(event *HoneypotEvent) error {
 	}
 }
 
+// flush 将缓冲区写入文件
+func (hl *HoneypotLogger) flush() error {
+	if hl.csvWriter != nil {
+		hl.csvWriter.Flush()
+	}
+	if hl.commandCSVWriter != nil {
+		hl.commandCSVWriter.Flush()
+	}
+	return errors.Join(hl.buffer.Flush(), hl.commandBuffer.Flush())
+}
+
+// Flush 等待已入队的事件全部写入文件
+func (hl *HoneypotLogger) Flush() {
+	if !hl.enabled {
+		return
+	}
+	req := make(chan struct{})
+	select {
+	case hl.flushReq <- req:
+		<-req
+	case <-hl.done:
+	}
+}
+
+// GetStats 获取异步写入队列的统计信息
+func (hl *HoneypotLogger) GetStats() map[string]any {
+	if !hl.enabled {
+		return map[string]any{"enabled": false}
+	}
+
+	var sinkDropped uint64
+	for _, sink := range hl.sinks {
+		sinkDropped += sink.dropped.Load()
+	}
+	return map[string]any{
+		"enabled":             true,
+		"queue_length":        hl.queue.len(),
+		"queue_capacity":      hl.queue.capacity(),
+		"drop_policy":         hl.dropPolicy,
+		"enqueued_events":     hl.enqueued.Load(),
+		"written_events":      hl.written.Load(),
+		"dropped_events":      hl.dropped.Load(),
+		"write_errors":        hl.writeErrors.Load(),
+		"sink_dropped_events": sinkDropped,
+	}
+}
+
 // writeJSON 写入JSON格式
 func (hl *HoneypotLogger) writeJSON(event *HoneypotEvent) error {
 	data, err := sonic.Marshal(event)
@@ -119,13 +450,19 @@ func (hl *HoneypotLogger) writeJSON(event *HoneypotEvent) error {
 		return fmt.Errorf("序列化蜜罐事件失败: %w", err)
 	}
 
-	_, err = hl.writer.Write(append(data, '\n'))
-	return err
+	hl.buffer.Write(data)
+	return hl.buffer.WriteByte('\n')
 }
 
 // writeCSV 写入CSV格式（优化版）
 func (hl *HoneypotLogger) writeCSV(event *HoneypotEvent) error {
-	record := []string{
+	// 按批刷新，见 flush
+	return hl.csvWriter.Write(EventCSVRecord(event))
+}
+
+// EventCSVRecord 将事件转换为一行 CSV
+func EventCSVRecord(event *HoneypotEvent) []string {
+	return []string{
 		event.Timestamp.Format(time.RFC3339),
 		event.ClientIP,
 		event.EventType,
@@ -139,15 +476,36 @@ func (hl *HoneypotLogger) writeCSV(event *HoneypotEvent) error {
 		event.ErrorMessage,
 		event.UserAgent,
 		event.GeoLocation,
+		event.ForwardedIP,
+		event.ForwardedUUID,
+		event.ModLoader,
+		event.ClientType,
+		event.Fingerprint,
+		strings.Join(event.Labels, "|"),
+		event.KeyExchange,
+		event.Message,
+		event.Channel,
+		fmt.Sprintf("%d", event.PayloadSize),
+		event.PayloadHash,
+		sessionJSON(event.Session),
+		event.Country,
+		fmt.Sprintf("%d", event.ASN),
+		event.ASOrg,
+		event.ReverseDNS,
+		event.Provider,
 	}
+}
 
-	if err := hl.csvWriter.Write(record); err != nil {
-		return err
+// sessionJSON CSV 中会话摘要以 JSON 字符串保存
+func sessionJSON(session *AttackSession) string {
+	if session == nil {
+		return ""
 	}
-
-	// 立即刷新到文件
-	hl.csvWriter.Flush()
-	return hl.csvWriter.Error()
+	data, err := sonic.MarshalString(session)
+	if err != nil {
+		return ""
+	}
+	return data
 }
 
 // LogConnection 记录连接事件（优化版：不记录connID）
@@ -203,24 +561,37 @@ func (hl *HoneypotLogger) LogProtocolViolation(clientIP, errorMsg string) error
 	})
 }
 
-// Close 关闭日志记录器
+// Close 写完队列中剩余的事件后关闭日志记录器
 func (hl *HoneypotLogger) Close() error {
 	if !hl.enabled {
 		return nil
 	}
 
-	hl.mutex.Lock()
-	defer hl.mutex.Unlock()
+	var err error
+	hl.closeOnce.Do(func() {
+		hl.closed.Store(true)
+		close(hl.stop)
+		<-hl.done
 
-	if hl.csvWriter != nil {
-		hl.csvWriter.Flush()
-	}
+		// 后台协程退出后再关闭投递目标，等待缓冲中的事件发出
+		hl.closeSinks()
 
-	if closer, ok := hl.writer.(io.Closer); ok {
-		return closer.Close()
-	}
+		err = hl.flush()
+		if closer, ok := hl.commandWriter.(io.Closer); ok {
+			closer.Close()
+		}
+		if closer, ok := hl.writer.(io.Closer); ok {
+			err = errors.Join(err, closer.Close())
+		}
+	})
+	return err
+}
 
-	return nil
+// closeSinks 关闭所有投递目标
+func (hl *HoneypotLogger) closeSinks() {
+	for _, sink := range hl.sinks {
+		sink.Close()
+	}
 }
 
 // IsEnabled 检查是否启用
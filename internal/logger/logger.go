@@ -238,6 +238,14 @@ func (pl *PerformanceLogger) LogRateLimitMetrics(globalRequests, totalRequests i
 		Msg("限流指标")
 }
 
+// LogHoneypotQueueMetrics 记录蜜罐日志异步队列指标
+func (pl *PerformanceLogger) LogHoneypotQueueMetrics(stats map[string]any) {
+	pl.logger.Info().
+		Str("metric_type", "honeypot_queue_metrics").
+		Fields(stats).
+		Msg("蜜罐日志队列指标")
+}
+
 // SecurityLogger 安全日志记录器
 type SecurityLogger struct {
 	logger zerolog.Logger
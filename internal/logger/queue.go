@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"sync/atomic"
+)
+
+// 队列写满时的处理策略
+const (
+	DropOldest = "drop_oldest" // 丢弃最早的事件，保留最新的
+	DropNewest = "drop_newest" // 丢弃新事件
+	Block      = "block"       // 阻塞调用方直到有空位
+)
+
+// queueItem 队列元素，事件与命令共用一个队列以保持写入顺序
+type queueItem struct {
+	event   *HoneypotEvent
+	command *CommandEvent
+}
+
+// ringCell 环形队列槽位，seq 标记槽位当前可写入还是可读取
+type ringCell struct {
+	seq  atomic.Uint64
+	item queueItem
+}
+
+// ringQueue 有界无锁多生产者多消费者环形队列（Vyukov 算法）
+// 生产者是各连接处理协程，消费者是后台写入协程；丢弃最早事件时生产者也会出队
+type ringQueue struct {
+	mask  uint64
+	cells []ringCell
+	_     [56]byte // 隔开读写位置，避免伪共享
+	head  atomic.Uint64
+	_     [56]byte
+	tail  atomic.Uint64
+}
+
+// newRingQueue 创建环形队列，容量向上取整到 2 的幂
+func newRingQueue(capacity int) *ringQueue {
+	size := uint64(2)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+	q := &ringQueue{
+		mask:  size - 1,
+		cells: make([]ringCell, size),
+	}
+	for i := range q.cells {
+		q.cells[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+// push 入队，队列已满时返回 false
+func (q *ringQueue) push(item queueItem) bool {
+	pos := q.head.Load()
+	for {
+		cell := &q.cells[pos&q.mask]
+		seq := cell.seq.Load()
+		switch diff := int64(seq) - int64(pos); {
+		case diff == 0:
+			if q.head.CompareAndSwap(pos, pos+1) {
+				cell.item = item
+				cell.seq.Store(pos + 1)
+				return true
+			}
+			pos = q.head.Load()
+		case diff < 0:
+			return false
+		default:
+			pos = q.head.Load()
+		}
+	}
+}
+
+// pop 出队，队列为空时返回 false
+func (q *ringQueue) pop() (queueItem, bool) {
+	pos := q.tail.Load()
+	for {
+		cell := &q.cells[pos&q.mask]
+		seq := cell.seq.Load()
+		switch diff := int64(seq) - int64(pos+1); {
+		case diff == 0:
+			if q.tail.CompareAndSwap(pos, pos+1) {
+				item := cell.item
+				cell.item = queueItem{}
+				cell.seq.Store(pos + q.mask + 1)
+				return item, true
+			}
+			pos = q.tail.Load()
+		case diff < 0:
+			return queueItem{}, false
+		default:
+			pos = q.tail.Load()
+		}
+	}
+}
+
+// len 当前排队数量（并发下为近似值）
+func (q *ringQueue) len() int {
+	head, tail := q.head.Load(), q.tail.Load()
+	if head < tail {
+		return 0
+	}
+	return int(head - tail)
+}
+
+// capacity 队列容量
+func (q *ringQueue) capacity() int {
+	return len(q.cells)
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"fake-mc-server/internal/config"
+)
+
+func TestRingQueueConcurrent(t *testing.T) {
+	q := newRingQueue(1000)
+	if q.capacity() != 1024 {
+		t.Fatalf("capacity() = %d, want 1024", q.capacity())
+	}
+
+	var wg sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 256; i++ {
+				if !q.push(queueItem{event: &HoneypotEvent{}}) {
+					t.Error("队列未满时 push() 不应失败")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if q.push(queueItem{}) {
+		t.Error("队列已满时 push() 应失败")
+	}
+	n := 0
+	for {
+		if _, ok := q.pop(); !ok {
+			break
+		}
+		n++
+	}
+	if n != 1024 || q.len() != 0 {
+		t.Errorf("取出 %d 条, 剩余 %d, want 1024, 0", n, q.len())
+	}
+}
+
+func TestDropPolicies(t *testing.T) {
+	// 不启动后台协程，队列只进不出
+	newLogger := func(policy string) *HoneypotLogger {
+		return newHoneypotLogger(&config.HoneypotLoggingConfig{QueueSize: 2, DropPolicy: policy}, &bytes.Buffer{}, &bytes.Buffer{})
+	}
+
+	t.Run("drop_oldest", func(t *testing.T) {
+		hl := newLogger(DropOldest)
+		for _, ip := range []string{"1", "2", "3"} {
+			if err := hl.LogEvent(&HoneypotEvent{ClientIP: ip}); err != nil {
+				t.Fatalf("LogEvent() error = %v", err)
+			}
+		}
+		var got []string
+		for {
+			item, ok := hl.queue.pop()
+			if !ok {
+				break
+			}
+			got = append(got, item.event.ClientIP)
+		}
+		if strings.Join(got, ",") != "2,3" || hl.dropped.Load() != 1 {
+			t.Errorf("队列 = %v, 丢弃 %d, want [2 3], 1", got, hl.dropped.Load())
+		}
+	})
+
+	t.Run("drop_newest", func(t *testing.T) {
+		hl := newLogger(DropNewest)
+		hl.LogEvent(&HoneypotEvent{})
+		hl.LogEvent(&HoneypotEvent{})
+		if err := hl.LogEvent(&HoneypotEvent{}); !errors.Is(err, ErrEventDropped) {
+			t.Errorf("LogEvent() error = %v, want ErrEventDropped", err)
+		}
+		if stats := hl.GetStats(); stats["dropped_events"] != uint64(1) || stats["queue_length"] != 2 {
+			t.Errorf("GetStats() = %v", stats)
+		}
+	})
+}
+
+func TestAsyncWriteAndFlush(t *testing.T) {
+	var buf bytes.Buffer
+	hl := NewHoneypotWriterLogger(&buf)
+	for i := 0; i < 1000; i++ {
+		hl.LogEvent(&HoneypotEvent{EventType: "connection"})
+	}
+	hl.Flush()
+	if n := strings.Count(buf.String(), "\n"); n != 1000 {
+		t.Errorf("写入 %d 行, want 1000", n)
+	}
+	if err := hl.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := hl.LogEvent(&HoneypotEvent{}); err != nil {
+		t.Errorf("关闭后 LogEvent() error = %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// ReadEvents 逐条读取 JSON Lines 或 CSV 格式的蜜罐日志，格式按首个非空白字符判断
+// CSV 按表头列名取值，旧版本日志缺少的列保持零值
+func ReadEvents(r io.Reader, fn func(*HoneypotEvent) error) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+			continue
+		case '{':
+			return readJSONEvents(br, fn)
+		default:
+			return readCSVEvents(br, fn)
+		}
+	}
+}
+
+// readJSONEvents 读取 JSON Lines，跳过空行
+func readJSONEvents(r *bufio.Reader, fn func(*HoneypotEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		event := &HoneypotEvent{}
+		if err := sonic.Unmarshal(data, event); err != nil {
+			return fmt.Errorf("第 %d 行解析失败: %w", line, err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readCSVEvents 读取带表头的 CSV
+func readCSVEvents(r io.Reader, fn func(*HoneypotEvent) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("读取CSV表头失败: %w", err)
+	}
+	header = append([]string(nil), header...)
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		event, err := ParseEventCSV(header, record)
+		if err != nil {
+			return fmt.Errorf("第 %d 行解析失败: %w", line, err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// ParseEventCSV 按表头将一行 CSV 还原为事件，是 EventCSVRecord 的逆操作
+func ParseEventCSV(header, record []string) (*HoneypotEvent, error) {
+	event := &HoneypotEvent{}
+	for i, name := range header {
+		if i >= len(record) || record[i] == "" {
+			continue
+		}
+		v := record[i]
+		var err error
+		switch name {
+		case "timestamp":
+			event.Timestamp, err = time.Parse(time.RFC3339, v)
+		case "client_ip":
+			event.ClientIP = v
+		case "event_type":
+			event.EventType = v
+		case "protocol_version":
+			event.ProtocolVersion, err = strconv.Atoi(v)
+		case "server_address":
+			event.ServerAddress = v
+		case "server_port":
+			var port uint64
+			port, err = strconv.ParseUint(v, 10, 16)
+			event.ServerPort = uint16(port)
+		case "next_state":
+			event.NextState, err = strconv.Atoi(v)
+		case "username":
+			event.Username = v
+		case "delay_applied_ms":
+			event.DelayApplied, err = strconv.ParseInt(v, 10, 64)
+		case "ip_frequency":
+			event.IPFrequency, err = strconv.ParseFloat(v, 64)
+		case "error_message":
+			event.ErrorMessage = v
+		case "user_agent":
+			event.UserAgent = v
+		case "geo_location":
+			event.GeoLocation = v
+		case "forwarded_ip":
+			event.ForwardedIP = v
+		case "forwarded_uuid":
+			event.ForwardedUUID = v
+		case "mod_loader":
+			event.ModLoader = v
+		case "client_type":
+			event.ClientType = v
+		case "fingerprint":
+			event.Fingerprint = v
+		case "labels":
+			event.Labels = strings.Split(v, "|")
+		case "key_exchange":
+			event.KeyExchange = v
+		case "message":
+			event.Message = v
+		case "channel":
+			event.Channel = v
+		case "payload_size":
+			event.PayloadSize, err = strconv.Atoi(v)
+		case "payload_hash":
+			event.PayloadHash = v
+		case "session":
+			event.Session = &AttackSession{}
+			err = sonic.UnmarshalString(v, event.Session)
+		case "country":
+			event.Country = v
+		case "asn":
+			var asn uint64
+			asn, err = strconv.ParseUint(v, 10, 32)
+			event.ASN = uint32(asn)
+		case "as_org":
+			event.ASOrg = v
+		case "reverse_dns":
+			event.ReverseDNS = v
+		case "provider":
+			event.Provider = v
+		}
+		if err != nil {
+			return nil, fmt.Errorf("列 %s: %w", name, err)
+		}
+	}
+	return event, nil
+}
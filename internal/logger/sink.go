@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"fake-mc-server/internal/config"
+)
+
+// sinkCloseTimeout 关闭时等待缓冲事件投递完成的最长时间，超时后中止重试
+const sinkCloseTimeout = 5 * time.Second
+
+// Sink 蜜罐事件投递目标
+type Sink interface {
+	// Write 投递一批事件；ctx 取消时应尽快返回
+	Write(ctx context.Context, events []*HoneypotEvent) error
+	Close() error
+}
+
+// SinkFactory 按配置创建投递目标
+type SinkFactory func(cfg config.EventSinkConfig) (Sink, error)
+
+// sinkFactories 其他包注册的投递目标类型
+var (
+	sinkFactories   = make(map[string]SinkFactory)
+	sinkFactoriesMu sync.RWMutex
+)
+
+// RegisterSink 注册由其他包实现的投递目标类型（如 store 包的 sqlite），通常在 init 中调用
+func RegisterSink(sinkType string, factory SinkFactory) {
+	sinkFactoriesMu.Lock()
+	defer sinkFactoriesMu.Unlock()
+	sinkFactories[sinkType] = factory
+}
+
+// newSink 按配置创建投递目标
+func newSink(cfg config.EventSinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case "syslog":
+		return newSyslogSink(cfg)
+	case "webhook":
+		return newWebhookSink(cfg)
+	case "unix":
+		return newUnixSink(cfg)
+	}
+
+	sinkFactoriesMu.RLock()
+	factory, ok := sinkFactories[cfg.Type]
+	sinkFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的事件投递类型: %q", cfg.Type)
+	}
+	return factory(cfg)
+}
+
+// asyncSink 为投递目标提供独立缓冲和后台投递，缓冲写满时丢弃事件而不阻塞调用方
+type asyncSink struct {
+	name          string
+	sink          Sink
+	events        chan *HoneypotEvent
+	batchSize     int
+	flushInterval time.Duration
+
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newAsyncSink 包装投递目标并启动后台投递
+func newAsyncSink(cfg config.EventSinkConfig, sink Sink) *asyncSink {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &asyncSink{
+		name:          cfg.Type,
+		sink:          sink,
+		events:        make(chan *HoneypotEvent, cfg.BufferSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// enqueue 非阻塞地放入事件
+func (s *asyncSink) enqueue(event *HoneypotEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		if n := s.dropped.Add(1); n == 1 || n%1000 == 0 {
+			log.Warn().Str("sink", s.name).Uint64("dropped", n).Msg("蜜罐事件投递缓冲已满，丢弃事件")
+		}
+	}
+}
+
+// run 攒批并投递，直到缓冲关闭
+func (s *asyncSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*HoneypotEvent, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.sink.Write(s.ctx, batch); err != nil {
+			log.Warn().Err(err).Str("sink", s.name).Int("events", len(batch)).Msg("蜜罐事件投递失败")
+		}
+		batch = make([]*HoneypotEvent, 0, s.batchSize)
+	}
+
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Close 投递剩余事件后关闭目标
+func (s *asyncSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.events)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(sinkCloseTimeout):
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+	return s.sink.Close()
+}
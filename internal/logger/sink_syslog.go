@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"fake-mc-server/internal/config"
+)
+
+// syslogFacilities RFC 5424 设施代码
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslog 严重级别
+const (
+	syslogWarning = 4
+	syslogInfo    = 6
+)
+
+// syslogSink 以 RFC 5424 格式发送事件，流式传输使用 RFC 6587 的长度前缀分帧
+type syslogSink struct {
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	pid      string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool // 是否为流式连接（tcp 或 unix stream）
+}
+
+// newSyslogSink 创建 syslog 投递目标，连接在首次发送时建立
+func newSyslogSink(cfg config.EventSinkConfig) (*syslogSink, error) {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("未知的 syslog facility: %s", cfg.Facility)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &syslogSink{
+		network:  cfg.Network,
+		address:  cfg.Address,
+		facility: facility,
+		tag:      cfg.Tag,
+		hostname: hostname,
+		pid:      strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// dial 建立连接；unix 优先使用数据报套接字，与本地 syslog 守护进程的习惯一致
+func (s *syslogSink) dial() error {
+	const timeout = 5 * time.Second
+	switch s.network {
+	case "unix":
+		conn, err := net.DialTimeout("unixgram", s.address, timeout)
+		if err == nil {
+			s.conn, s.stream = conn, false
+			return nil
+		}
+		conn, err = net.DialTimeout("unix", s.address, timeout)
+		if err != nil {
+			return fmt.Errorf("连接 syslog 失败: %w", err)
+		}
+		s.conn, s.stream = conn, true
+	default:
+		conn, err := net.DialTimeout(s.network, s.address, timeout)
+		if err != nil {
+			return fmt.Errorf("连接 syslog 失败: %w", err)
+		}
+		s.conn, s.stream = conn, s.network == "tcp"
+	}
+	return nil
+}
+
+// format 生成一条 RFC 5424 消息，MSG 部分为事件 JSON
+func (s *syslogSink) format(event *HoneypotEvent) ([]byte, error) {
+	data, err := sonic.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("序列化蜜罐事件失败: %w", err)
+	}
+
+	severity := syslogInfo
+	switch event.EventType {
+	case "protocol_violation", "access_denied":
+		severity = syslogWarning
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %s %s - ",
+		s.facility*8+severity,
+		event.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, s.tag, s.pid, event.EventType)
+	msg := append([]byte(header), data...)
+
+	if s.stream {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return msg, nil
+}
+
+// Write 逐条发送事件，写入失败时重连一次
+func (s *syslogSink) Write(ctx context.Context, events []*HoneypotEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		if err := s.send(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send 发送单条事件
+func (s *syslogSink) send(event *HoneypotEvent) error {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err := s.dial(); err != nil {
+				return err
+			}
+		}
+		msg, err := s.format(event)
+		if err != nil {
+			return err
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if _, err := s.conn.Write(msg); err != nil {
+			lastErr = err
+			s.conn.Close()
+			s.conn = nil
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("发送 syslog 消息失败: %w", lastErr)
+}
+
+// Close 关闭连接
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
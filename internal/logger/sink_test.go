@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"fake-mc-server/internal/config"
+)
+
+func TestSyslogSinkFormat(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听 UDP 失败: %v", err)
+	}
+	defer pc.Close()
+
+	sink, err := newSyslogSink(config.EventSinkConfig{
+		Type: "syslog", Network: "udp", Address: pc.LocalAddr().String(),
+		Facility: "local0", Tag: "fake-mc-server",
+	})
+	if err != nil {
+		t.Fatalf("newSyslogSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	event := &HoneypotEvent{Timestamp: time.Now(), ClientIP: "192.0.2.1", EventType: "protocol_violation"}
+	if err := sink.Write(context.Background(), []*HoneypotEvent{event}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("读取 syslog 消息失败: %v", err)
+	}
+	msg := string(buf[:n])
+	// local0(16)*8 + warning(4) = 132
+	if !strings.HasPrefix(msg, "<132>1 ") || !strings.Contains(msg, " fake-mc-server ") || !strings.Contains(msg, ` protocol_violation - {"timestamp"`) {
+		t.Errorf("syslog 消息格式错误: %q", msg)
+	}
+}
+
+func TestWebhookSinkSpoolsAndDrains(t *testing.T) {
+	var healthy atomic.Bool
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var events []HoneypotEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			t.Errorf("请求体不是事件数组: %v", err)
+		}
+		received.Add(int32(len(events)))
+	}))
+	defer server.Close()
+
+	spool := filepath.Join(t.TempDir(), "spool.jsonl")
+	sink, err := newWebhookSink(config.EventSinkConfig{
+		Type: "webhook", URL: server.URL, MaxRetries: 0, Timeout: time.Second, SpoolPath: spool,
+	})
+	if err != nil {
+		t.Fatalf("newWebhookSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	batch := []*HoneypotEvent{{EventType: "connection"}, {EventType: "handshake"}}
+	if err := sink.Write(context.Background(), batch); err == nil {
+		t.Fatal("端点不可用时 Write() 应返回错误")
+	}
+	if data, _ := os.ReadFile(spool); len(data) == 0 {
+		t.Fatal("失败的批次应写入暂存文件")
+	}
+
+	healthy.Store(true)
+	if err := sink.Write(context.Background(), batch[:1]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := received.Load(); got != 3 {
+		t.Errorf("端点收到 %d 条事件, want 3", got)
+	}
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Errorf("补发后暂存文件应被删除, err = %v", err)
+	}
+}
+
+func TestWebhookSinkSpoolLimit(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// 恢复后仍拒绝 status_query 批次，用于检查补发中断时的保留部分
+		if !healthy.Load() || strings.Contains(string(body), "status_query") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	spool := filepath.Join(t.TempDir(), "spool.jsonl")
+	line := func(eventType string) string {
+		body, _ := sonic.Marshal([]*HoneypotEvent{{EventType: eventType}})
+		return string(body) + "\n"
+	}
+	sink, err := newWebhookSink(config.EventSinkConfig{
+		Type: "webhook", URL: server.URL, MaxRetries: 0, Timeout: time.Second, SpoolPath: spool,
+		MaxSpoolBytes: int64(len(line("handshake")) + len(line("status_query"))),
+	})
+	if err != nil {
+		t.Fatalf("newWebhookSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	for _, eventType := range []string{"connection", "handshake", "status_query"} {
+		sink.Write(context.Background(), []*HoneypotEvent{{EventType: eventType}})
+	}
+	if data, _ := os.ReadFile(spool); string(data) != line("handshake")+line("status_query") {
+		t.Fatalf("超过上限后应丢弃最早的批次, 暂存文件 = %q", data)
+	}
+
+	healthy.Store(true)
+	if err := sink.Write(context.Background(), []*HoneypotEvent{{EventType: "connection"}}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if data, _ := os.ReadFile(spool); string(data) != line("status_query") {
+		t.Errorf("补发中断后应保留未发出的批次, 暂存文件 = %q", data)
+	}
+}
@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog/log"
+
+	"fake-mc-server/internal/config"
+)
+
+// unixSinkWriteTimeout 单个客户端的写超时，超时的客户端会被断开以免拖慢其他订阅者
+const unixSinkWriteTimeout = time.Second
+
+// unixSink 在本地 unix 套接字上监听，向所有已连接的客户端推送 JSON Lines 事件流
+type unixSink struct {
+	path     string
+	listener net.Listener
+
+	mu      sync.Mutex
+	clients map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+// newUnixSink 创建 unix 套接字投递目标，已存在的旧套接字文件会被替换
+func newUnixSink(cfg config.EventSinkConfig) (*unixSink, error) {
+	if info, err := os.Lstat(cfg.Path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是套接字文件", cfg.Path)
+		}
+		os.Remove(cfg.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, fmt.Errorf("创建套接字目录失败: %w", err)
+	}
+
+	listener, err := net.Listen("unix", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("监听 unix 套接字失败: %w", err)
+	}
+
+	s := &unixSink{
+		path:     cfg.Path,
+		listener: listener,
+		clients:  make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s, nil
+}
+
+// acceptLoop 接受订阅客户端
+func (s *unixSink) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Warn().Err(err).Str("path", s.path).Msg("接受 unix 套接字连接失败")
+			}
+			return
+		}
+		s.mu.Lock()
+		s.clients[conn] = struct{}{}
+		s.mu.Unlock()
+	}
+}
+
+// Write 向所有客户端推送事件，写入失败的客户端被移除
+func (s *unixSink) Write(ctx context.Context, events []*HoneypotEvent) error {
+	var buf []byte
+	for _, event := range events {
+		data, err := sonic.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("序列化蜜罐事件失败: %w", err)
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		conn.SetWriteDeadline(time.Now().Add(unixSinkWriteTimeout))
+		if _, err := conn.Write(buf); err != nil {
+			conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+	return nil
+}
+
+// Close 停止监听，断开所有客户端并删除套接字文件
+func (s *unixSink) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	for conn := range s.clients {
+		conn.Close()
+	}
+	s.clients = nil
+	s.mu.Unlock()
+
+	os.Remove(s.path)
+	return err
+}
@@ -0,0 +1,217 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"fake-mc-server/internal/config"
+)
+
+// webhookSink 将事件批量以 JSON 数组 POST 到 HTTP 端点
+// 重试用尽的批次按行追加到暂存文件，下次投递成功后补发；暂存文件超过上限时丢弃最早的批次
+type webhookSink struct {
+	url           string
+	headers       map[string]string
+	maxRetries    int
+	spoolPath     string
+	maxSpoolBytes int64
+	client        *http.Client
+}
+
+// newWebhookSink 创建 webhook 投递目标
+func newWebhookSink(cfg config.EventSinkConfig) (*webhookSink, error) {
+	if cfg.SpoolPath != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.SpoolPath), 0755); err != nil {
+			return nil, fmt.Errorf("创建 webhook 暂存目录失败: %w", err)
+		}
+	}
+	return &webhookSink{
+		url:           cfg.URL,
+		headers:       cfg.Headers,
+		maxRetries:    cfg.MaxRetries,
+		spoolPath:     cfg.SpoolPath,
+		maxSpoolBytes: cfg.MaxSpoolBytes,
+		client:        &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Write 投递一批事件，失败时写入暂存文件
+func (s *webhookSink) Write(ctx context.Context, events []*HoneypotEvent) error {
+	body, err := sonic.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("序列化蜜罐事件失败: %w", err)
+	}
+
+	if err := s.postWithRetry(ctx, body); err != nil {
+		return s.spool(body, err)
+	}
+	return s.drainSpool(ctx)
+}
+
+// postWithRetry 按指数退避重试 POST
+func (s *webhookSink) postWithRetry(ctx context.Context, body []byte) error {
+	backoff := time.Second
+	var err error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return fmt.Errorf("%w (已中止重试)", err)
+			}
+		}
+		if err = s.post(ctx, body); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// post 发送一次请求，2xx 视为成功
+func (s *webhookSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建 webhook 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// spool 将投递失败的批次追加到暂存文件，超过大小上限时丢弃最早的批次
+func (s *webhookSink) spool(body []byte, cause error) error {
+	if s.spoolPath == "" {
+		return cause
+	}
+	f, err := os.OpenFile(s.spoolPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("%w; 打开暂存文件失败: %v", cause, err)
+	}
+	_, err = f.Write(append(body, '\n'))
+	var size int64
+	if info, statErr := f.Stat(); statErr == nil {
+		size = info.Size()
+	}
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%w; 写入暂存文件失败: %v", cause, err)
+	}
+
+	if s.maxSpoolBytes > 0 && size > s.maxSpoolBytes {
+		dropped, err := s.trimSpool(size - s.maxSpoolBytes)
+		if err != nil {
+			return fmt.Errorf("%w; 裁剪暂存文件失败: %v", cause, err)
+		}
+		return fmt.Errorf("%w; 已写入暂存文件，超过大小上限丢弃最早的 %d 批", cause, dropped)
+	}
+	return fmt.Errorf("%w; 已写入暂存文件", cause)
+}
+
+// trimSpool 丢弃暂存文件开头至少 excess 字节的完整批次，返回丢弃的批次数
+func (s *webhookSink) trimSpool(excess int64) (int, error) {
+	f, err := os.Open(s.spoolPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var skipped int64
+	dropped := 0
+	for skipped < excess {
+		line, err := r.ReadSlice('\n')
+		skipped += int64(len(line))
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue // 超长的行继续跳过剩余部分
+		}
+		if err != nil {
+			break
+		}
+		dropped++
+	}
+	return dropped, s.replaceSpool(f, r)
+}
+
+// drainSpool 逐行补发暂存文件中的批次，遇到失败时保留该批次及之后的部分
+func (s *webhookSink) drainSpool(ctx context.Context) error {
+	if s.spoolPath == "" {
+		return nil
+	}
+	f, err := os.Open(s.spoolPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取暂存文件失败: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if postErr := s.post(ctx, bytes.TrimSuffix(line, []byte{'\n'})); postErr != nil {
+				// 未发出的批次放回文件开头，其余部分原样保留
+				return s.replaceSpool(f, io.MultiReader(bytes.NewReader(line), r))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取暂存文件失败: %w", err)
+		}
+	}
+	f.Close() // Windows 下不能删除打开中的文件
+	return os.Remove(s.spoolPath)
+}
+
+// replaceSpool 用 r 的剩余内容替换暂存文件，src 为读取中的暂存文件，替换前关闭
+// 先写临时文件再替换，避免中途退出丢失暂存数据
+func (s *webhookSink) replaceSpool(src *os.File, r io.Reader) error {
+	tmp := s.spoolPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("更新暂存文件失败: %w", err)
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("更新暂存文件失败: %w", err)
+	}
+	src.Close()
+	return os.Rename(tmp, s.spoolPath)
+}
+
+// Close 关闭空闲的 HTTP 连接
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
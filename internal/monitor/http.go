@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+)
+
+// HTTPServer 监控端口上的 HTTP 服务，提供健康检查及其他组件注册的接口
+type HTTPServer struct {
+	server   *http.Server
+	mux      *http.ServeMux
+	logger   zerolog.Logger
+	handlers int // 其他组件注册的接口数，为 0 时不监听端口
+}
+
+// NewHTTPServer 创建监控 HTTP 服务，未启用监控时返回 nil
+func NewHTTPServer(cfg *config.Config, logger zerolog.Logger) *HTTPServer {
+	if !cfg.Monitoring.Enabled {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(cfg.Monitoring.HealthCheckPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+
+	return &HTTPServer{
+		server: &http.Server{
+			Addr:              cfg.GetMetricsAddress(),
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		mux:    mux,
+		logger: logger.With().Str("component", "monitor_http").Logger(),
+	}
+}
+
+// Handle 注册接口，需在 Run 之前调用
+func (s *HTTPServer) Handle(pattern string, handler http.Handler) {
+	if s == nil {
+		return
+	}
+	s.mux.Handle(pattern, handler)
+	s.handlers++
+}
+
+// RequireToken 要求请求携带 Authorization: Bearer <token>，token 为空时拒绝所有请求
+func RequireToken(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Run 启动服务，直到 ctx 取消；没有组件注册接口时不监听端口
+func (s *HTTPServer) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	if s.handlers == 0 {
+		s.logger.Debug().Msg("没有组件注册监控接口，不启动监控 HTTP 服务")
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	s.logger.Info().Str("address", s.server.Addr).Msg("监控 HTTP 服务已启动")
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.logger.Error().Err(err).Msg("监控 HTTP 服务错误")
+	}
+}
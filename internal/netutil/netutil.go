@@ -0,0 +1,33 @@
+// Package netutil 网络地址相关的通用函数
+package netutil
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ParsePrefix 解析 CIDR 网段或单个地址，单个地址视为主机前缀
+// IPv4 映射的 IPv6 地址和网段按 IPv4 处理，与连接地址的比较方式保持一致；不接受带 zone 的地址
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if addr.Zone() != "" {
+			return netip.Prefix{}, fmt.Errorf("不支持带 zone 的地址: %s", s)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if addr := prefix.Addr(); addr.Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
@@ -0,0 +1,32 @@
+package netutil
+
+import "testing"
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"10.1.2.3/8", "10.0.0.0/8", false},
+		{"192.0.2.1", "192.0.2.1/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::1/32", "2001:db8::/32", false},
+		{"::ffff:192.0.2.1", "192.0.2.1/32", false},
+		{"::ffff:192.0.2.0/120", "192.0.2.0/24", false},
+		{"fe80::1%eth0", "", true},
+		{"10.0.0.0/33", "", true},
+		{"bad", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrefix(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePrefix(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParsePrefix(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+
+package network
+
+import (
+	"net"
+	"time"
+
+	"github.com/cloudwego/netpoll"
+	"github.com/rs/zerolog"
+)
+
+// memoryConn 将标准库 net.Conn 适配为 netpoll.Connection，用于回放等离线场景
+type memoryConn struct {
+	net.Conn
+	reader netpoll.Reader
+	writer netpoll.Writer
+}
+
+// NewMemoryConnection 基于任意 net.Conn（如 net.Pipe）创建连接，不经过事件循环
+func NewMemoryConnection(id, remoteIP string, conn net.Conn, logger zerolog.Logger) *Connection {
+	return &Connection{
+		Connection: &memoryConn{
+			Conn:   conn,
+			reader: netpoll.NewReader(conn),
+			writer: netpoll.NewWriter(conn),
+		},
+		ID:        id,
+		RemoteIP:  remoteIP,
+		StartTime: time.Now(),
+		Logger:    logger.With().Str("conn_id", id).Str("remote_ip", remoteIP).Logger(),
+		State:     StateHandshaking,
+	}
+}
+
+func (c *memoryConn) Reader() netpoll.Reader                       { return c.reader }
+func (c *memoryConn) Writer() netpoll.Writer                       { return c.writer }
+func (c *memoryConn) IsActive() bool                               { return true }
+func (c *memoryConn) SetReadTimeout(time.Duration) error           { return nil }
+func (c *memoryConn) SetWriteTimeout(time.Duration) error          { return nil }
+func (c *memoryConn) SetIdleTimeout(time.Duration) error           { return nil }
+func (c *memoryConn) SetOnRequest(netpoll.OnRequest) error         { return nil }
+func (c *memoryConn) AddCloseCallback(netpoll.CloseCallback) error { return nil }
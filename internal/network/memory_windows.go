@@ -0,0 +1,22 @@
+//go:build windows
+
+package network
+
+import (
+	"net"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// NewMemoryConnection 基于任意 net.Conn（如 net.Pipe）创建连接，不经过监听器
+func NewMemoryConnection(id, remoteIP string, conn net.Conn, logger zerolog.Logger) *Connection {
+	return &Connection{
+		Conn:      conn,
+		ID:        id,
+		RemoteIP:  remoteIP,
+		StartTime: time.Now(),
+		Logger:    logger.With().Str("conn_id", id).Str("remote_ip", remoteIP).Logger(),
+		State:     StateHandshaking,
+	}
+}
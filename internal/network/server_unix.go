@@ -27,6 +27,9 @@ type Server struct {
 	connections sync.Map // map[string]*Connection
 	connCount   atomic.Int64
 	ctx         context.Context
+
+	acceptFilter AcceptFilter
+	rejected     atomic.Int64 // 被连接过滤器拒绝的连接数
 }
 
 // ConnectionHandler 连接处理器接口
@@ -34,6 +37,9 @@ type ConnectionHandler interface {
 	HandleConnection(ctx context.Context, conn *Connection) error
 }
 
+// AcceptFilter 连接过滤器，在交给处理器之前调用，返回 false 时立即关闭连接
+type AcceptFilter func(ip string) bool
+
 // ConnectionState 连接状态
 type ConnectionState int
 
@@ -52,6 +58,7 @@ type Connection struct {
 	Logger    zerolog.Logger
 	State     ConnectionState
 	stateMu   sync.RWMutex
+	held      atomic.Bool
 }
 
 // GetState 获取连接状态
@@ -68,6 +75,27 @@ func (c *Connection) SetState(state ConnectionState) {
 	c.State = state
 }
 
+// OnClose 注册连接关闭时的回调，无论由哪一方关闭都会调用；已关闭时立即执行，回调需能重复调用
+func (c *Connection) OnClose(fn func()) {
+	c.Connection.AddCloseCallback(func(netpoll.Connection) error {
+		fn()
+		return nil
+	})
+	if !c.Connection.IsActive() {
+		fn()
+	}
+}
+
+// Hold 标记连接已交给焦油坑或延迟调度器，处理器返回后仍保持打开，由持有方写出并关闭
+func (c *Connection) Hold() {
+	c.held.Store(true)
+}
+
+// Held 连接是否已交给后台写出
+func (c *Connection) Held() bool {
+	return c.held.Load()
+}
+
 // NewServer 创建新的服务器 (Unix 版本)
 func NewServer(cfg *config.Config, logger zerolog.Logger, handler ConnectionHandler, ctx context.Context) (*Server, error) {
 	server := &Server{
@@ -188,6 +216,13 @@ func (s *Server) onPrepare(connection netpoll.Connection) context.Context {
 		return nil
 	}
 
+	// 被过滤的连接（如已封禁的 IP）不分配任何资源
+	if s.acceptFilter != nil && !s.acceptFilter(remoteIP) {
+		s.rejected.Add(1)
+		connection.Close()
+		return nil
+	}
+
 	// 创建连接包装器
 	connID := fmt.Sprintf("%s-%d", remoteIP, time.Now().UnixNano())
 	conn := &Connection{
@@ -229,6 +264,14 @@ func (s *Server) onRequest(ctx context.Context, connection netpoll.Connection) e
 		return nil
 	}
 
+	// 已交给焦油坑或延迟调度器的连接不再处理，丢弃客户端之后发送的数据
+	if conn.Held() {
+		reader := connection.Reader()
+		reader.Skip(reader.Len())
+		reader.Release()
+		return nil
+	}
+
 	// 调用处理器
 	if err := s.handler.HandleConnection(ctx, conn); err != nil {
 		conn.Logger.Error().Err(err).Msg("处理连接失败")
@@ -278,9 +321,7 @@ func (s *Server) cleanupExpiredConnections() {
 		if conn, ok := value.(*Connection); ok {
 			if now.Sub(conn.StartTime) > maxIdleTime {
 				conn.Logger.Info().Msg("清理过期连接")
-				conn.Close()
-				s.connections.Delete(key)
-				s.connCount.Add(-1)
+				conn.Close() // 关闭回调负责移除连接
 			}
 		}
 		return true
@@ -290,7 +331,13 @@ func (s *Server) cleanupExpiredConnections() {
 // GetStats 获取服务器统计信息
 func (s *Server) GetStats() map[string]interface{} {
 	return map[string]interface{}{
-		"connection_count": s.connCount.Load(),
-		"running":          s.running.Load(),
+		"connection_count":     s.connCount.Load(),
+		"rejected_connections": s.rejected.Load(),
+		"running":              s.running.Load(),
 	}
 }
+
+// SetAcceptFilter 设置连接过滤器，需在 Start 之前调用
+func (s *Server) SetAcceptFilter(filter AcceptFilter) {
+	s.acceptFilter = filter
+}
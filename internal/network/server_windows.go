@@ -25,6 +25,9 @@ type Server struct {
 	connections sync.Map // map[string]*Connection
 	connCount   atomic.Int64
 	ctx         context.Context
+
+	acceptFilter AcceptFilter
+	rejected     atomic.Int64 // 被连接过滤器拒绝的连接数
 }
 
 // ConnectionHandler 连接处理器接口
@@ -32,6 +35,9 @@ type ConnectionHandler interface {
 	HandleConnection(ctx context.Context, conn *Connection) error
 }
 
+// AcceptFilter 连接过滤器，在交给处理器之前调用，返回 false 时立即关闭连接
+type AcceptFilter func(ip string) bool
+
 // ConnectionState 连接状态
 type ConnectionState int
 
@@ -50,6 +56,11 @@ type Connection struct {
 	Logger    zerolog.Logger
 	State     ConnectionState
 	stateMu   sync.RWMutex
+
+	closeMu sync.Mutex
+	closed  bool
+	onClose []func()
+	held    atomic.Bool
 }
 
 // GetState 获取连接状态
@@ -66,6 +77,48 @@ func (c *Connection) SetState(state ConnectionState) {
 	c.State = state
 }
 
+// OnClose 注册连接关闭时的回调
+// 标准库连接无法感知对端关闭，回调在本端调用 Close 时执行；已关闭时立即执行
+func (c *Connection) OnClose(fn func()) {
+	c.closeMu.Lock()
+	if !c.closed {
+		c.onClose = append(c.onClose, fn)
+		c.closeMu.Unlock()
+		return
+	}
+	c.closeMu.Unlock()
+	fn()
+}
+
+// Close 关闭连接并执行关闭回调
+func (c *Connection) Close() error {
+	c.closeMu.Lock()
+	if c.closed {
+		c.closeMu.Unlock()
+		return nil
+	}
+	c.closed = true
+	callbacks := c.onClose
+	c.onClose = nil
+	c.closeMu.Unlock()
+
+	err := c.Conn.Close()
+	for _, fn := range callbacks {
+		fn()
+	}
+	return err
+}
+
+// Hold 标记连接已交给焦油坑或延迟调度器，处理器返回后仍保持打开，由持有方写出并关闭
+func (c *Connection) Hold() {
+	c.held.Store(true)
+}
+
+// Held 连接是否已交给后台写出
+func (c *Connection) Held() bool {
+	return c.held.Load()
+}
+
 // NewServer 创建新的服务器 (Windows 版本)
 func NewServer(cfg *config.Config, logger zerolog.Logger, handler ConnectionHandler, ctx context.Context) (*Server, error) {
 	server := &Server{
@@ -189,6 +242,13 @@ func (s *Server) handleConnection(conn net.Conn) {
 		return
 	}
 
+	// 被过滤的连接（如已封禁的 IP）不分配任何资源
+	if s.acceptFilter != nil && !s.acceptFilter(remoteIP) {
+		s.rejected.Add(1)
+		conn.Close()
+		return
+	}
+
 	// 创建连接包装器
 	connID := fmt.Sprintf("%s-%d", remoteIP, time.Now().UnixNano())
 	connection := &Connection{
@@ -203,9 +263,12 @@ func (s *Server) handleConnection(conn net.Conn) {
 			Logger(),
 	}
 
-	// 存储连接
+	// 存储连接，连接真正关闭时才移除；交给焦油坑或延迟调度器的连接在处理器返回后仍然占用连接数
 	s.connections.Store(connID, connection)
 	s.connCount.Add(1)
+	connection.OnClose(func() {
+		s.onConnectionClose(connection)
+	})
 
 	// 移除每个连接的建立日志，避免刷屏
 
@@ -216,7 +279,9 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}
 
 	// 清理连接
-	s.onConnectionClose(connection)
+	if !connection.Held() {
+		connection.Close()
+	}
 }
 
 // onConnectionClose 连接关闭回调
@@ -258,9 +323,7 @@ func (s *Server) cleanupExpiredConnections() {
 		if conn, ok := value.(*Connection); ok {
 			if now.Sub(conn.StartTime) > maxIdleTime {
 				conn.Logger.Info().Msg("清理过期连接")
-				conn.Close()
-				s.connections.Delete(key)
-				s.connCount.Add(-1)
+				conn.Close() // 关闭回调负责移除连接
 			}
 		}
 		return true
@@ -270,7 +333,13 @@ func (s *Server) cleanupExpiredConnections() {
 // GetStats 获取服务器统计信息
 func (s *Server) GetStats() map[string]any {
 	return map[string]any{
-		"connection_count": s.connCount.Load(),
-		"running":          s.running.Load(),
+		"connection_count":     s.connCount.Load(),
+		"rejected_connections": s.rejected.Load(),
+		"running":              s.running.Load(),
 	}
 }
+
+// SetAcceptFilter 设置连接过滤器，需在 Start 之前调用
+func (s *Server) SetAcceptFilter(filter AcceptFilter) {
+	s.acceptFilter = filter
+}
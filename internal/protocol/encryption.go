@@ -0,0 +1,152 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/Tnze/go-mc/net"
+	"github.com/Tnze/go-mc/net/CFB8"
+	pk "github.com/Tnze/go-mc/net/packet"
+)
+
+// 密钥交换结果
+const (
+	KeyExchangeCompleted    = "completed"    // 客户端返回了正确的验证令牌，已启用加密
+	KeyExchangeBadToken     = "bad_token"    // 验证令牌不匹配
+	KeyExchangeBadSecret    = "bad_secret"   // 共享密钥无法解密或长度错误
+	KeyExchangeUnexpected   = "unexpected"   // 客户端发送了其他数据包
+	KeyExchangeDisconnected = "disconnected" // 客户端在响应前断开（离线模式机器人或验证失败）
+)
+
+const (
+	// loginEncryptionRequestID 登录阶段加密请求包（客户端方向）
+	loginEncryptionRequestID = 0x01
+	// loginEncryptionResponseID 登录阶段加密响应包（服务端方向）
+	loginEncryptionResponseID = 0x01
+
+	// rsaKeyBits 与原版服务器一致使用 1024 位 RSA 密钥
+	rsaKeyBits = 1024
+	// verifyTokenLen 验证令牌长度
+	verifyTokenLen = 4
+	// sharedSecretLen AES-128 共享密钥长度
+	sharedSecretLen = 16
+)
+
+// loginEncryption 伪造正版验证使用的服务器密钥
+type loginEncryption struct {
+	key       *rsa.PrivateKey
+	publicKey []byte // DER 编码的公钥
+}
+
+// newLoginEncryption 生成服务器 RSA 密钥对
+func newLoginEncryption() (*loginEncryption, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("生成 RSA 密钥失败: %w", err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("编码 RSA 公钥失败: %w", err)
+	}
+	return &loginEncryption{key: key, publicKey: publicKey}, nil
+}
+
+// exchange 发送加密请求并读取加密响应，成功时为连接启用 AES/CFB8 加密
+// 返回密钥交换结果；只有在写入失败等无法继续的情况下返回 error
+func (e *loginEncryption) exchange(mcConn *net.Conn, sess *connSession, protocol int32) (string, error) {
+	verifyToken := make([]byte, verifyTokenLen)
+	if _, err := rand.Read(verifyToken); err != nil {
+		return "", fmt.Errorf("生成验证令牌失败: %w", err)
+	}
+
+	request := []pk.FieldEncoder{
+		pk.String(""), // 服务器 ID，1.7 之后始终为空
+		pk.ByteArray(e.publicKey),
+		pk.ByteArray(verifyToken),
+	}
+	if protocol >= 766 { // 1.20.5+ 增加“是否需要正版验证”字段
+		request = append(request, pk.Boolean(true))
+	}
+	if err := mcConn.WritePacket(pk.Marshal(loginEncryptionRequestID, request...)); err != nil {
+		return "", fmt.Errorf("发送加密请求失败: %w", err)
+	}
+
+	var p pk.Packet
+	if err := sess.readPacket(mcConn, &p); err != nil {
+		return KeyExchangeDisconnected, nil
+	}
+	if p.ID != loginEncryptionResponseID {
+		return KeyExchangeUnexpected, nil
+	}
+
+	encSecret, encToken, err := scanEncryptionResponse(&p, protocol)
+	if err != nil {
+		return KeyExchangeUnexpected, nil
+	}
+
+	secret, err := rsa.DecryptPKCS1v15(rand.Reader, e.key, encSecret)
+	if err != nil || len(secret) != sharedSecretLen {
+		return KeyExchangeBadSecret, nil
+	}
+
+	// 1.19 ~ 1.19.2 的客户端可以用消息签名代替验证令牌，无法校验时只要求共享密钥有效
+	if encToken != nil {
+		token, err := rsa.DecryptPKCS1v15(rand.Reader, e.key, encToken)
+		if err != nil || !bytes.Equal(token, verifyToken) {
+			return KeyExchangeBadToken, nil
+		}
+	}
+
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return KeyExchangeBadSecret, nil
+	}
+	mcConn.SetCipher(
+		CFB8.NewCFB8Encrypt(block, secret),
+		CFB8.NewCFB8Decrypt(block, secret),
+	)
+	return KeyExchangeCompleted, nil
+}
+
+// scanEncryptionResponse 解析加密响应包，返回加密的共享密钥和验证令牌
+// 对于使用签名代替验证令牌的 1.19 ~ 1.19.2 客户端，验证令牌返回 nil
+func scanEncryptionResponse(p *pk.Packet, protocol int32) ([]byte, []byte, error) {
+	var secret, token pk.ByteArray
+
+	if protocol != 759 && protocol != 760 {
+		if err := p.Scan(&secret, &token); err != nil {
+			return nil, nil, err
+		}
+		return secret, token, nil
+	}
+
+	r := bytes.NewReader(p.Data)
+	var hasToken pk.Boolean
+	if _, err := secret.ReadFrom(r); err != nil {
+		return nil, nil, err
+	}
+	if _, err := hasToken.ReadFrom(r); err != nil {
+		return nil, nil, err
+	}
+	if !hasToken {
+		var (
+			salt      pk.Long
+			signature pk.ByteArray
+		)
+		if _, err := salt.ReadFrom(r); err != nil {
+			return nil, nil, err
+		}
+		if _, err := signature.ReadFrom(r); err != nil {
+			return nil, nil, err
+		}
+		return secret, nil, nil
+	}
+	if _, err := token.ReadFrom(r); err != nil {
+		return nil, nil, err
+	}
+	return secret, token, nil
+}
@@ -0,0 +1,116 @@
+package protocol
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	stdnet "net"
+	"testing"
+	"time"
+
+	"github.com/Tnze/go-mc/net"
+	"github.com/Tnze/go-mc/net/CFB8"
+	pk "github.com/Tnze/go-mc/net/packet"
+
+	"fake-mc-server/internal/network"
+)
+
+// fakeOnlineClient 模拟正版客户端完成密钥交换，tamper 为 true 时发送错误的验证令牌
+func fakeOnlineClient(c stdnet.Conn, tamper bool) (string, error) {
+	mcConn := net.WrapConn(c)
+
+	var p pk.Packet
+	if err := mcConn.ReadPacket(&p); err != nil {
+		return "", err
+	}
+	var (
+		serverID    pk.String
+		publicKey   pk.ByteArray
+		verifyToken pk.ByteArray
+	)
+	if err := p.Scan(&serverID, &publicKey, &verifyToken); err != nil {
+		return "", err
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	secret := make([]byte, sharedSecretLen)
+	rand.Read(secret)
+	if tamper {
+		verifyToken = []byte{0, 0, 0, 0}
+	}
+	encSecret, _ := rsa.EncryptPKCS1v15(rand.Reader, pub.(*rsa.PublicKey), secret)
+	encToken, _ := rsa.EncryptPKCS1v15(rand.Reader, pub.(*rsa.PublicKey), verifyToken)
+	if err := mcConn.WritePacket(pk.Marshal(loginEncryptionResponseID, pk.ByteArray(encSecret), pk.ByteArray(encToken))); err != nil {
+		return "", err
+	}
+
+	block, _ := aes.NewCipher(secret)
+	mcConn.SetCipher(CFB8.NewCFB8Encrypt(block, secret), CFB8.NewCFB8Decrypt(block, secret))
+	if err := mcConn.ReadPacket(&p); err != nil {
+		return "", err
+	}
+	var msg pk.String
+	if err := p.Scan(&msg); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+func TestLoginEncryptionExchange(t *testing.T) {
+	enc, err := newLoginEncryption()
+	if err != nil {
+		t.Fatalf("newLoginEncryption() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper bool
+		want   string
+	}{
+		{"正确的验证令牌", false, KeyExchangeCompleted},
+		{"错误的验证令牌", true, KeyExchangeBadToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverSide, clientSide := stdnet.Pipe()
+			defer serverSide.Close()
+			defer clientSide.Close()
+
+			type clientResult struct {
+				msg string
+				err error
+			}
+			done := make(chan clientResult, 1)
+			go func() {
+				msg, err := fakeOnlineClient(clientSide, tt.tamper)
+				done <- clientResult{msg, err}
+			}()
+
+			sess := newConnSession(&network.Connection{StartTime: time.Now()}, nil, nil, nil)
+			mcConn := net.WrapConn(serverSide)
+			got, err := enc.exchange(mcConn, sess, 765)
+			if err != nil {
+				t.Fatalf("exchange() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("exchange() = %s, want %s", got, tt.want)
+			}
+			if got != KeyExchangeCompleted {
+				return
+			}
+
+			if err := mcConn.WritePacket(pk.Marshal(0x00, pk.String("bye"))); err != nil {
+				t.Fatalf("WritePacket() error = %v", err)
+			}
+			res := <-done
+			if res.err != nil || res.msg != "bye" {
+				t.Errorf("客户端收到 %q, err = %v, want \"bye\"", res.msg, res.err)
+			}
+		})
+	}
+}
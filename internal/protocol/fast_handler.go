@@ -3,6 +3,8 @@ package protocol
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -10,13 +12,20 @@ import (
 	"github.com/Tnze/go-mc/net/packet"
 	"github.com/rs/zerolog"
 
+	"fake-mc-server/internal/capture"
 	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/delay"
 	"fake-mc-server/internal/logger"
 	"fake-mc-server/internal/network"
 	"fake-mc-server/internal/pool"
+	"fake-mc-server/internal/signature"
 	"fake-mc-server/internal/sync"
+	"fake-mc-server/internal/tarpit"
 )
 
+// errHeld 连接已交给焦油坑或延迟调度器，结束处理但不关闭连接
+var errHeld = errors.New("连接已交给焦油坑或延迟调度器")
+
 // FastHandler 快速协议处理器
 type FastHandler struct {
 	config         *config.Config
@@ -25,10 +34,14 @@ type FastHandler struct {
 	limiter        RateLimiter
 	responsePool   *pool.ResponsePool
 	honeypotLogger *logger.HoneypotLogger
+	signatures     *signature.Database
+	capturer       *capture.Capturer
+	tarpit         *tarpit.Tarpit   // 为 nil 时只按延迟等待
+	delays         *delay.Scheduler // 延迟等待和延迟写出
 }
 
 // NewFastHandler 创建快速协议处理器
-func NewFastHandler(cfg *config.Config, logger zerolog.Logger, syncer *sync.UpstreamSyncer, limiter RateLimiter, honeypotLogger *logger.HoneypotLogger) *FastHandler {
+func NewFastHandler(cfg *config.Config, logger zerolog.Logger, syncer *sync.UpstreamSyncer, limiter RateLimiter, honeypotLogger *logger.HoneypotLogger, signatures *signature.Database, capturer *capture.Capturer, tarpit *tarpit.Tarpit, delays *delay.Scheduler) *FastHandler {
 	return &FastHandler{
 		config:         cfg,
 		logger:         logger.With().Str("component", "fast_protocol_handler").Logger(),
@@ -36,6 +49,10 @@ func NewFastHandler(cfg *config.Config, logger zerolog.Logger, syncer *sync.Upst
 		limiter:        limiter,
 		responsePool:   pool.NewResponsePool(),
 		honeypotLogger: honeypotLogger,
+		signatures:     signatures,
+		capturer:       capturer,
+		tarpit:         tarpit,
+		delays:         delays,
 	}
 }
 
@@ -49,18 +66,29 @@ func (h *FastHandler) HandleConnection(ctx context.Context, conn *network.Connec
 		return fmt.Errorf("限流")
 	}
 
-	// 计算并应用延迟
+	// 计算并应用延迟，延迟过高的连接改由焦油坑拖住，不在这里等待
 	delay := h.limiter.CalculateDelay(conn.RemoteIP)
-	if delay > 0 {
-		select {
-		case <-time.After(delay):
-		case <-ctx.Done():
-			return ctx.Err()
+	slot := h.tarpit.Acquire(conn.RemoteIP, delay)
+	defer slot.Release()
+	if slot == nil {
+		if err := h.delays.Wait(ctx, conn, delay); err != nil {
+			return err
 		}
 	}
 
+	sess := newConnSession(conn, h.honeypotLogger, h.signatures, h.capturer)
+	sess.tarpit = slot
+	sess.delays = h.delays
+	defer sess.finish()
+
+	// 仅凭来源地址即可命中的规则（如扫描器网段）在读取数据前就断开，指纹规则等到握手后再匹配
+	if err := sess.checkAddress(h.config.Security.DenyLabels); err != nil {
+		return err
+	}
+
 	// 处理多个数据包（类似 SimpleHandler）
-	buffer := make([]byte, MaxPacketSize)
+	// 缓冲区按握手包上限分配，以容纳 BungeeCord 转发的长地址
+	buffer := make([]byte, MaxHandshakeSize)
 
 	for {
 		// 设置读取超时
@@ -77,7 +105,12 @@ func (h *FastHandler) HandleConnection(ctx context.Context, conn *network.Connec
 
 		// 快速处理数据包
 		if n > 0 {
-			err := h.processPacketFast(conn, buffer[:n], delay)
+			sess.tracker.ObserveBytes(buffer[:n])
+			sess.capture.Record(capture.Inbound, buffer[:n])
+			err := h.processPacketFast(sess, buffer[:n], delay)
+			if errors.Is(err, errHeld) {
+				return nil
+			}
 			if err != nil {
 				// 处理失败，结束连接
 				return err
@@ -89,20 +122,30 @@ func (h *FastHandler) HandleConnection(ctx context.Context, conn *network.Connec
 }
 
 // processPacketFast 快速处理数据包（简化版，类似原始实现）
-func (h *FastHandler) processPacketFast(conn *network.Connection, data []byte, baseDelay time.Duration) error {
+func (h *FastHandler) processPacketFast(sess *connSession, data []byte, baseDelay time.Duration) error {
+	conn := sess.conn
+
 	// 1. 快速预检查
 	if err := h.quickPreCheck(data); err != nil {
-		return h.rejectSilently(conn, err.Error(), baseDelay)
+		return h.rejectSilently(sess, err.Error(), baseDelay)
 	}
+	sess.observeRawPacket(data)
 
 	// 2. 对于1字节的数据包，直接发送状态响应（兼容简单查询工具）
 	if len(data) == 1 {
 		conn.Logger.Debug().Msg("收到1字节数据包，发送状态响应")
-		return h.handleStatusRequestFast(conn)
+		return h.handleStatusRequestFast(sess)
+	}
+
+	// 3. 解析包长度和包ID（均为 VarInt，携带转发信息的握手包长度前缀占 2 字节）
+	packetID, body, err := splitPacketFast(data)
+	if err != nil {
+		conn.Logger.Debug().Err(err).Bytes("data", data).Msg("无法解析包头，尝试发送状态响应")
+		return h.handleStatusRequestFast(sess)
 	}
 
-	// 3. 检查是否是状态相关包（包ID 0x00）- 包括握手包和状态请求包
-	if data[1] == 0x00 {
+	// 4. 检查是否是状态相关包（包ID 0x00）- 包括握手包和状态请求包
+	if packetID == 0x00 {
 		// 尝试解析握手包（如果是长包）
 		if len(data) >= 7 {
 			handshake, err := h.parseHandshakeFast(data)
@@ -113,44 +156,56 @@ func (h *FastHandler) processPacketFast(conn *network.Connection, data []byte, b
 					Str("address", handshake.ServerAddress).
 					Int("port", int(handshake.ServerPort)).
 					Int("intention", handshake.NextState).
+					Str("forwarded_ip", handshake.Forwarding.ClientIP).
+					Str("mod_loader", handshake.Forwarding.ModLoader).
 					Msg("收到握手包")
 
 				// 记录蜜罐事件（优化版：不记录connID和dataHex）
-				if h.honeypotLogger.IsEnabled() {
-					h.honeypotLogger.LogHandshake(
-						conn.RemoteIP,
-						handshake.ProtocolVersion,
-						handshake.ServerAddress,
-						handshake.ServerPort,
-						handshake.NextState,
-					)
+				sess.setHandshake(handshake)
+				sess.logEvent(newHandshakeEvent(conn.RemoteIP, handshake))
+
+				if err := sess.checkAccess(h.config.Security.DenyLabels); err != nil {
+					return err
 				}
 
 				// 如果是登录意图，直接处理
 				if handshake.NextState == 2 {
-					return h.handleLoginFast(conn)
+					return h.handleLoginFast(sess)
 				}
 			}
 		}
 
 		// 对所有包ID为0x00的包（握手包或状态请求包）都发送状态响应
-		return h.handleStatusRequestFast(conn)
+		return h.handleStatusRequestFast(sess)
 	}
 
-	// 4. 检查是否是 Ping 包（包ID 0x01）
-	if data[1] == 0x01 {
-		return h.handlePingRequestFast(conn, data)
+	// 5. 检查是否是 Ping 包（包ID 0x01）
+	if packetID == 0x01 {
+		return h.handlePingRequestFast(sess, body)
 	}
 
-	// 5. 未知协议包，但不立即拒绝，先尝试发送状态响应（更宽松的处理）
+	// 6. 未知协议包，但不立即拒绝，先尝试发送状态响应（更宽松的处理）
 	conn.Logger.Debug().Bytes("data", data).Msg("收到未知协议包，尝试发送状态响应")
-	return h.handleStatusRequestFast(conn)
+	return h.handleStatusRequestFast(sess)
+}
+
+// splitPacketFast 解析包长度和包ID，返回包ID和包体
+func splitPacketFast(data []byte) (int32, []byte, error) {
+	r := bytes.NewReader(data)
+	var packetLen, packetID packet.VarInt
+	if _, err := packetLen.ReadFrom(r); err != nil {
+		return 0, nil, fmt.Errorf("invalid packet length")
+	}
+	if _, err := packetID.ReadFrom(r); err != nil {
+		return 0, nil, fmt.Errorf("invalid packet id")
+	}
+	return int32(packetID), data[len(data)-r.Len():], nil
 }
 
 // quickPreCheck 快速预检查
 func (h *FastHandler) quickPreCheck(data []byte) error {
 	// 大小检查
-	if len(data) > MaxPacketSize {
+	if len(data) > MaxHandshakeSize {
 		return fmt.Errorf("packet too large: %d", len(data))
 	}
 
@@ -194,11 +249,16 @@ func (h *FastHandler) parseHandshakeFast(data []byte) (*HandshakeInfo, error) {
 		return nil, fmt.Errorf("invalid address")
 	}
 
-	// 验证地址长度
-	if len(address) > MaxStringLen {
+	// 验证地址长度（原始地址可能携带 BungeeCord 转发信息）
+	if len(address) > MaxForwardedAddressLen {
 		return nil, fmt.Errorf("address too long: %d", len(address))
 	}
 
+	forwarding := ParseServerAddress(string(address))
+	if len(forwarding.Host) > MaxStringLen {
+		return nil, fmt.Errorf("host too long: %d", len(forwarding.Host))
+	}
+
 	// 解析端口
 	var port packet.UnsignedShort
 	if _, err := port.ReadFrom(r); err != nil {
@@ -218,19 +278,19 @@ func (h *FastHandler) parseHandshakeFast(data []byte) (*HandshakeInfo, error) {
 
 	return &HandshakeInfo{
 		ProtocolVersion: int(protocol),
-		ServerAddress:   string(address),
+		ServerAddress:   forwarding.Host,
 		ServerPort:      uint16(port),
 		NextState:       int(intention),
+		Forwarding:      forwarding,
 	}, nil
 }
 
 // handleLoginFast 快速处理登录请求
-func (h *FastHandler) handleLoginFast(conn *network.Connection) error {
-	// 应用额外的登录延迟
+func (h *FastHandler) handleLoginFast(sess *connSession) error {
+	conn := sess.conn
+
+	// 额外的登录延迟在写出断开包时交给延迟调度器
 	loginDelay := h.limiter.CalculateDelay(conn.RemoteIP)
-	if loginDelay > 0 {
-		time.Sleep(loginDelay)
-	}
 
 	// 构建断开连接包
 	kickJSON := fmt.Sprintf(`{"text":"%s"}`, h.config.Messages.KickMessage)
@@ -242,20 +302,27 @@ func (h *FastHandler) handleLoginFast(conn *network.Connection) error {
 	}
 
 	// 发送断开连接包
-	if _, err := conn.Write(buf.Bytes()); err != nil {
-		return fmt.Errorf("send login disconnect failed: %w", err)
+	held := sess.handOff(buf.Bytes(), loginDelay)
+	if !held {
+		if err := sess.write(buf.Bytes()); err != nil {
+			return fmt.Errorf("send login disconnect failed: %w", err)
+		}
 	}
 
-	// 记录蜜罐登录尝试事件（优化版：不记录connID和kickMsg）
-	if h.honeypotLogger.IsEnabled() {
-		delayMs := loginDelay.Milliseconds()
-		h.honeypotLogger.LogLoginAttempt(conn.RemoteIP, "", delayMs) // 没有用户名
-	}
+	// 记录蜜罐登录尝试事件（优化版：不记录connID和kickMsg，没有用户名）
+	sess.logEvent(&logger.HoneypotEvent{
+		EventType:    "login_attempt",
+		DelayApplied: loginDelay.Milliseconds(),
+	})
 
 	conn.Logger.Info().
 		Str("kick_message", h.config.Messages.KickMessage).
+		Bool("deferred", held).
 		Msg("发送登录断开连接包")
 
+	if held {
+		return errHeld
+	}
 	return nil
 }
 
@@ -279,24 +346,26 @@ func (h *FastHandler) buildServerStatus() string {
 }
 
 // rejectSilently 静默拒绝连接
-func (h *FastHandler) rejectSilently(conn *network.Connection, reason string, delay time.Duration) error {
-	conn.Logger.Warn().Str("reason", reason).Msg("静默拒绝连接")
+func (h *FastHandler) rejectSilently(sess *connSession, reason string, delay time.Duration) error {
+	sess.conn.Logger.Warn().Str("reason", reason).Msg("静默拒绝连接")
 
 	// 记录蜜罐协议违规事件（优化版：不记录connID和dataHex）
-	if h.honeypotLogger.IsEnabled() {
-		h.honeypotLogger.LogProtocolViolation(conn.RemoteIP, reason)
-	}
-
-	// 应用延迟让攻击者以为服务器在处理
-	if delay > 0 {
-		time.Sleep(delay)
+	sess.logEvent(&logger.HoneypotEvent{
+		EventType:    "protocol_violation",
+		ErrorMessage: reason,
+	})
+
+	// 延迟一段时间再断开，让攻击者以为服务器在处理
+	if sess.handOff(nil, delay) {
+		return errHeld
 	}
 
 	return fmt.Errorf("rejected: %s", reason)
 }
 
 // handleStatusRequestFast 快速处理状态请求
-func (h *FastHandler) handleStatusRequestFast(conn *network.Connection) error {
+func (h *FastHandler) handleStatusRequestFast(sess *connSession) error {
+	conn := sess.conn
 	conn.Logger.Debug().Msg("收到状态请求包")
 
 	// 构建并发送状态响应
@@ -308,7 +377,11 @@ func (h *FastHandler) handleStatusRequestFast(conn *network.Connection) error {
 		return fmt.Errorf("pack status response failed: %w", err)
 	}
 
-	if _, err := conn.Write(buf.Bytes()); err != nil {
+	if sess.handOff(buf.Bytes(), 0) {
+		conn.Logger.Debug().Msg("状态响应交给焦油坑")
+		return errHeld
+	}
+	if err := sess.write(buf.Bytes()); err != nil {
 		return fmt.Errorf("send status response failed: %w", err)
 	}
 
@@ -317,11 +390,14 @@ func (h *FastHandler) handleStatusRequestFast(conn *network.Connection) error {
 }
 
 // handlePingRequestFast 快速处理 ping 请求（采用原始实现的方式）
-func (h *FastHandler) handlePingRequestFast(conn *network.Connection, data []byte) error {
-	// 提取时间戳（跳过包长度和包ID）- 采用原始实现的逻辑
+func (h *FastHandler) handlePingRequestFast(sess *connSession, data []byte) error {
+	conn := sess.conn
+
+	// 提取时间戳（data 为去掉包长度和包ID后的包体）- 采用原始实现的逻辑
 	var timestamp []byte
-	if len(data) >= 10 { // 包长度(1) + 包ID(1) + 时间戳(8)
-		timestamp = data[2:10]
+	if len(data) >= 8 {
+		timestamp = data[:8]
+		sess.tracker.ObservePing(int64(binary.BigEndian.Uint64(timestamp)))
 	} else {
 		// 如果没有时间戳，使用简单填充（与原始实现一致）
 		timestamp = make([]byte, 8)
@@ -341,7 +417,7 @@ func (h *FastHandler) handlePingRequestFast(conn *network.Connection, data []byt
 	response = append(response, timestamp...)       // 时间戳
 
 	// 发送响应
-	if _, err := conn.Write(response); err != nil {
+	if err := sess.write(response); err != nil {
 		return fmt.Errorf("发送 Pong 响应失败: %w", err)
 	}
 
@@ -0,0 +1,85 @@
+package protocol
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pk "github.com/Tnze/go-mc/net/packet"
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/network"
+	"fake-mc-server/internal/signature"
+)
+
+// TestSplitPacketFastForwardedHandshake 携带 BungeeCord 转发信息的握手包超过 127 字节，包长度前缀占 2 字节
+func TestSplitPacketFastForwardedHandshake(t *testing.T) {
+	address := "mc.example.com\x00203.0.113.7\x00069a79f444e94726a5befca90e38aaf5\x00" +
+		`[{"name":"textures","value":"` + strings.Repeat("e30=", 20) + `"}]`
+	var buf bytes.Buffer
+	handshake := pk.Marshal(0x00, pk.VarInt(765), pk.String(address), pk.UnsignedShort(25565), pk.VarInt(2))
+	if err := handshake.Pack(&buf, -1); err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+	data := buf.Bytes()
+	if data[0]&0x80 == 0 {
+		t.Fatalf("握手包长度 %d，未达到 2 字节长度前缀", len(data))
+	}
+
+	packetID, body, err := splitPacketFast(data)
+	if err != nil || packetID != 0x00 || !bytes.Equal(body, handshake.Data) {
+		t.Fatalf("splitPacketFast() = %#02X, %d bytes, %v", packetID, len(body), err)
+	}
+
+	info, err := (&FastHandler{}).parseHandshakeFast(data)
+	if err != nil {
+		t.Fatalf("parseHandshakeFast() error = %v", err)
+	}
+	if got := info.Forwarding; got.Host != "mc.example.com" || got.ClientIP != "203.0.113.7" || !got.HasProperties || info.NextState != 2 {
+		t.Errorf("握手信息 = %+v", info)
+	}
+
+	// Ping 包体即 8 字节时间戳
+	var ping bytes.Buffer
+	pingPacket := pk.Marshal(0x01, pk.Long(42))
+	if err := pingPacket.Pack(&ping, -1); err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+	if packetID, body, err := splitPacketFast(ping.Bytes()); err != nil || packetID != 0x01 || len(body) != 8 {
+		t.Errorf("splitPacketFast(ping) = %#02X, %d bytes, %v", packetID, len(body), err)
+	}
+}
+
+// TestCheckAddressIgnoresFingerprint 读取数据前的检查只按网段匹配，指纹规则不会误伤还没发送数据的连接
+func TestCheckAddressIgnoresFingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.yml")
+	rules := "rules:\n  - label: mass-scanner\n    fingerprints: [banner_grab, nmap]\n  - label: bad-net\n    cidrs: [\"198.51.100.0/24\"]\n"
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatalf("写入规则文件失败: %v", err)
+	}
+	signatures, err := signature.NewDatabase(path, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	honeypotLogger, _ := logger.NewHoneypotLogger(&config.HoneypotLoggingConfig{})
+	denyLabels := []string{"mass-scanner", "bad-net"}
+
+	for _, tt := range []struct {
+		ip     string
+		denied bool
+	}{
+		{"192.0.2.1", false},
+		{"198.51.100.7", true},
+	} {
+		conn := &network.Connection{ID: tt.ip + "-1", RemoteIP: tt.ip, StartTime: time.Now(), Logger: zerolog.Nop()}
+		sess := newConnSession(conn, honeypotLogger, signatures, nil)
+		if err := sess.checkAddress(denyLabels); (err != nil) != tt.denied {
+			t.Errorf("%s checkAddress() error = %v, want denied %v", tt.ip, err, tt.denied)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package protocol
+
+import (
+	"net"
+	"strings"
+
+	"fake-mc-server/internal/logger"
+)
+
+// 握手地址中可能出现的模组加载器标记
+const (
+	ModLoaderFML   = "FML"   // Forge 1.7 - 1.12
+	ModLoaderFML2  = "FML2"  // Forge 1.13 - 1.17
+	ModLoaderFML3  = "FML3"  // Forge 1.18 - 1.20.1
+	ModLoaderForge = "FORGE" // Forge 1.20.2+
+)
+
+// ForwardingInfo 从握手地址中解析出的代理转发信息
+type ForwardingInfo struct {
+	Host          string // 去除附加信息后的真实主机名
+	ClientIP      string // BungeeCord 转发的真实客户端 IP
+	UUID          string // BungeeCord 转发的玩家 UUID
+	HasProperties bool   // 是否携带了 properties JSON
+	ModLoader     string // 模组加载器标记（FML/FML2/FML3/FORGE）
+}
+
+// IsForwarded 是否为 BungeeCord 传统转发
+func (f *ForwardingInfo) IsForwarded() bool {
+	return f.ClientIP != ""
+}
+
+// ParseServerAddress 解析握手包中的服务器地址
+// 支持 BungeeCord/Velocity 传统转发格式 host\0clientIP\0uuid[\0properties]
+// 以及 Forge 的 host\0FML\0 系列标记
+func ParseServerAddress(raw string) ForwardingInfo {
+	parts := strings.Split(raw, "\x00")
+	info := ForwardingInfo{Host: parts[0]}
+
+	rest := parts[1:]
+	for i := 0; i < len(rest); i++ {
+		part := rest[i]
+		switch {
+		case part == "":
+			continue
+		case isModLoaderMarker(part):
+			if info.ModLoader == "" {
+				info.ModLoader = part
+			}
+		case info.ClientIP == "" && net.ParseIP(part) != nil && i+1 < len(rest) && isUndashedUUID(rest[i+1]):
+			info.ClientIP = part
+			info.UUID = formatUUID(rest[i+1])
+			i++
+			// properties 紧跟在 UUID 之后，以 JSON 数组形式出现
+			if i+1 < len(rest) && strings.HasPrefix(rest[i+1], "[") {
+				info.HasProperties = true
+				i++
+			}
+		}
+	}
+
+	return info
+}
+
+// newHandshakeEvent 根据握手信息构建蜜罐事件，转发信息作为独立字段记录
+func newHandshakeEvent(clientIP string, info *HandshakeInfo) *logger.HoneypotEvent {
+	return &logger.HoneypotEvent{
+		ClientIP:        clientIP,
+		EventType:       "handshake",
+		ProtocolVersion: info.ProtocolVersion,
+		ServerAddress:   info.ServerAddress,
+		ServerPort:      info.ServerPort,
+		NextState:       info.NextState,
+		ForwardedIP:     info.Forwarding.ClientIP,
+		ForwardedUUID:   info.Forwarding.UUID,
+		ModLoader:       info.Forwarding.ModLoader,
+	}
+}
+
+// isModLoaderMarker 判断是否为模组加载器标记
+func isModLoaderMarker(s string) bool {
+	switch s {
+	case ModLoaderFML, ModLoaderFML2, ModLoaderFML3, ModLoaderForge:
+		return true
+	}
+	return false
+}
+
+// isUndashedUUID 判断是否为 32 位十六进制 UUID（BungeeCord 格式不带连字符）
+func isUndashedUUID(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// formatUUID 将 32 位十六进制 UUID 转换为标准带连字符格式
+func formatUUID(s string) string {
+	s = strings.ToLower(s)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
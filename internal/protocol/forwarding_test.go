@@ -0,0 +1,65 @@
+package protocol
+
+import "testing"
+
+func TestParseServerAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want ForwardingInfo
+	}{
+		{
+			name: "普通地址",
+			raw:  "mc.example.com",
+			want: ForwardingInfo{Host: "mc.example.com"},
+		},
+		{
+			name: "Forge FML2 标记",
+			raw:  "mc.example.com\x00FML2\x00",
+			want: ForwardingInfo{Host: "mc.example.com", ModLoader: ModLoaderFML2},
+		},
+		{
+			name: "BungeeCord 传统转发",
+			raw:  "mc.example.com\x00203.0.113.7\x00069a79f444e94726a5befca90e38aaf5",
+			want: ForwardingInfo{
+				Host:     "mc.example.com",
+				ClientIP: "203.0.113.7",
+				UUID:     "069a79f4-44e9-4726-a5be-fca90e38aaf5",
+			},
+		},
+		{
+			name: "BungeeCord 转发携带 properties",
+			raw:  "mc.example.com\x002001:db8::1\x00069A79F444E94726A5BEFCA90E38AAF5\x00[{\"name\":\"textures\",\"value\":\"e30=\"}]",
+			want: ForwardingInfo{
+				Host:          "mc.example.com",
+				ClientIP:      "2001:db8::1",
+				UUID:          "069a79f4-44e9-4726-a5be-fca90e38aaf5",
+				HasProperties: true,
+			},
+		},
+		{
+			name: "BungeeCord 转发和 FML3 标记",
+			raw:  "mc.example.com\x00FML3\x00\x00198.51.100.2\x00069a79f444e94726a5befca90e38aaf5",
+			want: ForwardingInfo{
+				Host:      "mc.example.com",
+				ClientIP:  "198.51.100.2",
+				UUID:      "069a79f4-44e9-4726-a5be-fca90e38aaf5",
+				ModLoader: ModLoaderFML3,
+			},
+		},
+		{
+			name: "IP 后缺少 UUID 不视为转发",
+			raw:  "mc.example.com\x00203.0.113.7",
+			want: ForwardingInfo{Host: "mc.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseServerAddress(tt.raw)
+			if got != tt.want {
+				t.Errorf("ParseServerAddress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
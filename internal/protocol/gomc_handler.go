@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,12 +12,19 @@ import (
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
+	"fake-mc-server/internal/capture"
 	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/delay"
 	"fake-mc-server/internal/logger"
 	"fake-mc-server/internal/network"
+	"fake-mc-server/internal/signature"
 	"fake-mc-server/internal/sync"
+	"fake-mc-server/internal/tarpit"
 )
 
+// errHandshakeRead 读取握手包失败（连接断开或超时）
+var errHandshakeRead = errors.New("读取握手包失败")
+
 // GoMCHandler 基于go-mc库的处理器
 // 使用go-mc的标准服务器框架，提供更好的兼容性
 type GoMCHandler struct {
@@ -25,6 +33,11 @@ type GoMCHandler struct {
 	upstreamSyncer *sync.UpstreamSyncer
 	honeypotLogger *logger.HoneypotLogger
 	limiter        RateLimiter
+	signatures     *signature.Database
+	capturer       *capture.Capturer
+	tarpit         *tarpit.Tarpit   // 为 nil 时只按延迟等待
+	delays         *delay.Scheduler // 延迟等待和延迟写出
+	encryption     *loginEncryption // 为 nil 时登录后直接踢出
 }
 
 // NewGoMCHandler 创建新的GoMC处理器
@@ -34,14 +47,33 @@ func NewGoMCHandler(
 	upstreamSyncer *sync.UpstreamSyncer,
 	honeypotLogger *logger.HoneypotLogger,
 	limiter RateLimiter,
+	signatures *signature.Database,
+	capturer *capture.Capturer,
+	tarpit *tarpit.Tarpit,
+	delays *delay.Scheduler,
 ) *GoMCHandler {
-	return &GoMCHandler{
+	h := &GoMCHandler{
 		config:         cfg,
 		logger:         logger.With().Str("handler", "gomc").Logger(),
 		upstreamSyncer: upstreamSyncer,
 		honeypotLogger: honeypotLogger,
 		limiter:        limiter,
+		signatures:     signatures,
+		capturer:       capturer,
+		tarpit:         tarpit,
+		delays:         delays,
+	}
+
+	if cfg.Login.Encryption {
+		encryption, err := newLoginEncryption()
+		if err != nil {
+			h.logger.Error().Err(err).Msg("初始化登录加密失败，登录后将直接踢出")
+		} else {
+			h.encryption = encryption
+		}
 	}
+
+	return h
 }
 
 // HandleConnection 处理连接（实现network.ConnectionHandler接口）
@@ -52,36 +84,67 @@ func (h *GoMCHandler) HandleConnection(ctx context.Context, conn *network.Connec
 		return fmt.Errorf("限流")
 	}
 
-	// 计算并应用延迟
+	// 计算并应用延迟，延迟过高的连接改由焦油坑拖住，不在这里等待
 	delay := h.limiter.CalculateDelay(conn.RemoteIP)
-	if delay > 0 {
-		select {
-		case <-time.After(delay):
-		case <-ctx.Done():
-			return ctx.Err()
+	slot := h.tarpit.Acquire(conn.RemoteIP, delay)
+	defer slot.Release()
+	if slot == nil {
+		if err := h.delays.Wait(ctx, conn, delay); err != nil {
+			return err
 		}
 	}
 
-	// 将network.Connection转换为go-mc的net.Conn
+	sess := newConnSession(conn, h.honeypotLogger, h.signatures, h.capturer)
+	sess.tarpit = slot
+	sess.delays = h.delays
+	defer sess.finish()
+
+	// 将network.Connection转换为go-mc的net.Conn，读写的字节同时交给指纹采集器和抓包记录器
+	// 启用加密后 go-mc 会直接读写底层连接，之后的密文不再记录
 	mcConn := h.wrapConnection(conn)
-	defer mcConn.Close()
+	mcConn.Reader = sess.capture.Reader(sess.tracker.Reader(mcConn.Reader))
+	h.limitPackets(mcConn)
+	mcConn.Writer = sess.capture.Writer(mcConn.Writer)
+	defer func() {
+		// 交给焦油坑的连接由焦油坑关闭
+		if !sess.held {
+			mcConn.Close()
+		}
+	}()
 
 	// 处理握手
-	protocol, intention, err := h.handleHandshake(mcConn)
+	handshake, err := h.handleHandshake(mcConn, sess)
 	if err != nil {
 		conn.Logger.Debug().Err(err).Msg("握手失败")
+		// 读取失败（断开、超时）不算协议违规，只有收到了无效的握手包才记录
+		if !errors.Is(err, errHandshakeRead) {
+			sess.logEvent(&logger.HoneypotEvent{
+				EventType:    "protocol_violation",
+				ErrorMessage: err.Error(),
+			})
+		}
 		return err
 	}
 
+	// 记录蜜罐握手事件
+	sess.setHandshake(handshake)
+	sess.logEvent(newHandshakeEvent(conn.RemoteIP, handshake))
+
+	if err := sess.checkAccess(h.config.Security.DenyLabels); err != nil {
+		return err
+	}
+
+	protocol := int32(handshake.ProtocolVersion)
+
 	// 根据意图处理
-	switch intention {
+	switch handshake.NextState {
 	case 1: // 状态查询
-		return h.handleStatusQuery(mcConn, conn, protocol)
+		return h.handleStatusQuery(mcConn, sess, protocol)
 	case 2: // 登录
-		return h.handleLogin(mcConn, conn, protocol, delay)
+		return h.handleLogin(ctx, mcConn, sess, protocol, delay)
 	default:
-		conn.Logger.Warn().Int("intention", int(intention)).Msg("未知意图")
-		return fmt.Errorf("unknown intention: %d", intention)
+		conn.Logger.Warn().Int("intention", handshake.NextState).Msg("未知意图")
+		return fmt.Errorf("unknown intention: %d", handshake.NextState)
 	}
 }
 
@@ -100,17 +163,15 @@ func (h *GoMCHandler) wrapConnection(conn *network.Connection) *net.Conn {
 }
 
 // handleHandshake 处理握手包
-func (h *GoMCHandler) handleHandshake(conn *net.Conn) (protocol int32, intention int32, err error) {
+func (h *GoMCHandler) handleHandshake(conn *net.Conn, sess *connSession) (*HandshakeInfo, error) {
 	var p pk.Packet
-	err = conn.ReadPacket(&p)
-	if err != nil {
-		return
+	if err := sess.readPacket(conn, &p); err != nil {
+		return nil, fmt.Errorf("%w: %w", errHandshakeRead, err)
 	}
 
 	// 握手包ID是0x00，不需要检查
 	if p.ID != 0x00 {
-		err = fmt.Errorf("expected handshake packet, got %#02X", p.ID)
-		return
+		return nil, fmt.Errorf("expected handshake packet, got %#02X", p.ID)
 	}
 
 	var (
@@ -120,31 +181,44 @@ func (h *GoMCHandler) handleHandshake(conn *net.Conn) (protocol int32, intention
 		nextState       pk.VarInt
 	)
 
-	err = p.Scan(&protocolVersion, &serverAddress, &serverPort, &nextState)
-	if err != nil {
-		return
+	if err := p.Scan(&protocolVersion, &serverAddress, &serverPort, &nextState); err != nil {
+		return nil, err
 	}
 
-	protocol = int32(protocolVersion)
-	intention = int32(nextState)
+	if len(serverAddress) > MaxForwardedAddressLen {
+		return nil, fmt.Errorf("address too long: %d", len(serverAddress))
+	}
+
+	// 拆分 BungeeCord 转发信息和 Forge 标记，避免原样记录
+	forwarding := ParseServerAddress(string(serverAddress))
 
 	h.logger.Debug().
-		Int32("protocol", protocol).
-		Str("address", string(serverAddress)).
+		Int32("protocol", int32(protocolVersion)).
+		Str("address", forwarding.Host).
 		Int("port", int(serverPort)).
-		Int32("intention", intention).
+		Int32("intention", int32(nextState)).
+		Str("forwarded_ip", forwarding.ClientIP).
+		Str("forwarded_uuid", forwarding.UUID).
+		Str("mod_loader", forwarding.ModLoader).
 		Msg("收到握手包")
 
-	return
+	return &HandshakeInfo{
+		ProtocolVersion: int(protocolVersion),
+		ServerAddress:   forwarding.Host,
+		ServerPort:      uint16(serverPort),
+		NextState:       int(nextState),
+		Forwarding:      forwarding,
+	}, nil
 }
 
 // handleStatusQuery 处理状态查询
-func (h *GoMCHandler) handleStatusQuery(mcConn *net.Conn, conn *network.Connection, protocol int32) error {
+func (h *GoMCHandler) handleStatusQuery(mcConn *net.Conn, sess *connSession, protocol int32) error {
+	conn := sess.conn
 	var p pk.Packet
 
 	// 最多处理2个包（状态请求和Ping）
 	for range 2 {
-		err := mcConn.ReadPacket(&p)
+		err := sess.readPacket(mcConn, &p)
 		if err != nil {
 			return err
 		}
@@ -155,12 +229,17 @@ func (h *GoMCHandler) handleStatusQuery(mcConn *net.Conn, conn *network.Connecti
 
 			// 构建状态响应
 			statusJSON := h.buildStatusResponse(protocol)
-
-			// 发送响应
-			err = mcConn.WritePacket(pk.Marshal(
+			response := pk.Marshal(
 				0x00, // ClientboundStatusStatusResponse
 				pk.String(statusJSON),
-			))
+			)
+			if sess.handOffPacket(response, 0) {
+				conn.Logger.Debug().Msg("状态响应交给焦油坑")
+				return nil
+			}
+
+			// 发送响应
+			err = mcConn.WritePacket(response)
 			if err != nil {
 				return fmt.Errorf("发送状态响应失败: %w", err)
 			}
@@ -170,6 +249,11 @@ func (h *GoMCHandler) handleStatusQuery(mcConn *net.Conn, conn *network.Connecti
 		case 0x01: // Ping请求
 			conn.Logger.Debug().Msg("收到Ping请求")
 
+			var payload pk.Long
+			if err := p.Scan(&payload); err == nil {
+				sess.tracker.ObservePing(int64(payload))
+			}
+
 			// 直接回显Ping包
 			err = mcConn.WritePacket(p)
 			if err != nil {
@@ -216,16 +300,25 @@ func (h *GoMCHandler) buildStatusResponse(protocol int32) string {
 }
 
 // handleLogin 处理登录请求
-func (h *GoMCHandler) handleLogin(mcConn *net.Conn, conn *network.Connection, protocol int32, baseDelay time.Duration) error {
-	// 应用额外的登录延迟
+func (h *GoMCHandler) handleLogin(ctx context.Context, mcConn *net.Conn, sess *connSession, protocol int32, baseDelay time.Duration) error {
+	conn := sess.conn
+
+	// 额外的登录延迟：直接踢出时在写出断开包时交给延迟调度器；
+	// 还需继续交互（密钥交换、陷阱世界）时只能在这里等待
 	loginDelay := h.limiter.CalculateDelay(conn.RemoteIP)
-	if loginDelay > 0 {
-		time.Sleep(loginDelay)
+	kickDelay := loginDelay
+	if h.encryption != nil || h.config.Login.TrapWorld.Enabled {
+		kickDelay = 0
+		if sess.tarpit == nil {
+			if err := h.delays.Wait(ctx, conn, loginDelay); err != nil {
+				return err
+			}
+		}
 	}
 
 	// 读取登录开始包
 	var p pk.Packet
-	err := mcConn.ReadPacket(&p)
+	err := sess.readPacket(mcConn, &p)
 	if err != nil {
 		return err
 	}
@@ -243,6 +336,8 @@ func (h *GoMCHandler) handleLogin(mcConn *net.Conn, conn *network.Connection, pr
 	if err != nil {
 		return err
 	}
+	sess.tracker.ObserveLogin(string(username), playerID)
+	sess.setUsername(string(username))
 
 	conn.Logger.Info().
 		Str("username", string(username)).
@@ -250,17 +345,67 @@ func (h *GoMCHandler) handleLogin(mcConn *net.Conn, conn *network.Connection, pr
 		Msg("收到登录请求")
 
 	// 记录蜜罐登录尝试事件
-	if h.honeypotLogger.IsEnabled() {
-		delayMs := loginDelay.Milliseconds()
-		h.honeypotLogger.LogLoginAttempt(conn.RemoteIP, string(username), delayMs)
+	sess.logEvent(&logger.HoneypotEvent{
+		EventType:    "login_attempt",
+		Username:     string(username),
+		DelayApplied: loginDelay.Milliseconds(),
+	})
+
+	if err := sess.checkAccess(h.config.Security.DenyLabels); err != nil {
+		return err
 	}
 
-	// 构建并发送断开连接包
-	kickMessage := chat.Message{Text: h.config.Messages.KickMessage}
-	err = mcConn.WritePacket(pk.Marshal(
+	// 焦油坑中的连接跳过密钥交换和陷阱世界，直接慢慢写出断开包
+	kickPacket := pk.Marshal(
 		0x00, // ClientboundLoginLoginDisconnect
-		kickMessage,
-	))
+		chat.Message{Text: h.config.Messages.KickMessage},
+	)
+	if sess.tarpit != nil && sess.handOffPacket(kickPacket, 0) {
+		conn.Logger.Debug().Msg("登录断开包交给焦油坑")
+		return nil
+	}
+
+	// 伪造正版验证：完成密钥交换后再踢出
+	if h.encryption != nil {
+		result, err := h.encryption.exchange(mcConn, sess, protocol)
+		if err != nil {
+			return err
+		}
+
+		conn.Logger.Info().Str("result", result).Msg("密钥交换结束")
+		sess.logEvent(&logger.HoneypotEvent{
+			EventType:   "key_exchange",
+			Username:    string(username),
+			KeyExchange: result,
+		})
+
+		// 客户端发送加密响应后即切换到加密模式，密钥无效时无法再发送可读的断开包
+		switch result {
+		case KeyExchangeCompleted:
+			h.limitPackets(mcConn)
+		case KeyExchangeUnexpected:
+		default:
+			return nil
+		}
+	}
+
+	// 陷阱世界：让离线模式客户端进入虚空世界并记录其行为
+	if h.config.Login.TrapWorld.Enabled {
+		if tp, ok := trapProtocols[protocol]; ok {
+			return h.runTrapWorld(ctx, mcConn, sess, tp, string(username))
+		}
+		conn.Logger.Info().Int32("protocol", protocol).Msg("陷阱世界不支持该协议版本，直接踢出")
+	}
+
+	// 发送断开连接包，需要延迟时交给延迟调度器
+	if sess.handOffPacket(kickPacket, kickDelay) {
+		conn.Logger.Info().
+			Str("kick_message", h.config.Messages.KickMessage).
+			Dur("delay", kickDelay).
+			Msg("登录断开连接包已排入延迟发送")
+		return nil
+	}
+	err = mcConn.WritePacket(kickPacket)
 	if err != nil {
 		return fmt.Errorf("发送登录断开连接包失败: %w", err)
 	}
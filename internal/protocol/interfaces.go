@@ -4,11 +4,12 @@ import "time"
 
 // 共享常量
 const (
-	MaxPacketSize    = 512    // 最大数据包大小
-	MaxHandshakeSize = 512    // 握手包最大大小
-	MaxStringLen     = 128    // 最大字符串长度
-	MaxVarIntValue   = 100000 // 最大 VarInt 值
-	ReadTimeout      = 5      // 读取超时秒数
+	MaxPacketSize          = 512    // 最大数据包大小
+	MaxHandshakeSize       = 4608   // 握手包最大大小（兼容 BungeeCord 转发地址）
+	MaxStringLen           = 128    // 最大字符串长度（去除转发信息后的主机名）
+	MaxForwardedAddressLen = 4096   // 携带转发信息的原始地址最大长度
+	MaxVarIntValue         = 100000 // 最大 VarInt 值
+	ReadTimeout            = 5      // 读取超时秒数
 )
 
 // RateLimiter 限流器接口
@@ -21,7 +22,8 @@ type RateLimiter interface {
 // HandshakeInfo 握手包信息
 type HandshakeInfo struct {
 	ProtocolVersion int
-	ServerAddress   string
+	ServerAddress   string // 去除转发信息后的主机名
 	ServerPort      uint16
 	NextState       int
+	Forwarding      ForwardingInfo
 }
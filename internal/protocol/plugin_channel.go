@@ -0,0 +1,154 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+
+	"fake-mc-server/internal/logger"
+)
+
+// 常用插件频道
+const (
+	channelBrand      = "minecraft:brand"
+	channelRegister   = "minecraft:register"
+	channelUnregister = "minecraft:unregister"
+)
+
+const (
+	// maxBrandLen 记录的客户端品牌最大长度
+	maxBrandLen = 64
+	// maxRegisteredChannels 记录的注册频道最大数量
+	maxRegisteredChannels = 32
+)
+
+// errPacketTooLarge 数据包超过 Security.MaxPacketSize
+var errPacketTooLarge = errors.New("packet exceeds max_packet_size")
+
+// packetSizeError 数据包长度前缀超过限制，在读取包体之前返回
+type packetSizeError struct {
+	size int
+}
+
+func (e *packetSizeError) Error() string {
+	return fmt.Sprintf("%v: %d", errPacketTooLarge, e.size)
+}
+
+func (e *packetSizeError) Unwrap() error {
+	return errPacketTooLarge
+}
+
+// packetLimitReader 跟踪数据包边界，长度前缀超过上限时直接返回错误
+// go-mc 按长度前缀一次性分配包体，必须在它读到长度之前拦截
+type packetLimitReader struct {
+	r         io.Reader
+	max       int
+	remaining int // 当前包体剩余的字节数
+	length    int // 正在解析的长度前缀
+	shift     uint
+}
+
+// Read 实现 io.Reader
+func (r *packetLimitReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := 0; i < n; {
+		if r.remaining > 0 {
+			step := min(r.remaining, n-i)
+			r.remaining -= step
+			i += step
+			continue
+		}
+
+		b := p[i]
+		i++
+		r.length |= int(b&0x7F) << r.shift
+		r.shift += 7
+		if b&0x80 != 0 {
+			if r.shift >= 35 { // VarInt 最多 5 字节
+				return 0, &packetSizeError{size: r.length}
+			}
+			continue
+		}
+		if r.length > r.max {
+			return 0, &packetSizeError{size: r.length}
+		}
+		r.remaining, r.length, r.shift = r.length, 0, 0
+	}
+	return n, err
+}
+
+// limitPackets 为 go-mc 连接的读取端加上数据包大小限制；启用加密会替换读取端，之后需要重新调用
+func (h *GoMCHandler) limitPackets(mcConn *net.Conn) {
+	mcConn.Reader = &packetLimitReader{r: mcConn.Reader, max: h.config.Security.MaxPacketSize}
+}
+
+// recordPluginMessage 记录插件消息的频道、载荷大小和哈希
+// minecraft:brand 声明的品牌会作为后续事件的 UserAgent
+func (h *GoMCHandler) recordPluginMessage(sess *connSession, p *pk.Packet) {
+	r := bytes.NewReader(p.Data)
+	var channel pk.Identifier
+	if _, err := channel.ReadFrom(r); err != nil {
+		sess.logEvent(&logger.HoneypotEvent{
+			EventType:    "protocol_violation",
+			Username:     sess.username,
+			ErrorMessage: "插件消息频道无效",
+			PayloadSize:  len(p.Data),
+		})
+		return
+	}
+	payload := p.Data[len(p.Data)-r.Len():]
+
+	event := &logger.HoneypotEvent{
+		EventType:   "plugin_message",
+		Username:    sess.username,
+		Channel:     truncate(string(channel), trapMaxMessageLen),
+		PayloadSize: len(payload),
+		PayloadHash: hashPayload(payload),
+	}
+
+	switch string(channel) {
+	case channelBrand:
+		var brand pk.String
+		if _, err := brand.ReadFrom(bytes.NewReader(payload)); err != nil {
+			event.ErrorMessage = "brand 载荷格式错误"
+			break
+		}
+		sess.setBrand(truncate(string(brand), maxBrandLen))
+	case channelRegister, channelUnregister:
+		event.Message = truncate(strings.Join(splitChannels(payload), ","), trapMaxMessageLen)
+	}
+
+	sess.logEvent(event)
+}
+
+// hashPayload 计算载荷的 SHA-256，便于聚合相同的利用载荷
+func hashPayload(payload []byte) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// splitChannels 解析 minecraft:register 载荷中以 \0 分隔的频道名
+func splitChannels(payload []byte) []string {
+	var channels []string
+	for _, name := range bytes.Split(payload, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		if len(channels) == maxRegisteredChannels {
+			channels = append(channels, "...")
+			break
+		}
+		channels = append(channels, string(name))
+	}
+	return channels
+}
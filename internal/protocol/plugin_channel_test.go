@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+
+	"fake-mc-server/internal/config"
+)
+
+// TestPacketLimitReader 超过上限的长度前缀在 go-mc 分配包体之前被拒绝
+func TestPacketLimitReader(t *testing.T) {
+	var stream bytes.Buffer
+	for _, p := range []pk.Packet{
+		pk.Marshal(0x00, pk.String("ok")),
+		pk.Marshal(0x01),
+		pk.Marshal(0x02, pk.ByteArray(make([]byte, 200))),
+	} {
+		if err := p.Pack(&stream, -1); err != nil {
+			t.Fatalf("Pack() error = %v", err)
+		}
+	}
+	// 声明 1GB 包体的长度前缀，实际没有数据
+	stream.Write([]byte{0x80, 0x80, 0x80, 0x80, 0x04})
+
+	cfg := &config.Config{}
+	cfg.Security.MaxPacketSize = 256
+	h := &GoMCHandler{config: cfg}
+	// 逐字节读取，覆盖长度前缀跨越多次读取的情况
+	mcConn := &net.Conn{Reader: iotest.OneByteReader(&stream)}
+	mcConn.SetThreshold(-1)
+	h.limitPackets(mcConn)
+
+	var p pk.Packet
+	for _, id := range []int32{0x00, 0x01, 0x02} {
+		if err := mcConn.ReadPacket(&p); err != nil || p.ID != id {
+			t.Fatalf("ReadPacket() = %#02X, %v, want %#02X", p.ID, err, id)
+		}
+	}
+	err := mcConn.ReadPacket(&p)
+	var sizeErr *packetSizeError
+	if !errors.As(err, &sizeErr) || !errors.Is(err, errPacketTooLarge) || sizeErr.size != 1<<30 {
+		t.Errorf("ReadPacket() error = %v, want packet too large (1<<30)", err)
+	}
+
+	// 不完整的长度前缀不会被误判
+	r := &packetLimitReader{r: bytes.NewReader([]byte{0x80}), max: 256}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Errorf("ReadAll() error = %v", err)
+	}
+}
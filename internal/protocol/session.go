@@ -0,0 +1,283 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+
+	"fake-mc-server/internal/capture"
+	"fake-mc-server/internal/delay"
+	"fake-mc-server/internal/fingerprint"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/network"
+	"fake-mc-server/internal/signature"
+	"fake-mc-server/internal/tarpit"
+)
+
+// connSession 单个连接的会话上下文
+// 在握手、登录等阶段累积连接级信息，并在记录蜜罐事件时统一补充到事件中
+type connSession struct {
+	conn           *network.Connection
+	honeypotLogger *logger.HoneypotLogger
+	signatures     *signature.Database
+	tracker        *fingerprint.Tracker
+	capture        *capture.Recorder // 未被采样时为 nil
+	violated       atomic.Bool       // 是否记录过协议违规
+	handshake      *HandshakeInfo
+	username       string
+	brand          string           // minecraft:brand 声明的客户端品牌
+	joinedAt       time.Time        // 进入陷阱世界的时间
+	tarpit         *tarpit.Slot     // 延迟达到焦油坑阈值时非 nil
+	delays         *delay.Scheduler // 为 nil 时延迟响应直接写出
+	held           bool             // 连接是否已交给焦油坑或延迟调度器
+
+	// 缓存的客户端分类和特征标签，握手、用户名或品牌变化时重新计算
+	classMu    sync.Mutex
+	classified bool
+	clientType string
+	labelCache []string
+}
+
+// newConnSession 创建连接会话
+func newConnSession(conn *network.Connection, honeypotLogger *logger.HoneypotLogger, signatures *signature.Database, capturer *capture.Capturer) *connSession {
+	return &connSession{
+		conn:           conn,
+		honeypotLogger: honeypotLogger,
+		signatures:     signatures,
+		tracker:        fingerprint.NewTracker(conn.StartTime),
+		capture:        capturer.Start(conn.ID, conn.RemoteIP, conn.StartTime),
+	}
+}
+
+// setHandshake 记录握手信息
+func (s *connSession) setHandshake(info *HandshakeInfo) {
+	s.invalidateClass()
+	s.handshake = info
+	s.tracker.ObserveHandshake(
+		info.ProtocolVersion,
+		info.ServerAddress,
+		info.ServerPort,
+		info.NextState,
+		info.Forwarding.ModLoader,
+	)
+}
+
+// setUsername 记录登录用户名
+func (s *connSession) setUsername(username string) {
+	s.invalidateClass()
+	s.username = username
+}
+
+// setBrand 记录客户端品牌
+func (s *connSession) setBrand(brand string) {
+	s.invalidateClass()
+	s.brand = brand
+	s.tracker.ObserveBrand(brand)
+}
+
+// classify 返回缓存的客户端分类和特征标签，缓存失效时重新计算
+func (s *connSession) classify() (string, []string) {
+	s.classMu.Lock()
+	defer s.classMu.Unlock()
+	if !s.classified {
+		s.clientType = s.tracker.Classify().Client
+		s.labelCache = s.labels(s.clientType)
+		s.classified = true
+	}
+	return s.clientType, s.labelCache
+}
+
+// invalidateClass 参与分类和特征匹配的连接信息变化时丢弃缓存
+func (s *connSession) invalidateClass() {
+	s.classMu.Lock()
+	s.classified = false
+	s.classMu.Unlock()
+}
+
+// labels 使用当前已知的连接信息匹配扫描器特征库
+func (s *connSession) labels(clientType string) []string {
+	in := signature.Input{
+		IP:          s.conn.RemoteIP,
+		Username:    s.username,
+		Fingerprint: clientType,
+	}
+	if s.handshake != nil {
+		in.Hostname = s.handshake.ServerAddress
+		in.Protocol = s.handshake.ProtocolVersion
+	}
+	return s.signatures.Match(in)
+}
+
+// checkAccess 检查连接是否命中禁止的特征标签
+func (s *connSession) checkAccess(denyLabels []string) error {
+	if len(denyLabels) == 0 {
+		return nil
+	}
+	_, labels := s.classify()
+	return s.deny(denyLabels, labels)
+}
+
+// checkAddress 仅按来源地址检查禁止的特征标签，用于读取数据之前
+// 此时还没有收到任何数据，指纹分类没有意义，不参与匹配
+func (s *connSession) checkAddress(denyLabels []string) error {
+	if len(denyLabels) == 0 {
+		return nil
+	}
+	return s.deny(denyLabels, s.signatures.Match(signature.Input{IP: s.conn.RemoteIP}))
+}
+
+// deny 命中禁止的标签时记录事件并返回错误
+func (s *connSession) deny(denyLabels, labels []string) error {
+	for _, label := range labels {
+		if !slices.Contains(denyLabels, label) {
+			continue
+		}
+		s.conn.Logger.Warn().Str("label", label).Msg("命中禁止的特征标签，断开连接")
+		s.logEvent(&logger.HoneypotEvent{
+			EventType:    "access_denied",
+			ErrorMessage: "label: " + label,
+		})
+		return fmt.Errorf("access denied: %s", label)
+	}
+	return nil
+}
+
+// readPacket 通过 go-mc 连接读取数据包并交给指纹采集器，数据包超过大小限制时记录协议违规
+func (s *connSession) readPacket(mcConn *net.Conn, p *pk.Packet) error {
+	if err := mcConn.ReadPacket(p); err != nil {
+		var sizeErr *packetSizeError
+		if errors.As(err, &sizeErr) {
+			s.conn.Logger.Warn().Int("size", sizeErr.size).Msg("数据包超过大小限制，断开连接")
+			s.logEvent(&logger.HoneypotEvent{
+				EventType:    "protocol_violation",
+				Username:     s.username,
+				ErrorMessage: errPacketTooLarge.Error(),
+				PayloadSize:  sizeErr.size,
+			})
+		}
+		return err
+	}
+	s.tracker.ObservePacket(p.ID, len(p.Data))
+	return nil
+}
+
+// write 向客户端写入数据并交给抓包记录器（FastHandler 使用）
+func (s *connSession) write(data []byte) error {
+	s.capture.Record(capture.Outbound, data)
+	_, err := s.conn.Write(data)
+	return err
+}
+
+// handOff 把最后的响应交给焦油坑或延迟调度器写出，并由其关闭连接，返回是否已交出
+// 未进入焦油坑且 d 为 0 时返回 false，由调用方直接写出
+func (s *connSession) handOff(data []byte, d time.Duration) bool {
+	switch {
+	case s.tarpit != nil:
+		s.tarpit.Hold(s.conn, data)
+	case d > 0 && s.delays != nil:
+		s.delays.WriteAndClose(s.conn, d, data)
+	default:
+		return false
+	}
+	s.capture.Record(capture.Outbound, data)
+	s.held = true
+	s.conn.Hold()
+	return true
+}
+
+// handOffPacket 与 handOff 相同，数据包按未压缩格式打包（GoMCHandler 使用）
+func (s *connSession) handOffPacket(p pk.Packet, d time.Duration) bool {
+	if s.tarpit == nil && (d <= 0 || s.delays == nil) {
+		return false
+	}
+	var buf bytes.Buffer
+	if err := p.Pack(&buf, -1); err != nil {
+		return false
+	}
+	return s.handOff(buf.Bytes(), d)
+}
+
+// observeRawPacket 记录原始数据包（FastHandler 使用）
+func (s *connSession) observeRawPacket(data []byte) {
+	r := bytes.NewReader(data)
+	var length, id pk.VarInt
+	if _, err := length.ReadFrom(r); err != nil {
+		return
+	}
+	if _, err := id.ReadFrom(r); err != nil {
+		return
+	}
+	s.tracker.ObservePacket(int32(id), len(data))
+}
+
+// logEvent 补充连接级字段后记录蜜罐事件
+func (s *connSession) logEvent(event *logger.HoneypotEvent) {
+	if event.EventType == "protocol_violation" {
+		s.violated.Store(true)
+	}
+	if !s.honeypotLogger.IsEnabled() {
+		return
+	}
+	event.ClientIP = s.conn.RemoteIP
+	if event.UserAgent == "" {
+		event.UserAgent = s.brand
+	}
+	event.ClientType, event.Labels = s.classify()
+	s.honeypotLogger.LogEvent(event)
+}
+
+// logCommand 记录陷阱世界中的聊天/命令到独立的命令日志
+func (s *connSession) logCommand(kind, text string) {
+	if !s.honeypotLogger.IsEnabled() {
+		return
+	}
+	s.honeypotLogger.LogCommand(&logger.CommandEvent{
+		SessionID: s.conn.ID,
+		ClientIP:  s.conn.RemoteIP,
+		Username:  s.username,
+		Kind:      kind,
+		OffsetMs:  time.Since(s.joinedAt).Milliseconds(),
+		Text:      text,
+	})
+}
+
+// finish 连接处理结束时保存抓包记录，并为识别出的客户端记录指纹事件
+func (s *connSession) finish() {
+	if err := s.capture.Finish(s.violated.Load()); err != nil {
+		s.conn.Logger.Error().Err(err).Msg("写入抓包记录失败")
+	}
+	if !s.honeypotLogger.IsEnabled() {
+		return
+	}
+	// 生产环境不记录普通的连接尝试，只有识别出客户端类型或命中特征规则时才记录
+	// 连接结束时按完整的采集结果重新分类，客户端类型不变时沿用缓存的特征标签
+	result := s.tracker.Classify()
+	clientType, labels := s.classify()
+	if result.Client != clientType {
+		labels = s.labels(result.Client)
+	}
+	if result.Client == fingerprint.ClientUnknown && len(labels) == 0 {
+		return
+	}
+	event := &logger.HoneypotEvent{
+		ClientIP:    s.conn.RemoteIP,
+		EventType:   "fingerprint",
+		ClientType:  result.Client,
+		Fingerprint: result.String(),
+		Labels:      labels,
+		UserAgent:   s.brand,
+	}
+	if s.handshake != nil {
+		event.ProtocolVersion = s.handshake.ProtocolVersion
+		event.ServerAddress = s.handshake.ServerAddress
+		event.NextState = s.handshake.NextState
+	}
+	s.honeypotLogger.LogEvent(event)
+}
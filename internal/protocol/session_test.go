@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/fingerprint"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/network"
+)
+
+// TestFinishSkipsUnknownClients 无法识别且未命中特征的连接不记录指纹事件
+func TestFinishSkipsUnknownClients(t *testing.T) {
+	var buf bytes.Buffer
+	honeypotLogger := logger.NewHoneypotWriterLogger(&buf)
+	defer honeypotLogger.Close()
+
+	newSession := func(ip string) *connSession {
+		conn := &network.Connection{ID: ip + "-1", RemoteIP: ip, StartTime: time.Now(), Logger: zerolog.Nop()}
+		return newConnSession(conn, honeypotLogger, nil, nil)
+	}
+
+	// 发送了握手但没有可识别特征的连接
+	unknown := newSession("192.0.2.1")
+	unknown.tracker.ObserveBytes([]byte{0x10, 0x00})
+	unknown.setHandshake(&HandshakeInfo{ProtocolVersion: 765, ServerAddress: "mc.example.com", ServerPort: 25565, NextState: 2})
+	unknown.finish()
+
+	// 没有发送任何数据的横幅抓取
+	newSession("192.0.2.2").finish()
+
+	honeypotLogger.Flush()
+	if out := buf.String(); strings.Contains(out, "192.0.2.1") || !strings.Contains(out, "banner_grab") {
+		t.Errorf("指纹事件 = %s", out)
+	}
+}
+
+// TestLogEventCachesClassification 分类结果在握手、用户名或品牌变化之前沿用缓存
+func TestLogEventCachesClassification(t *testing.T) {
+	var buf bytes.Buffer
+	honeypotLogger := logger.NewHoneypotWriterLogger(&buf)
+	defer honeypotLogger.Close()
+
+	conn := &network.Connection{ID: "192.0.2.1-1", RemoteIP: "192.0.2.1", StartTime: time.Now(), Logger: zerolog.Nop()}
+	sess := newConnSession(conn, honeypotLogger, nil, nil)
+	sess.tracker.ObserveBytes([]byte{0x10, 0x00})
+	sess.setHandshake(&HandshakeInfo{ProtocolVersion: 765, ServerAddress: "mc.example.com", ServerPort: 25565, NextState: 2})
+
+	sess.logEvent(&logger.HoneypotEvent{EventType: "handshake"})
+	if client, _ := sess.classify(); client != fingerprint.ClientUnknown {
+		t.Fatalf("classify() = %s", client)
+	}
+
+	// 未经 setBrand 的变化不会触发重新分类
+	sess.tracker.ObserveBrand("fabric")
+	if client, _ := sess.classify(); client != fingerprint.ClientUnknown {
+		t.Errorf("缓存的分类 = %s", client)
+	}
+
+	sess.setBrand("fabric")
+	sess.logEvent(&logger.HoneypotEvent{EventType: "plugin_message"})
+	honeypotLogger.Flush()
+	if out := buf.String(); !strings.Contains(out, `"client_type":"`+fingerprint.ClientFabric+`"`) {
+		t.Errorf("品牌变化后未重新分类: %s", out)
+	}
+}
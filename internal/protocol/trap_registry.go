@@ -0,0 +1,211 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/Tnze/go-mc/chat"
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// 陷阱世界使用的维度
+const (
+	trapDimensionType = "minecraft:overworld"
+	trapDimensionName = "minecraft:overworld"
+)
+
+// registryEntry 注册表条目
+type registryEntry[E any] struct {
+	Name    string `nbt:"name"`
+	ID      int32  `nbt:"id"`
+	Element E      `nbt:"element"`
+}
+
+// registryList 注册表
+type registryList[E any] struct {
+	Type  string             `nbt:"type"`
+	Value []registryEntry[E] `nbt:"value"`
+}
+
+// newRegistryList 按顺序为条目分配 ID
+func newRegistryList[E any](typ string, names []string, element func(name string) E) registryList[E] {
+	list := registryList[E]{Type: typ, Value: make([]registryEntry[E], 0, len(names))}
+	for i, name := range names {
+		list.Value = append(list.Value, registryEntry[E]{
+			Name:    name,
+			ID:      int32(i),
+			Element: element(name),
+		})
+	}
+	return list
+}
+
+// trapChatType 聊天类型
+type trapChatType struct {
+	Chat      chat.Decoration `nbt:"chat"`
+	Narration chat.Decoration `nbt:"narration"`
+}
+
+// trapDamageType 伤害类型
+type trapDamageType struct {
+	MessageID  string  `nbt:"message_id"`
+	Scaling    string  `nbt:"scaling"`
+	Exhaustion float32 `nbt:"exhaustion"`
+}
+
+// trapDimension 维度类型
+type trapDimension struct {
+	PiglinSafe                  bool    `nbt:"piglin_safe"`
+	Natural                     bool    `nbt:"natural"`
+	AmbientLight                float32 `nbt:"ambient_light"`
+	Infiniburn                  string  `nbt:"infiniburn"`
+	RespawnAnchorWorks          bool    `nbt:"respawn_anchor_works"`
+	HasSkylight                 bool    `nbt:"has_skylight"`
+	BedWorks                    bool    `nbt:"bed_works"`
+	Effects                     string  `nbt:"effects"`
+	HasRaids                    bool    `nbt:"has_raids"`
+	MinY                        int32   `nbt:"min_y"`
+	Height                      int32   `nbt:"height"`
+	LogicalHeight               int32   `nbt:"logical_height"`
+	CoordinateScale             float64 `nbt:"coordinate_scale"`
+	Ultrawarm                   bool    `nbt:"ultrawarm"`
+	HasCeiling                  bool    `nbt:"has_ceiling"`
+	MonsterSpawnLightLevel      int32   `nbt:"monster_spawn_light_level"`
+	MonsterSpawnBlockLightLimit int32   `nbt:"monster_spawn_block_light_limit"`
+}
+
+// trapBiome 生物群系
+type trapBiome struct {
+	HasPrecipitation bool    `nbt:"has_precipitation"`
+	Temperature      float32 `nbt:"temperature"`
+	Downfall         float32 `nbt:"downfall"`
+	Effects          struct {
+		SkyColor      int32 `nbt:"sky_color"`
+		WaterFogColor int32 `nbt:"water_fog_color"`
+		FogColor      int32 `nbt:"fog_color"`
+		WaterColor    int32 `nbt:"water_color"`
+	} `nbt:"effects"`
+}
+
+// trapRegistryCodec 1.20 ~ 1.20.4 客户端需要的同步注册表
+type trapRegistryCodec struct {
+	ChatType      registryList[trapChatType]   `nbt:"minecraft:chat_type"`
+	DamageType    registryList[trapDamageType] `nbt:"minecraft:damage_type"`
+	DimensionType registryList[trapDimension]  `nbt:"minecraft:dimension_type"`
+	TrimMaterial  registryList[struct{}]       `nbt:"minecraft:trim_material"`
+	TrimPattern   registryList[struct{}]       `nbt:"minecraft:trim_pattern"`
+	Biome         registryList[trapBiome]      `nbt:"minecraft:worldgen/biome"`
+}
+
+// trapDamageTypes 客户端创建世界时会逐个查找原版伤害类型，缺失任何一个都会导致客户端断开
+// 这里取 1.20 ~ 1.20.4 的并集，多出的条目不影响旧版本
+var trapDamageTypes = map[string]string{
+	"minecraft:arrow":                 "arrow",
+	"minecraft:bad_respawn_point":     "badRespawnPoint",
+	"minecraft:cactus":                "cactus",
+	"minecraft:cramming":              "cramming",
+	"minecraft:dragon_breath":         "dragonBreath",
+	"minecraft:drown":                 "drown",
+	"minecraft:dry_out":               "dryout",
+	"minecraft:explosion":             "explosion",
+	"minecraft:fall":                  "fall",
+	"minecraft:falling_anvil":         "anvil",
+	"minecraft:falling_block":         "fallingBlock",
+	"minecraft:falling_stalactite":    "fallingStalactite",
+	"minecraft:fireball":              "fireball",
+	"minecraft:fireworks":             "fireworks",
+	"minecraft:fly_into_wall":         "flyIntoWall",
+	"minecraft:freeze":                "freeze",
+	"minecraft:generic":               "generic",
+	"minecraft:generic_kill":          "genericKill",
+	"minecraft:hot_floor":             "hotFloor",
+	"minecraft:in_fire":               "inFire",
+	"minecraft:in_wall":               "inWall",
+	"minecraft:indirect_magic":        "indirectMagic",
+	"minecraft:lava":                  "lava",
+	"minecraft:lightning_bolt":        "lightningBolt",
+	"minecraft:magic":                 "magic",
+	"minecraft:mob_attack":            "mob",
+	"minecraft:mob_attack_no_aggro":   "mob",
+	"minecraft:mob_projectile":        "mob",
+	"minecraft:on_fire":               "onFire",
+	"minecraft:out_of_world":          "outOfWorld",
+	"minecraft:fell_out_of_world":     "outOfWorld",
+	"minecraft:outside_border":        "outsideBorder",
+	"minecraft:player_attack":         "player",
+	"minecraft:player_explosion":      "explosion.player",
+	"minecraft:sonic_boom":            "sonic_boom",
+	"minecraft:stalagmite":            "stalagmite",
+	"minecraft:starve":                "starve",
+	"minecraft:sting":                 "sting",
+	"minecraft:sweet_berry_bush":      "sweetBerryBush",
+	"minecraft:thorns":                "thorns",
+	"minecraft:thrown":                "thrown",
+	"minecraft:trident":               "trident",
+	"minecraft:unattributed_fireball": "onFire",
+	"minecraft:wither":                "wither",
+	"minecraft:wither_skull":          "witherSkull",
+}
+
+// trapRegistry 陷阱世界的注册表，只构建一次
+var trapRegistry = sync.OnceValue(func() *trapRegistryCodec {
+	damageNames := make([]string, 0, len(trapDamageTypes))
+	for name := range trapDamageTypes {
+		damageNames = append(damageNames, name)
+	}
+	slices.Sort(damageNames)
+
+	return &trapRegistryCodec{
+		ChatType: newRegistryList("minecraft:chat_type", []string{"minecraft:chat"}, func(string) trapChatType {
+			return trapChatType{
+				Chat:      chat.Decoration{TranslationKey: "chat.type.text", Parameters: []string{"sender", "content"}},
+				Narration: chat.Decoration{TranslationKey: "chat.type.text.narrate", Parameters: []string{"sender", "content"}},
+			}
+		}),
+		DamageType: newRegistryList("minecraft:damage_type", damageNames, func(name string) trapDamageType {
+			return trapDamageType{
+				MessageID:  trapDamageTypes[name],
+				Scaling:    "when_caused_by_living_non_player",
+				Exhaustion: 0.1,
+			}
+		}),
+		DimensionType: newRegistryList("minecraft:dimension_type", []string{trapDimensionType}, func(string) trapDimension {
+			return trapDimension{
+				Infiniburn:      "#minecraft:infiniburn_overworld",
+				Effects:         "minecraft:the_end", // 虚空世界使用末地的天空效果
+				MinY:            0,
+				Height:          256,
+				LogicalHeight:   256,
+				CoordinateScale: 1,
+			}
+		}),
+		TrimMaterial: registryList[struct{}]{Type: "minecraft:trim_material", Value: []registryEntry[struct{}]{}},
+		TrimPattern:  registryList[struct{}]{Type: "minecraft:trim_pattern", Value: []registryEntry[struct{}]{}},
+		Biome: newRegistryList("minecraft:worldgen/biome", []string{"minecraft:plains", "minecraft:the_void"}, func(string) trapBiome {
+			var biome trapBiome
+			biome.Temperature = 0.5
+			biome.Downfall = 0.5
+			biome.Effects.SkyColor = 0x000000
+			biome.Effects.WaterFogColor = 0x050533
+			biome.Effects.FogColor = 0x000000
+			biome.Effects.WaterColor = 0x3F76E4
+			return biome
+		}),
+	}
+})
+
+// namedNBT 以带根标签名的格式编码 NBT（1.20.2 之前的协议）
+type namedNBT struct {
+	v any
+}
+
+// WriteTo 实现 pk.FieldEncoder
+func (n namedNBT) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if err := nbt.NewEncoder(&buf).Encode(n.v, ""); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
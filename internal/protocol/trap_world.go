@@ -0,0 +1,327 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Tnze/go-mc/chat"
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+	"github.com/Tnze/go-mc/offline"
+
+	"fake-mc-server/internal/logger"
+)
+
+const (
+	// trapKeepAliveInterval 发送心跳包的间隔，客户端 30 秒没有收到数据会自行断开
+	trapKeepAliveInterval = 10 * time.Second
+	// trapMaxConfigPackets 配置阶段最多读取的数据包数量
+	trapMaxConfigPackets = 64
+	// trapMaxMessageLen 蜜罐事件中记录的聊天/命令内容最大长度
+	trapMaxMessageLen = 256
+	// trapMaxCommandLen 命令日志中记录的最大长度（协议允许的最大字符串长度）
+	trapMaxCommandLen = 32767
+	// trapSpawnY 出生点高度
+	trapSpawnY = 100
+)
+
+// 登录与配置阶段的数据包 ID（763 ~ 765 相同）
+const (
+	loginSuccessID             = 0x02
+	loginAcknowledgedID        = 0x03
+	configFinishID             = 0x02
+	configRegistryDataID       = 0x05
+	configPluginServerboundID  = 0x01
+	configFinishAcknowledgedID = 0x02
+)
+
+// trapProtocol 陷阱世界支持的协议版本及其游戏阶段数据包 ID
+type trapProtocol struct {
+	configuration bool // 1.20.2+ 登录成功后进入配置阶段
+	nbtText       bool // 1.20.3+ 文本组件使用 NBT 编码
+	levelEvent    bool // 1.20.3+ 需要“开始等待区块”游戏事件才会关闭加载界面
+
+	// 客户端方向
+	login          int32
+	keepAlive      int32
+	disconnect     int32
+	gameEvent      int32
+	playerPosition int32
+	spawnPosition  int32
+
+	// 服务端方向
+	chatCommand   int32
+	chat          int32
+	customPayload int32
+}
+
+// trapProtocols 陷阱世界支持的协议版本，其他版本仍然直接踢出
+var trapProtocols = map[int32]trapProtocol{
+	763: { // 1.20 / 1.20.1
+		login: 0x28, keepAlive: 0x23, disconnect: 0x1A, gameEvent: 0x1F, playerPosition: 0x3C, spawnPosition: 0x50,
+		chatCommand: 0x04, chat: 0x05, customPayload: 0x0D,
+	},
+	764: { // 1.20.2
+		configuration: true,
+		login:         0x29, keepAlive: 0x24, disconnect: 0x1B, gameEvent: 0x20, playerPosition: 0x3E, spawnPosition: 0x52,
+		chatCommand: 0x04, chat: 0x05, customPayload: 0x0F,
+	},
+	765: { // 1.20.3 / 1.20.4
+		configuration: true, nbtText: true, levelEvent: true,
+		login: 0x29, keepAlive: 0x24, disconnect: 0x1B, gameEvent: 0x20, playerPosition: 0x3E, spawnPosition: 0x54,
+		chatCommand: 0x04, chat: 0x05, customPayload: 0x10,
+	},
+}
+
+// runTrapWorld 完成离线模式登录并让客户端进入虚空世界
+// 记录客户端发送的聊天、命令和插件消息，到达配置的时长后断开
+func (h *GoMCHandler) runTrapWorld(ctx context.Context, mcConn *net.Conn, sess *connSession, tp trapProtocol, username string) error {
+	conn := sess.conn
+	playerID := offline.NameToUUID(username)
+
+	err := mcConn.WritePacket(pk.Marshal(
+		loginSuccessID,
+		pk.UUID(playerID),
+		pk.String(username),
+		pk.VarInt(0), // 无皮肤等属性
+	))
+	if err != nil {
+		return fmt.Errorf("发送登录成功包失败: %w", err)
+	}
+
+	if tp.configuration {
+		if err := h.configureTrapClient(mcConn, sess); err != nil {
+			return err
+		}
+	}
+
+	if err := h.joinTrapWorld(mcConn, tp); err != nil {
+		return err
+	}
+
+	sess.joinedAt = time.Now()
+	conn.Logger.Info().Str("username", username).Msg("客户端进入陷阱世界")
+	sess.logEvent(&logger.HoneypotEvent{
+		EventType: "trap_join",
+		Username:  username,
+	})
+
+	// 读取协程负责记录客户端数据包，当前协程只负责写入
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var p pk.Packet
+			if err := sess.readPacket(mcConn, &p); err != nil {
+				readErr <- err
+				return
+			}
+			h.recordTrapPacket(sess, tp, &p)
+		}
+	}()
+
+	keepAlive := time.NewTicker(trapKeepAliveInterval)
+	defer keepAlive.Stop()
+	deadline := time.NewTimer(h.config.Login.TrapWorld.Duration)
+	defer deadline.Stop()
+
+	var reason string
+	for reason == "" {
+		select {
+		case <-ctx.Done():
+			reason = "shutdown"
+		case err := <-readErr:
+			reason = "client_closed"
+			if errors.Is(err, errPacketTooLarge) {
+				reason = "packet_too_large"
+			}
+			conn.Logger.Debug().Err(err).Msg("陷阱世界客户端断开")
+		case <-deadline.C:
+			reason = "timeout"
+			if err := mcConn.WritePacket(pk.Marshal(tp.disconnect, h.trapText(tp, h.config.Messages.KickMessage))); err != nil {
+				conn.Logger.Debug().Err(err).Msg("发送断开连接包失败")
+			}
+		case <-keepAlive.C:
+			if err := mcConn.WritePacket(pk.Marshal(tp.keepAlive, pk.Long(time.Now().UnixMilli()))); err != nil {
+				reason = "write_failed"
+			}
+		}
+	}
+
+	// 关闭连接并等待读取协程退出，保证离开事件是该连接的最后一条陷阱世界事件
+	mcConn.Close()
+	if reason != "client_closed" && reason != "packet_too_large" {
+		<-readErr
+	}
+
+	stayed := time.Since(sess.joinedAt)
+	conn.Logger.Info().Str("reason", reason).Dur("stayed", stayed).Msg("客户端离开陷阱世界")
+	sess.logEvent(&logger.HoneypotEvent{
+		EventType:    "trap_leave",
+		Username:     username,
+		ErrorMessage: reason,
+	})
+	return nil
+}
+
+// configureTrapClient 1.20.2+ 的配置阶段：发送注册表后等待客户端确认
+func (h *GoMCHandler) configureTrapClient(mcConn *net.Conn, sess *connSession) error {
+	var p pk.Packet
+	if err := h.awaitTrapPacket(mcConn, sess, &p, loginAcknowledgedID, nil); err != nil {
+		return fmt.Errorf("等待登录确认失败: %w", err)
+	}
+
+	if err := mcConn.WritePacket(pk.Marshal(configRegistryDataID, pk.NBT(trapRegistry()))); err != nil {
+		return fmt.Errorf("发送注册表失败: %w", err)
+	}
+	if err := mcConn.WritePacket(pk.Marshal(configFinishID)); err != nil {
+		return fmt.Errorf("发送配置完成包失败: %w", err)
+	}
+
+	// 配置阶段客户端会发送 minecraft:brand 等插件消息
+	recordPlugin := func(p *pk.Packet) {
+		if p.ID == configPluginServerboundID {
+			h.recordPluginMessage(sess, p)
+		}
+	}
+	if err := h.awaitTrapPacket(mcConn, sess, &p, configFinishAcknowledgedID, recordPlugin); err != nil {
+		return fmt.Errorf("等待配置完成确认失败: %w", err)
+	}
+	return nil
+}
+
+// awaitTrapPacket 读取数据包直到收到指定 ID，其他数据包交给 other 处理
+func (h *GoMCHandler) awaitTrapPacket(mcConn *net.Conn, sess *connSession, p *pk.Packet, id int32, other func(*pk.Packet)) error {
+	for range trapMaxConfigPackets {
+		if err := sess.readPacket(mcConn, p); err != nil {
+			return err
+		}
+		if p.ID == id {
+			return nil
+		}
+		if other != nil {
+			other(p)
+		}
+	}
+	return fmt.Errorf("超过 %d 个数据包仍未收到 %#02X", trapMaxConfigPackets, id)
+}
+
+// joinTrapWorld 发送加入游戏、出生点和玩家位置
+func (h *GoMCHandler) joinTrapWorld(mcConn *net.Conn, tp trapProtocol) error {
+	dimensions := pk.Ary[pk.VarInt]{Ary: []pk.Identifier{trapDimensionName}}
+	maxPlayers := pk.VarInt(h.config.Messages.MaxPlayers)
+
+	var login pk.Packet
+	if tp.configuration {
+		login = pk.Marshal(
+			tp.login,
+			pk.Int(1),         // 实体 ID
+			pk.Boolean(false), // 极限模式
+			dimensions,        // 维度列表
+			maxPlayers,        // 最大玩家数
+			pk.VarInt(2),      // 视距
+			pk.VarInt(2),      // 模拟距离
+			pk.Boolean(false), // 简化调试信息
+			pk.Boolean(true),  // 显示重生界面
+			pk.Boolean(false), // 限制合成
+			pk.Identifier(trapDimensionType),
+			pk.Identifier(trapDimensionName),
+			pk.Long(0),         // 种子哈希
+			pk.UnsignedByte(0), // 生存模式
+			pk.Byte(-1),        // 上一个游戏模式
+			pk.Boolean(false),  // 调试世界
+			pk.Boolean(false),  // 超平坦
+			pk.Boolean(false),  // 无死亡位置
+			pk.VarInt(0),       // 传送门冷却
+		)
+	} else {
+		login = pk.Marshal(
+			tp.login,
+			pk.Int(1),          // 实体 ID
+			pk.Boolean(false),  // 极限模式
+			pk.UnsignedByte(0), // 生存模式
+			pk.Byte(-1),        // 上一个游戏模式
+			dimensions,         // 维度列表
+			namedNBT{trapRegistry()},
+			pk.Identifier(trapDimensionType),
+			pk.Identifier(trapDimensionName),
+			pk.Long(0),        // 种子哈希
+			maxPlayers,        // 最大玩家数
+			pk.VarInt(2),      // 视距
+			pk.VarInt(2),      // 模拟距离
+			pk.Boolean(false), // 简化调试信息
+			pk.Boolean(true),  // 显示重生界面
+			pk.Boolean(false), // 调试世界
+			pk.Boolean(false), // 超平坦
+			pk.Boolean(false), // 无死亡位置
+			pk.VarInt(0),      // 传送门冷却
+		)
+	}
+
+	packets := []pk.Packet{
+		login,
+		pk.Marshal(tp.spawnPosition, pk.Position{X: 0, Y: trapSpawnY, Z: 0}, pk.Float(0)),
+	}
+	if tp.levelEvent {
+		packets = append(packets, pk.Marshal(tp.gameEvent, pk.UnsignedByte(13), pk.Float(0)))
+	}
+	packets = append(packets, pk.Marshal(
+		tp.playerPosition,
+		pk.Double(0.5), pk.Double(trapSpawnY), pk.Double(0.5),
+		pk.Float(0), pk.Float(0),
+		pk.Byte(0),   // 绝对坐标
+		pk.VarInt(1), // 传送 ID
+	))
+
+	for _, p := range packets {
+		if err := mcConn.WritePacket(p); err != nil {
+			return fmt.Errorf("发送加入游戏数据包失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordTrapPacket 记录游戏阶段客户端发送的聊天、命令和插件消息
+func (h *GoMCHandler) recordTrapPacket(sess *connSession, tp trapProtocol, p *pk.Packet) {
+	switch p.ID {
+	case tp.chat, tp.chatCommand:
+		// 聊天和命令包的第一个字段都是文本，后面的签名等字段不需要
+		var text pk.String
+		if _, err := text.ReadFrom(bytes.NewReader(p.Data)); err != nil {
+			return
+		}
+		eventType := "chat"
+		if p.ID == tp.chatCommand {
+			eventType = "command"
+		}
+		sess.logEvent(&logger.HoneypotEvent{
+			EventType: eventType,
+			Username:  sess.username,
+			Message:   truncate(string(text), trapMaxMessageLen),
+		})
+		sess.logCommand(eventType, truncate(string(text), trapMaxCommandLen))
+	case tp.customPayload:
+		h.recordPluginMessage(sess, p)
+	}
+}
+
+// trapText 按协议版本编码文本组件
+func (h *GoMCHandler) trapText(tp trapProtocol, text string) pk.FieldEncoder {
+	if tp.nbtText {
+		return pk.NBT(struct {
+			Text string `nbt:"text"`
+		}{text})
+	}
+	return chat.Message{Text: text}
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,160 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+	"fake-mc-server/internal/network"
+)
+
+// fakeBotClient 模拟 1.20.4 机器人：完成配置阶段后发送命令和聊天消息再断开
+func fakeBotClient(c stdnet.Conn, tp trapProtocol) error {
+	mcConn := net.WrapConn(c)
+	defer mcConn.Close()
+
+	var p pk.Packet
+	expect := func(id int32) error {
+		if err := mcConn.ReadPacket(&p); err != nil {
+			return err
+		}
+		if p.ID != id {
+			return fmt.Errorf("期望数据包 %#02X, 收到 %#02X", id, p.ID)
+		}
+		return nil
+	}
+
+	steps := []func() error{
+		func() error { return expect(loginSuccessID) },
+		func() error { return mcConn.WritePacket(pk.Marshal(loginAcknowledgedID)) },
+		func() error { return expect(configRegistryDataID) },
+		func() error { return expect(configFinishID) },
+		func() error {
+			return mcConn.WritePacket(pk.Marshal(configPluginServerboundID, pk.Identifier("minecraft:brand"), pk.String("vanilla")))
+		},
+		func() error { return mcConn.WritePacket(pk.Marshal(configFinishAcknowledgedID)) },
+		func() error { return expect(tp.login) },
+		func() error { return expect(tp.spawnPosition) },
+		func() error { return expect(tp.gameEvent) },
+		func() error { return expect(tp.playerPosition) },
+		func() error { return mcConn.WritePacket(pk.Marshal(tp.chatCommand, pk.String("op Griefer"))) },
+		func() error { return mcConn.WritePacket(pk.Marshal(tp.chat, pk.String("hello"), pk.Long(0))) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTrapWorldRecordsBotActivity(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "honeypot.log")
+	honeypotLogger, err := logger.NewHoneypotLogger(&config.HoneypotLoggingConfig{
+		Enabled:  true,
+		FilePath: logPath,
+		Format:   "json",
+	})
+	if err != nil {
+		t.Fatalf("NewHoneypotLogger() error = %v", err)
+	}
+	defer honeypotLogger.Close()
+
+	cfg := &config.Config{}
+	cfg.Login.TrapWorld.Enabled = true
+	cfg.Login.TrapWorld.Duration = time.Minute
+	cfg.Security.MaxPacketSize = 1 << 20
+	h := NewGoMCHandler(cfg, zerolog.Nop(), nil, honeypotLogger, nil, nil, nil, nil, nil)
+
+	tp := trapProtocols[765]
+	serverSide, clientSide := stdnet.Pipe()
+	clientErr := make(chan error, 1)
+	go func() { clientErr <- fakeBotClient(clientSide, tp) }()
+
+	conn := &network.Connection{ID: "192.0.2.1-1", RemoteIP: "192.0.2.1", StartTime: time.Now(), Logger: zerolog.Nop()}
+	sess := newConnSession(conn, honeypotLogger, nil, nil)
+	sess.setUsername("Griefer")
+	if err := h.runTrapWorld(context.Background(), net.WrapConn(serverSide), sess, tp, "Griefer"); err != nil {
+		t.Fatalf("runTrapWorld() error = %v", err)
+	}
+	if err := <-clientErr; err != nil {
+		t.Fatalf("客户端错误: %v", err)
+	}
+
+	honeypotLogger.Flush()
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatalf("打开蜜罐日志失败: %v", err)
+	}
+	defer f.Close()
+
+	var got []logger.HoneypotEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event logger.HoneypotEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("解析事件失败: %v", err)
+		}
+		got = append(got, event)
+	}
+
+	want := []struct {
+		eventType string
+		detail    string
+	}{
+		{"plugin_message", "minecraft:brand"},
+		{"trap_join", ""},
+		{"command", "op Griefer"},
+		{"chat", "hello"},
+		{"trap_leave", "client_closed"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("记录了 %d 条事件, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		detail := e.Message + e.Channel
+		if e.EventType == "trap_leave" {
+			detail = e.ErrorMessage
+		}
+		if e.EventType != w.eventType || detail != w.detail {
+			t.Errorf("事件 %d = (%s, %q), want (%s, %q)", i, e.EventType, detail, w.eventType, w.detail)
+		}
+		if e.UserAgent != "vanilla" {
+			t.Errorf("事件 %d UserAgent = %q, want \"vanilla\"", i, e.UserAgent)
+		}
+	}
+	commands, err := os.ReadFile(filepath.Join(filepath.Dir(logPath), "commands.log"))
+	if err != nil {
+		t.Fatalf("读取命令日志失败: %v", err)
+	}
+	var kinds, texts []string
+	for _, line := range bytes.Split(bytes.TrimSpace(commands), []byte("\n")) {
+		var event logger.CommandEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatalf("解析命令事件失败: %v", err)
+		}
+		if event.SessionID != conn.ID || event.Username != "Griefer" || event.OffsetMs < 0 {
+			t.Errorf("命令事件字段错误: %+v", event)
+		}
+		kinds = append(kinds, event.Kind)
+		texts = append(texts, event.Text)
+	}
+	if !slices.Equal(kinds, []string{"command", "chat"}) || !slices.Equal(texts, []string{"op Griefer", "hello"}) {
+		t.Errorf("命令日志 = %v %v", kinds, texts)
+	}
+}
@@ -0,0 +1,182 @@
+// Package rdns 异步反向解析客户端 IP，并按 PTR 主机名规则识别云厂商、扫描器组织等来源
+package rdns
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+// Resolver PTR 查询接口，*net.Resolver 满足该接口，测试中可替换
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// Result 反向解析结果
+type Result struct {
+	Hostname string // PTR 主机名，不含末尾的点
+	Provider string // 命中规则的来源分类，如 aws、shodan、residential
+}
+
+// entry 缓存条目
+type entry struct {
+	result  Result
+	expires time.Time
+}
+
+// Tagger 带缓存和限速的异步 PTR 解析器
+// 查询不在调用方协程中进行：缓存未命中时放入队列，由后台协程解析，之后的事件即可带上结果
+type Tagger struct {
+	config   *config.ReverseDNSConfig
+	resolver Resolver
+	logger   zerolog.Logger
+	limiter  *rate.Limiter
+	queue    chan string
+
+	mu      sync.Mutex
+	cache   map[string]entry
+	pending map[string]struct{}
+	now     func() time.Time
+}
+
+// NewTagger 创建解析器，未启用时返回 nil
+func NewTagger(cfg *config.ReverseDNSConfig, resolver Resolver, logger zerolog.Logger) *Tagger {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Tagger{
+		config:   cfg,
+		resolver: resolver,
+		logger:   logger.With().Str("component", "rdns").Logger(),
+		limiter:  rate.NewLimiter(rate.Limit(cfg.RateLimit), max(1, int(cfg.RateLimit))),
+		queue:    make(chan string, cfg.QueueSize),
+		cache:    make(map[string]entry),
+		pending:  make(map[string]struct{}),
+		now:      time.Now,
+	}
+}
+
+// Lookup 返回缓存中的结果；未命中时安排后台解析并返回 false
+func (t *Tagger) Lookup(ip string) (Result, bool) {
+	if t == nil || ip == "" {
+		return Result{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.cache[ip]; ok && t.now().Before(e.expires) {
+		return e.result, true
+	}
+	if _, ok := t.pending[ip]; ok {
+		return Result{}, false
+	}
+
+	select {
+	case t.queue <- ip:
+		t.pending[ip] = struct{}{}
+	default:
+		// 队列已满，等该 IP 的下一个事件再尝试
+	}
+	return Result{}, false
+}
+
+// Enrich 为蜜罐事件补充 PTR 主机名和来源分类，注册为 HoneypotLogger 的补充函数
+func (t *Tagger) Enrich(event *logger.HoneypotEvent) {
+	if result, ok := t.Lookup(event.ClientIP); ok {
+		event.ReverseDNS = result.Hostname
+		event.Provider = result.Provider
+	}
+}
+
+// Run 启动解析协程，直到 ctx 取消
+func (t *Tagger) Run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for range t.config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.worker(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// worker 从队列中取出 IP 并限速解析
+func (t *Tagger) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ip := <-t.queue:
+			if err := t.limiter.Wait(ctx); err != nil {
+				return
+			}
+			t.store(ip, t.resolve(ctx, ip))
+		}
+	}
+}
+
+// resolve 查询 PTR 记录，失败时返回空结果（按否定缓存时间保存）
+func (t *Tagger) resolve(ctx context.Context, ip string) Result {
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	names, err := t.resolver.LookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		if err != nil {
+			t.logger.Debug().Err(err).Str("ip", ip).Msg("PTR 查询失败")
+		}
+		return Result{}
+	}
+	hostname := strings.ToLower(strings.TrimSuffix(names[0], "."))
+	return Result{Hostname: hostname, Provider: t.classify(hostname)}
+}
+
+// classify 按配置顺序匹配主机名规则，返回第一条命中规则的分类
+func (t *Tagger) classify(hostname string) string {
+	for _, rule := range t.config.Providers {
+		if ok, _ := path.Match(strings.ToLower(rule.Pattern), hostname); ok {
+			return rule.Provider
+		}
+	}
+	return ""
+}
+
+// store 保存解析结果
+func (t *Tagger) store(ip string, result Result) {
+	ttl := t.config.CacheTTL
+	if result.Hostname == "" {
+		ttl = t.config.NegativeTTL
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, ip)
+	if len(t.cache) >= t.config.CacheSize {
+		// 缓存写满时先清掉过期条目，仍然不够就整体清空
+		now := t.now()
+		for k, e := range t.cache {
+			if now.After(e.expires) {
+				delete(t.cache, k)
+			}
+		}
+		if len(t.cache) >= t.config.CacheSize {
+			clear(t.cache)
+		}
+	}
+	t.cache[ip] = entry{result: result, expires: t.now().Add(ttl)}
+}
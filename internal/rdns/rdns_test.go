@@ -0,0 +1,93 @@
+package rdns
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+// stubResolver 固定的 PTR 记录，并统计查询次数
+type stubResolver struct {
+	records map[string]string
+	calls   atomic.Int32
+}
+
+func (r *stubResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	r.calls.Add(1)
+	if name, ok := r.records[addr]; ok {
+		return []string{name}, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestTaggerResolvesAsynchronously(t *testing.T) {
+	resolver := &stubResolver{records: map[string]string{
+		"192.0.2.1": "ec2-192-0-2-1.compute-1.AmazonAWS.com.",
+		"192.0.2.2": "census1.shodan.io.",
+		"192.0.2.3": "host-3.example.net.",
+	}}
+	tagger := NewTagger(&config.ReverseDNSConfig{
+		Enabled:     true,
+		Workers:     2,
+		RateLimit:   1000,
+		Timeout:     time.Second,
+		CacheTTL:    time.Hour,
+		NegativeTTL: time.Minute,
+		CacheSize:   16,
+		QueueSize:   16,
+		Providers: []config.ProviderRule{
+			{Pattern: "*.amazonaws.com", Provider: "aws"},
+			{Pattern: "*.shodan.io", Provider: "shodan"},
+		},
+	}, resolver, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tagger.Run(ctx)
+
+	want := map[string]Result{
+		"192.0.2.1": {Hostname: "ec2-192-0-2-1.compute-1.amazonaws.com", Provider: "aws"},
+		"192.0.2.2": {Hostname: "census1.shodan.io", Provider: "shodan"},
+		"192.0.2.3": {Hostname: "host-3.example.net"},
+		"192.0.2.4": {},
+	}
+	for ip, w := range want {
+		// 第一次查询只会安排解析
+		if _, ok := tagger.Lookup(ip); ok {
+			t.Errorf("Lookup(%s) 首次查询不应命中缓存", ip)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			got, ok := tagger.Lookup(ip)
+			if ok {
+				if got != w {
+					t.Errorf("Lookup(%s) = %+v, want %+v", ip, got, w)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Lookup(%s) 超时未解析", ip)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	if n := resolver.calls.Load(); n != 4 {
+		t.Errorf("解析器被调用 %d 次, want 4（结果应被缓存）", n)
+	}
+
+	event := &logger.HoneypotEvent{ClientIP: "192.0.2.2"}
+	tagger.Enrich(event)
+	if event.ReverseDNS != "census1.shodan.io" || event.Provider != "shodan" {
+		t.Errorf("Enrich() = %+v", event)
+	}
+
+	var nilTagger *Tagger
+	nilTagger.Enrich(event)
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fake-mc-server/internal/logger"
+)
+
+// backupTimeFormat lumberjack 备份文件名中的时间格式
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// LogFiles 返回日志文件及其 lumberjack 轮转备份（name-<时间>.ext，压缩后追加 .gz），按时间从旧到新排列
+func LogFiles(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(path, ext) + "-"
+
+	var files []string
+	for _, pattern := range []string{prefix + "*" + ext, prefix + "*" + ext + ".gz"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			stamp := strings.TrimSuffix(strings.TrimSuffix(m, ".gz"), ext)[len(prefix):]
+			if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+				files = append(files, m)
+			}
+		}
+	}
+	// 备份文件名中的时间戳格式固定，按名称排序即按时间排序
+	slices.SortFunc(files, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz"))
+	})
+
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("未找到日志文件: %s", path)
+	}
+	return files, nil
+}
+
+// ReadFile 读取一个日志文件中的全部事件，.gz 文件自动解压
+func ReadFile(path string, fn func(*logger.HoneypotEvent) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if err := logger.ReadEvents(r, fn); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ParseTime 解析命令行和查询参数中的时间：相对时长（表示 now 之前）、RFC3339 时间或本地日期
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q", s)
+}
@@ -0,0 +1,173 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// barWidth 文本直方图最长的条形字符数
+const barWidth = 40
+
+// WriteText 以纯文本输出报告
+func WriteText(w io.Writer, r *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "蜜罐攻击报告 (生成于 %s)\n", r.GeneratedAt.Format(time.DateTime))
+	if r.Events > 0 {
+		fmt.Fprintf(tw, "时间范围: %s ~ %s\n", r.First.Local().Format(time.DateTime), r.Last.Local().Format(time.DateTime))
+	}
+	fmt.Fprintf(tw, "事件: %d  独立IP: %d (新 %d / 回访 %d)  攻击会话: %d  日志文件: %d\n",
+		r.Events, r.UniqueIPs, r.NewIPs, r.ReturningIPs, r.Sessions, len(r.Files))
+
+	section := func(title string, counts []Count) {
+		fmt.Fprintf(tw, "\n== %s\n", title)
+		if len(counts) == 0 {
+			fmt.Fprintln(tw, "(无)")
+			return
+		}
+		for _, c := range counts {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", c.Key, c.Count, c.Label)
+		}
+	}
+	section("事件类型", r.EventTypes)
+	section("来源IP", r.TopIPs)
+	section("来源ASN", r.TopASNs)
+	section("目标主机名", r.TopHostnames)
+	section("登录用户名", r.Usernames)
+	section("协议版本", r.Protocols)
+
+	fmt.Fprintf(tw, "\n== 每日新增/回访IP\n")
+	for _, d := range r.Daily {
+		fmt.Fprintf(tw, "%s\t新 %d\t回访 %d\n", d.Day, d.New, d.Returning)
+	}
+
+	fmt.Fprintf(tw, "\n== 每小时事件数\n")
+	peak := maxHourly(r.Hourly)
+	for _, h := range r.Hourly {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", h.Hour.Format("2006-01-02 15:00"), h.Events, strings.Repeat("#", scale(h.Events, peak, barWidth)))
+	}
+	return tw.Flush()
+}
+
+// WriteJSON 以 JSON 输出报告
+func WriteJSON(w io.Writer, r *Report) error {
+	data, err := sonic.ConfigStd.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// WriteHTML 输出不依赖外部资源的静态 HTML 页面
+func WriteHTML(w io.Writer, r *Report) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+// maxHourly 每小时事件数的最大值
+func maxHourly(hours []HourBucket) int {
+	peak := 0
+	for _, h := range hours {
+		peak = max(peak, h.Events)
+	}
+	return peak
+}
+
+// scale 将 n 按 peak 缩放到 [0, width]，非零值至少为 1
+func scale(n, peak, width int) int {
+	if n == 0 || peak == 0 {
+		return 0
+	}
+	return max(1, n*width/peak)
+}
+
+// maxCount 排行榜中的最大数量
+func maxCount(counts []Count) int {
+	if len(counts) == 0 {
+		return 0
+	}
+	return counts[0].Count
+}
+
+// htmlSection HTML 页面中的一个排行榜
+type htmlSection struct {
+	Title  string
+	Counts []Count
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent":  func(n, peak int) int { return scale(n, peak, 100) },
+	"peak":     maxCount,
+	"hourPeak": maxHourly,
+	"datetime": func(t time.Time) string { return t.Local().Format(time.DateTime) },
+	"hour":     func(t time.Time) string { return t.Format("2006-01-02 15:00") },
+	"section": func(title string, counts []Count) htmlSection {
+		return htmlSection{Title: title, Counts: counts}
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>蜜罐攻击报告</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 1100px; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.1em; margin-top: 2em; border-bottom: 1px solid #ddd; }
+.summary span { display: inline-block; margin-right: 2em; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+td { padding: 2px 6px; vertical-align: middle; }
+td.key { width: 30%; font-family: monospace; word-break: break-all; }
+td.num { width: 8%; text-align: right; }
+.bar { background: #4a7bd0; height: 0.9em; }
+.grid { display: grid; grid-template-columns: 1fr 1fr; gap: 0 2em; }
+.hist { display: flex; align-items: flex-end; height: 160px; gap: 1px; }
+.hist div { flex: 1; background: #d0674a; min-width: 1px; }
+</style>
+</head>
+<body>
+<h1>蜜罐攻击报告</h1>
+<p class="summary">
+<span>生成于 {{datetime .GeneratedAt}}</span>
+{{if .Events}}<span>时间范围 {{datetime .First}} ~ {{datetime .Last}}</span>{{end}}
+</p>
+<p class="summary">
+<span>事件 <b>{{.Events}}</b></span>
+<span>独立IP <b>{{.UniqueIPs}}</b></span>
+<span>新IP <b>{{.NewIPs}}</b></span>
+<span>回访IP <b>{{.ReturningIPs}}</b></span>
+<span>攻击会话 <b>{{.Sessions}}</b></span>
+</p>
+
+<h2>每小时事件数</h2>
+{{$hp := hourPeak .Hourly}}
+<div class="hist">{{range .Hourly}}<div style="height: {{percent .Events $hp}}%" title="{{hour .Hour}}: {{.Events}}"></div>{{end}}</div>
+
+{{define "counts"}}<h2>{{.Title}}</h2>
+<table>{{$p := peak .Counts}}{{range .Counts}}
+<tr><td class="key">{{.Key}}{{if .Label}} <small>{{.Label}}</small>{{end}}</td><td class="num">{{.Count}}</td><td><div class="bar" style="width: {{percent .Count $p}}%"></div></td></tr>{{else}}
+<tr><td>(无)</td></tr>{{end}}
+</table>{{end}}
+
+<div class="grid">
+<div>{{template "counts" (section "来源IP" .TopIPs)}}</div>
+<div>{{template "counts" (section "来源ASN" .TopASNs)}}</div>
+<div>{{template "counts" (section "目标主机名" .TopHostnames)}}</div>
+<div>{{template "counts" (section "登录用户名" .Usernames)}}</div>
+<div>{{template "counts" (section "协议版本" .Protocols)}}</div>
+<div>{{template "counts" (section "事件类型" .EventTypes)}}</div>
+</div>
+
+<h2>每日新增/回访IP</h2>
+<table>
+<tr><td class="key"><b>日期</b></td><td class="num"><b>新</b></td><td class="num"><b>回访</b></td><td></td></tr>
+{{range .Daily}}<tr><td class="key">{{.Day}}</td><td class="num">{{.New}}</td><td class="num">{{.Returning}}</td><td></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,251 @@
+// Package report 汇总蜜罐日志，生成攻击报告
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"fake-mc-server/internal/logger"
+)
+
+// Options 报告参数
+type Options struct {
+	Since time.Time // 统计窗口起点，之前的事件只用于判断 IP 是否为回访
+	Until time.Time // 统计窗口终点（不含）
+	Top   int       // 各排行榜保留的条目数
+}
+
+// Count 排行榜条目
+type Count struct {
+	Key   string `json:"key"`
+	Label string `json:"label,omitempty"` // 附加说明，如 ASN 所属组织
+	Count int    `json:"count"`
+}
+
+// HourBucket 每小时事件数
+type HourBucket struct {
+	Hour   time.Time `json:"hour"`
+	Events int       `json:"events"`
+}
+
+// DayIPs 每天活跃 IP 中首次出现与回访的数量
+type DayIPs struct {
+	Day       string `json:"day"`
+	New       int    `json:"new"`
+	Returning int    `json:"returning"`
+}
+
+// Report 攻击报告
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+	First       time.Time `json:"first_event"`
+	Last        time.Time `json:"last_event"`
+
+	Events       int `json:"events"`
+	Sessions     int `json:"sessions"` // 已结束的攻击会话数
+	UniqueIPs    int `json:"unique_ips"`
+	NewIPs       int `json:"new_ips"`       // 窗口内首次出现的 IP
+	ReturningIPs int `json:"returning_ips"` // 窗口之前已出现过的 IP
+
+	EventTypes   []Count      `json:"event_types"`
+	TopIPs       []Count      `json:"top_ips"`
+	TopASNs      []Count      `json:"top_asns"`
+	TopHostnames []Count      `json:"top_hostnames"`
+	Usernames    []Count      `json:"usernames"`
+	Protocols    []Count      `json:"protocols"`
+	Hourly       []HourBucket `json:"hourly"`
+	Daily        []DayIPs     `json:"daily"`
+}
+
+// Builder 逐条累计事件并生成报告，不保留事件本身
+type Builder struct {
+	opts Options
+
+	firstSeen map[string]time.Time // 所有读到的事件中每个 IP 的首次出现时间
+	ips       map[string]int
+	asns      map[uint32]int
+	asOrgs    map[uint32]string
+	hostnames map[string]int
+	usernames map[string]int
+	protocols map[int]int
+	types     map[string]int
+	hourly    map[time.Time]int
+	daily     map[string]map[string]struct{} // 日期 -> 当天活跃的 IP
+
+	events   int
+	sessions int
+	first    time.Time
+	last     time.Time
+}
+
+// NewBuilder 创建报告累计器
+func NewBuilder(opts Options) *Builder {
+	if opts.Top <= 0 {
+		opts.Top = 10
+	}
+	return &Builder{
+		opts:      opts,
+		firstSeen: make(map[string]time.Time),
+		ips:       make(map[string]int),
+		asns:      make(map[uint32]int),
+		asOrgs:    make(map[uint32]string),
+		hostnames: make(map[string]int),
+		usernames: make(map[string]int),
+		protocols: make(map[int]int),
+		types:     make(map[string]int),
+		hourly:    make(map[time.Time]int),
+		daily:     make(map[string]map[string]struct{}),
+	}
+}
+
+// Add 累计一条事件，文件读取顺序不影响结果
+func (b *Builder) Add(e *logger.HoneypotEvent) {
+	if e.ClientIP == "" {
+		return
+	}
+	if seen, ok := b.firstSeen[e.ClientIP]; !ok || e.Timestamp.Before(seen) {
+		b.firstSeen[e.ClientIP] = e.Timestamp
+	}
+	if !b.opts.Since.IsZero() && e.Timestamp.Before(b.opts.Since) {
+		return
+	}
+	if !b.opts.Until.IsZero() && !e.Timestamp.Before(b.opts.Until) {
+		return
+	}
+
+	// 会话摘要由原始事件汇总而来，只计数，避免重复统计
+	if e.EventType == "attack_session" {
+		if e.Session != nil && e.Session.Ended {
+			b.sessions++
+		}
+		return
+	}
+
+	b.events++
+	if b.first.IsZero() || e.Timestamp.Before(b.first) {
+		b.first = e.Timestamp
+	}
+	if e.Timestamp.After(b.last) {
+		b.last = e.Timestamp
+	}
+
+	b.ips[e.ClientIP]++
+	b.types[e.EventType]++
+	if e.ASN != 0 {
+		b.asns[e.ASN]++
+		if e.ASOrg != "" {
+			b.asOrgs[e.ASN] = e.ASOrg
+		}
+	}
+	switch e.EventType {
+	case "handshake":
+		if e.ServerAddress != "" {
+			b.hostnames[e.ServerAddress]++
+		}
+		b.protocols[e.ProtocolVersion]++
+	case "login_attempt":
+		if e.Username != "" {
+			b.usernames[e.Username]++
+		}
+	}
+
+	local := e.Timestamp.Local()
+	b.hourly[local.Truncate(time.Hour)]++
+	day := local.Format(time.DateOnly)
+	if b.daily[day] == nil {
+		b.daily[day] = make(map[string]struct{})
+	}
+	b.daily[day][e.ClientIP] = struct{}{}
+}
+
+// Report 生成报告
+func (b *Builder) Report() *Report {
+	r := &Report{
+		GeneratedAt:  time.Now(),
+		First:        b.first,
+		Last:         b.last,
+		Events:       b.events,
+		Sessions:     b.sessions,
+		UniqueIPs:    len(b.ips),
+		EventTypes:   top(b.types, 0, nil),
+		TopIPs:       top(b.ips, b.opts.Top, nil),
+		TopHostnames: top(b.hostnames, b.opts.Top, nil),
+		Usernames:    top(b.usernames, b.opts.Top, nil),
+	}
+
+	asns := make(map[string]int, len(b.asns))
+	for asn, n := range b.asns {
+		asns[fmt.Sprintf("AS%d", asn)] = n
+	}
+	r.TopASNs = top(asns, b.opts.Top, func(key string) string {
+		asn, _ := strconv.ParseUint(key[2:], 10, 32)
+		return b.asOrgs[uint32(asn)]
+	})
+
+	protocols := make(map[string]int, len(b.protocols))
+	for v, n := range b.protocols {
+		protocols[strconv.Itoa(v)] = n
+	}
+	r.Protocols = top(protocols, 0, nil)
+
+	// 窗口起点：指定了 since 时以其为准，否则以窗口内第一条事件为准
+	start := b.opts.Since
+	if start.IsZero() {
+		start = b.first
+	}
+	for ip := range b.ips {
+		if b.firstSeen[ip].Before(start) {
+			r.ReturningIPs++
+		} else {
+			r.NewIPs++
+		}
+	}
+
+	if !b.first.IsZero() {
+		for h := b.first.Local().Truncate(time.Hour); !h.After(b.last); h = h.Add(time.Hour) {
+			r.Hourly = append(r.Hourly, HourBucket{Hour: h, Events: b.hourly[h]})
+		}
+	}
+
+	for day, ips := range b.daily {
+		d := DayIPs{Day: day}
+		for ip := range ips {
+			if b.firstSeen[ip].Local().Format(time.DateOnly) == day {
+				d.New++
+			} else {
+				d.Returning++
+			}
+		}
+		r.Daily = append(r.Daily, d)
+	}
+	slices.SortFunc(r.Daily, func(a, b DayIPs) int { return cmp.Compare(a.Day, b.Day) })
+
+	return r
+}
+
+// top 按数量降序排列，数量相同时按键排序；n 为 0 时保留全部
+func top(counts map[string]int, n int, label func(string) string) []Count {
+	result := make([]Count, 0, len(counts))
+	for k, c := range counts {
+		result = append(result, Count{Key: k, Count: c})
+	}
+	slices.SortFunc(result, func(a, b Count) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	if label != nil {
+		for i := range result {
+			result[i].Label = label(result[i].Key)
+		}
+	}
+	return result
+}
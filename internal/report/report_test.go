@@ -0,0 +1,126 @@
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"fake-mc-server/internal/logger"
+)
+
+func TestReportFromRotatedLogs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "honeypot.log")
+	day1 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+
+	// 压缩的 CSV 备份：第一天
+	var csvBuf bytes.Buffer
+	cw := csv.NewWriter(&csvBuf)
+	cw.Write(logger.EventCSVHeader())
+	for _, e := range []*logger.HoneypotEvent{
+		{Timestamp: day1, ClientIP: "192.0.2.1", EventType: "handshake", ProtocolVersion: 767, ServerAddress: "mc.example.com", ASN: 64496, ASOrg: "Example Hosting"},
+		{Timestamp: day1.Add(time.Minute), ClientIP: "192.0.2.1", EventType: "login_attempt", Username: "Notch", ASN: 64496},
+	} {
+		cw.Write(logger.EventCSVRecord(e))
+	}
+	cw.Flush()
+	var gzBuf bytes.Buffer
+	gz := gzip.NewWriter(&gzBuf)
+	gz.Write(csvBuf.Bytes())
+	gz.Close()
+	writeFile(t, filepath.Join(dir, "honeypot-2024-05-01T23-00-00.000.log.gz"), gzBuf.Bytes())
+
+	// 当前 JSON 日志：第二天，一个回访 IP 和一个新 IP
+	var jsonBuf bytes.Buffer
+	for _, e := range []*logger.HoneypotEvent{
+		{Timestamp: day2, ClientIP: "192.0.2.1", EventType: "handshake", ProtocolVersion: 767, ServerAddress: "mc.example.com"},
+		{Timestamp: day2.Add(time.Hour), ClientIP: "198.51.100.9", EventType: "handshake", ProtocolVersion: 47, ServerAddress: "play.example.net"},
+		{Timestamp: day2.Add(time.Hour), ClientIP: "198.51.100.9", EventType: "login_attempt", Username: "Notch"},
+		{Timestamp: day2.Add(2 * time.Hour), ClientIP: "198.51.100.9", EventType: "attack_session", Session: &logger.AttackSession{Ended: true}},
+	} {
+		data, _ := sonic.Marshal(e)
+		jsonBuf.Write(append(data, '\n'))
+	}
+	writeFile(t, path, jsonBuf.Bytes())
+	// 不属于轮转备份的文件应被忽略
+	writeFile(t, filepath.Join(dir, "honeypot-notes.log"), []byte("x"))
+
+	files, err := LogFiles(path)
+	if err != nil {
+		t.Fatalf("LogFiles() error = %v", err)
+	}
+	if len(files) != 2 || files[1] != path {
+		t.Fatalf("LogFiles() = %v", files)
+	}
+
+	// 从当前文件读起，验证新/回访判断与读取顺序无关
+	b := NewBuilder(Options{Since: day2.Add(-time.Hour), Top: 5})
+	for _, f := range []string{files[1], files[0]} {
+		if err := ReadFile(f, func(e *logger.HoneypotEvent) error { b.Add(e); return nil }); err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", f, err)
+		}
+	}
+	r := b.Report()
+
+	if r.Events != 3 || r.Sessions != 1 || r.UniqueIPs != 2 {
+		t.Errorf("Events/Sessions/UniqueIPs = %d/%d/%d, want 3/1/2", r.Events, r.Sessions, r.UniqueIPs)
+	}
+	if r.NewIPs != 1 || r.ReturningIPs != 1 {
+		t.Errorf("NewIPs/ReturningIPs = %d/%d, want 1/1", r.NewIPs, r.ReturningIPs)
+	}
+	if len(r.TopIPs) != 2 || r.TopIPs[0].Key != "198.51.100.9" || r.TopIPs[0].Count != 2 {
+		t.Errorf("TopIPs = %+v", r.TopIPs)
+	}
+	if len(r.Usernames) != 1 || r.Usernames[0].Count != 1 {
+		t.Errorf("Usernames = %+v", r.Usernames)
+	}
+	if len(r.Protocols) != 2 || len(r.TopHostnames) != 2 {
+		t.Errorf("Protocols = %+v, TopHostnames = %+v", r.Protocols, r.TopHostnames)
+	}
+	if len(r.Hourly) != 2 || r.Hourly[0].Events != 1 || r.Hourly[1].Events != 2 {
+		t.Errorf("Hourly = %+v", r.Hourly)
+	}
+	if len(r.Daily) != 1 || r.Daily[0].New != 1 || r.Daily[0].Returning != 1 {
+		t.Errorf("Daily = %+v", r.Daily)
+	}
+
+	// 不设窗口时包含第一天，ASN 排行带组织名
+	b = NewBuilder(Options{})
+	for _, f := range files {
+		ReadFile(f, func(e *logger.HoneypotEvent) error { b.Add(e); return nil })
+	}
+	r = b.Report()
+	if len(r.TopASNs) != 1 || r.TopASNs[0].Key != "AS64496" || r.TopASNs[0].Count != 2 || r.TopASNs[0].Label != "Example Hosting" {
+		t.Errorf("TopASNs = %+v", r.TopASNs)
+	}
+
+	for name, write := range map[string]func(io.Writer, *Report) error{
+		"text": WriteText,
+		"json": WriteJSON,
+		"html": WriteHTML,
+	} {
+		var out bytes.Buffer
+		if err := write(&out, r); err != nil {
+			t.Fatalf("%s: error = %v", name, err)
+		}
+		if !strings.Contains(out.String(), "mc.example.com") {
+			t.Errorf("%s 输出缺少主机名", name)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,219 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+
+	"fake-mc-server/internal/netutil"
+)
+
+// RuleFile 特征规则文件结构
+type RuleFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule 单条特征规则
+// 所有非空条件同时满足时命中，命中后为事件添加 Label
+type Rule struct {
+	Label            string   `yaml:"label"`
+	CIDRs            []string `yaml:"cidrs"`
+	HostnamePattern  string   `yaml:"hostname_pattern"`
+	ProtocolVersions []int    `yaml:"protocol_versions"`
+	UsernamePattern  string   `yaml:"username_pattern"`
+	Fingerprints     []string `yaml:"fingerprints"` // 客户端指纹分类
+}
+
+// Input 待匹配的连接信息，未知的字段留空
+type Input struct {
+	IP          string
+	Hostname    string
+	Protocol    int
+	Username    string
+	Fingerprint string
+}
+
+// compiledRule 预编译的规则
+type compiledRule struct {
+	label        string
+	prefixes     []netip.Prefix
+	hostname     *regexp.Regexp
+	protocols    []int
+	username     *regexp.Regexp
+	fingerprints []string
+}
+
+// Database 扫描器特征库，支持热加载
+type Database struct {
+	path    string
+	logger  zerolog.Logger
+	rules   atomic.Pointer[[]compiledRule]
+	modTime time.Time
+}
+
+// NewDatabase 从规则文件创建特征库
+func NewDatabase(path string, logger zerolog.Logger) (*Database, error) {
+	db := &Database{
+		path:   path,
+		logger: logger.With().Str("component", "signature").Logger(),
+	}
+	if err := db.Reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Reload 重新加载规则文件，解析失败时保留旧规则
+func (db *Database) Reload() error {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return fmt.Errorf("读取特征规则文件失败: %w", err)
+	}
+
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return fmt.Errorf("读取特征规则文件失败: %w", err)
+	}
+
+	var file RuleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析特征规则文件失败: %w", err)
+	}
+
+	rules, err := compileRules(file.Rules)
+	if err != nil {
+		return err
+	}
+
+	db.rules.Store(&rules)
+	db.modTime = info.ModTime()
+
+	db.logger.Info().
+		Str("path", db.path).
+		Int("rules", len(rules)).
+		Msg("加载扫描器特征规则")
+	return nil
+}
+
+// Watch 定期检查规则文件的修改时间，变化时自动重新加载
+func (db *Database) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(db.path)
+			if err != nil || info.ModTime().Equal(db.modTime) {
+				continue
+			}
+			if err := db.Reload(); err != nil {
+				// 记下失败的版本，文件再次修改前不重复尝试，避免每次轮询都记录同样的错误
+				db.modTime = info.ModTime()
+				db.logger.Error().Err(err).Msg("重新加载特征规则失败，继续使用旧规则")
+			}
+		}
+	}
+}
+
+// Match 返回命中的标签（去重，按规则顺序）
+// db 为 nil 时返回 nil，方便调用方在未启用特征库时直接调用
+func (db *Database) Match(in Input) []string {
+	if db == nil {
+		return nil
+	}
+	rules := db.rules.Load()
+	if rules == nil {
+		return nil
+	}
+
+	var addr netip.Addr
+	if in.IP != "" {
+		addr, _ = netip.ParseAddr(in.IP)
+		addr = addr.Unmap()
+	}
+
+	var labels []string
+	for i := range *rules {
+		rule := &(*rules)[i]
+		if rule.match(addr, &in) && !slices.Contains(labels, rule.label) {
+			labels = append(labels, rule.label)
+		}
+	}
+	return labels
+}
+
+// match 检查单条规则
+func (r *compiledRule) match(addr netip.Addr, in *Input) bool {
+	if len(r.prefixes) > 0 {
+		if !addr.IsValid() || !slices.ContainsFunc(r.prefixes, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+			return false
+		}
+	}
+	if r.hostname != nil && (in.Hostname == "" || !r.hostname.MatchString(in.Hostname)) {
+		return false
+	}
+	if len(r.protocols) > 0 && !slices.Contains(r.protocols, in.Protocol) {
+		return false
+	}
+	if r.username != nil && (in.Username == "" || !r.username.MatchString(in.Username)) {
+		return false
+	}
+	if len(r.fingerprints) > 0 && !slices.Contains(r.fingerprints, in.Fingerprint) {
+		return false
+	}
+	return true
+}
+
+// compileRules 校验并预编译规则
+func compileRules(rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Label == "" {
+			return nil, fmt.Errorf("第 %d 条规则缺少 label", i+1)
+		}
+
+		cr := compiledRule{
+			label:        rule.Label,
+			protocols:    rule.ProtocolVersions,
+			fingerprints: rule.Fingerprints,
+		}
+
+		for _, cidr := range rule.CIDRs {
+			prefix, err := netutil.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s 的 CIDR 无效: %w", rule.Label, err)
+			}
+			cr.prefixes = append(cr.prefixes, prefix)
+		}
+
+		if rule.HostnamePattern != "" {
+			re, err := regexp.Compile(rule.HostnamePattern)
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s 的 hostname_pattern 无效: %w", rule.Label, err)
+			}
+			cr.hostname = re
+		}
+
+		if rule.UsernamePattern != "" {
+			re, err := regexp.Compile(rule.UsernamePattern)
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s 的 username_pattern 无效: %w", rule.Label, err)
+			}
+			cr.username = re
+		}
+
+		compiled = append(compiled, cr)
+	}
+	return compiled, nil
+}
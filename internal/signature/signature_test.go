@@ -0,0 +1,101 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestMatch(t *testing.T) {
+	rules := `
+rules:
+  - label: scanner
+    cidrs: ["10.0.0.0/8", "2001:db8::/32"]
+  - label: seeker
+    username_pattern: "^Seeker"
+    protocol_versions: [763]
+  - label: scanner
+    hostname_pattern: "^scan\\."
+  - label: bot
+    fingerprints: [bot_framework]
+`
+	path := filepath.Join(t.TempDir(), "signatures.yml")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatalf("写入规则文件失败: %v", err)
+	}
+
+	db, err := NewDatabase(path, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		in   Input
+		want []string
+	}{
+		{"IPv4 网段", Input{IP: "10.1.2.3"}, []string{"scanner"}},
+		{"IPv4 映射地址", Input{IP: "::ffff:10.1.2.3"}, []string{"scanner"}},
+		{"IPv6 网段", Input{IP: "2001:db8::1"}, []string{"scanner"}},
+		{"用户名与协议同时满足", Input{IP: "1.1.1.1", Username: "Seeker01", Protocol: 763}, []string{"seeker"}},
+		{"协议不满足", Input{IP: "1.1.1.1", Username: "Seeker01", Protocol: 765}, nil},
+		{"标签去重", Input{IP: "10.0.0.1", Hostname: "scan.example.com"}, []string{"scanner"}},
+		{"指纹", Input{IP: "1.1.1.1", Fingerprint: "bot_framework"}, []string{"bot"}},
+		{"未命中", Input{IP: "1.1.1.1"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := db.Match(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var nilDB *Database
+	if got := nilDB.Match(Input{IP: "10.0.0.1"}); got != nil {
+		t.Errorf("nil Database Match() = %v, want nil", got)
+	}
+}
+
+func TestReloadKeepsOldRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.yml")
+	if err := os.WriteFile(path, []byte("rules:\n  - label: a\n    cidrs: [\"10.0.0.0/8\"]\n"), 0o644); err != nil {
+		t.Fatalf("写入规则文件失败: %v", err)
+	}
+	db, err := NewDatabase(path, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("rules:\n  - label: a\n    cidrs: [\"bad\"]\n"), 0o644); err != nil {
+		t.Fatalf("写入规则文件失败: %v", err)
+	}
+	if err := db.Reload(); err == nil {
+		t.Fatal("Reload() 应返回错误")
+	}
+	if got := db.Match(Input{IP: "10.0.0.1"}); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("Match() = %v, want [a]", got)
+	}
+
+	// 同一个错误的文件只记录一次错误
+	var logs bytes.Buffer
+	db.logger = zerolog.New(&logs)
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("修改文件时间失败: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	db.Watch(ctx, 5*time.Millisecond)
+	if n := strings.Count(logs.String(), "重新加载特征规则失败"); n != 1 {
+		t.Errorf("记录了 %d 次重新加载失败, want 1", n)
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/logger"
+)
+
+// pruneInterval 按保留时长清理过期事件的间隔
+const pruneInterval = time.Hour
+
+func init() {
+	logger.RegisterSink("sqlite", newSink)
+}
+
+// ConfiguredPath 返回第一个 sqlite 投递目标的数据库路径，未配置时返回空
+func ConfiguredPath(cfg *config.HoneypotLoggingConfig) string {
+	for _, sink := range cfg.Sinks {
+		if sink.Type == "sqlite" {
+			return sink.Path
+		}
+	}
+	return ""
+}
+
+// sink 作为蜜罐日志投递目标的 SQLite 事件库
+type sink struct {
+	store     *Store
+	retention time.Duration
+	lastPrune time.Time
+}
+
+// newSink 按投递目标配置打开事件库
+func newSink(cfg config.EventSinkConfig) (logger.Sink, error) {
+	store, err := Open(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{store: store, retention: cfg.Retention}, nil
+}
+
+// Write 写入一批事件，并定期清理超出保留时长的事件
+func (s *sink) Write(ctx context.Context, events []*logger.HoneypotEvent) error {
+	if err := s.store.Insert(ctx, events); err != nil {
+		return err
+	}
+
+	if s.retention > 0 && time.Since(s.lastPrune) >= pruneInterval {
+		s.lastPrune = time.Now()
+		n, err := s.store.Prune(ctx, s.lastPrune.Add(-s.retention))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Info().Int64("deleted", n).Dur("retention", s.retention).Msg("已清理过期的 SQLite 事件")
+		}
+	}
+	return nil
+}
+
+// Close 关闭事件库
+func (s *sink) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,233 @@
+// Package store 将蜜罐事件保存到 SQLite，便于长期查询
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	_ "modernc.org/sqlite" // 纯 Go 实现，镜像使用 CGO_ENABLED=0 构建
+
+	"fake-mc-server/internal/logger"
+)
+
+// migrations 数据库结构迁移，按顺序执行，已执行的版本记录在 PRAGMA user_version
+// 常用过滤条件单独成列并建立索引，完整事件以 JSON 保存在 data 列，新增事件字段无需迁移
+var migrations = []string{
+	`CREATE TABLE events (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		ts             INTEGER NOT NULL, -- Unix 毫秒
+		client_ip      TEXT    NOT NULL,
+		event_type     TEXT    NOT NULL,
+		username       TEXT    NOT NULL DEFAULT '',
+		server_address TEXT    NOT NULL DEFAULT '',
+		data           TEXT    NOT NULL
+	);
+	CREATE INDEX idx_events_ts ON events(ts);
+	CREATE INDEX idx_events_ip_ts ON events(client_ip, ts);
+	CREATE INDEX idx_events_type_ts ON events(event_type, ts);
+	CREATE INDEX idx_events_username ON events(username) WHERE username <> '';
+	CREATE INDEX idx_events_server_address ON events(server_address) WHERE server_address <> '';`,
+}
+
+// Store SQLite 事件库
+type Store struct {
+	db *sql.DB
+}
+
+// Open 打开（必要时创建）事件库并执行迁移
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建事件库目录失败: %w", err)
+	}
+
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开事件库失败: %w", err)
+	}
+	// SQLite 同一时间只允许一个写入者，单连接避免 SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate 执行尚未执行的迁移
+func (s *Store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("读取事件库版本失败: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("事件库版本 %d 高于程序支持的版本 %d", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("执行事件库迁移 %d 失败: %w", i+1, err)
+		}
+		// PRAGMA 不支持参数绑定
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("更新事件库版本失败: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("执行事件库迁移 %d 失败: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Insert 在一个事务中写入一批事件
+func (s *Store) Insert(ctx context.Context, events []*logger.HoneypotEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO events (ts, client_ip, event_type, username, server_address, data) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("准备插入语句失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, event := range events {
+		data, err := sonic.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("序列化蜜罐事件失败: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, event.Timestamp.UnixMilli(), event.ClientIP, event.EventType, event.Username, event.ServerAddress, data); err != nil {
+			return fmt.Errorf("写入事件失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Prune 删除早于 before 的事件，返回删除的行数
+func (s *Store) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM events WHERE ts < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("清理过期事件失败: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Filter 查询条件，零值字段不参与过滤
+type Filter struct {
+	IP           string
+	Since        time.Time
+	Until        time.Time
+	Types        []string
+	UsernameLike string // SQL LIKE 模式，不含通配符时按包含匹配
+	HostLike     string // 同上，匹配握手中的服务器地址
+	Limit        int
+}
+
+// likePattern 不含通配符的输入按包含匹配
+func likePattern(s string) string {
+	if strings.ContainsAny(s, "%_") {
+		return s
+	}
+	return "%" + s + "%"
+}
+
+// Query 按时间倒序返回符合条件的事件
+func (s *Store) Query(ctx context.Context, f Filter) ([]*logger.HoneypotEvent, error) {
+	var events []*logger.HoneypotEvent
+	err := s.Each(ctx, f, func(event *logger.HoneypotEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// Each 按时间倒序逐条处理符合条件的事件，不在内存中保留结果
+func (s *Store) Each(ctx context.Context, f Filter, fn func(*logger.HoneypotEvent) error) error {
+	query, args := f.sql()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("查询事件失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("读取事件失败: %w", err)
+		}
+		event := &logger.HoneypotEvent{}
+		if err := sonic.Unmarshal(data, event); err != nil {
+			return fmt.Errorf("解析事件失败: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sql 生成查询语句和参数
+func (f Filter) sql() (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	if f.IP != "" {
+		where = append(where, "client_ip = ?")
+		args = append(args, f.IP)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "ts >= ?")
+		args = append(args, f.Since.UnixMilli())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "ts < ?")
+		args = append(args, f.Until.UnixMilli())
+	}
+	if len(f.Types) > 0 {
+		where = append(where, "event_type IN (?"+strings.Repeat(", ?", len(f.Types)-1)+")")
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+	if f.UsernameLike != "" {
+		where = append(where, "username LIKE ?")
+		args = append(args, likePattern(f.UsernameLike))
+	}
+	if f.HostLike != "" {
+		where = append(where, "server_address LIKE ?")
+		args = append(args, likePattern(f.HostLike))
+	}
+
+	query := "SELECT data FROM events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY ts DESC, id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	return query, args
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fake-mc-server/internal/logger"
+)
+
+func TestInsertQueryPrune(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []*logger.HoneypotEvent{
+		{Timestamp: base, ClientIP: "192.0.2.1", EventType: "handshake", ServerAddress: "mc.example.com"},
+		{Timestamp: base.Add(time.Minute), ClientIP: "192.0.2.1", EventType: "login_attempt", Username: "Notch", ASN: 64496},
+		{Timestamp: base.Add(2 * time.Minute), ClientIP: "198.51.100.9", EventType: "login_attempt", Username: "admin"},
+		{Timestamp: base.Add(3 * time.Minute), ClientIP: "198.51.100.9", EventType: "status_query"},
+	}
+	if err := s.Insert(ctx, events); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"全部", Filter{}, 4},
+		{"IP", Filter{IP: "192.0.2.1"}, 2},
+		{"类型", Filter{Types: []string{"login_attempt", "status_query"}}, 3},
+		{"时间范围", Filter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, 2},
+		{"用户名包含", Filter{UsernameLike: "otc"}, 1},
+		{"用户名通配", Filter{UsernameLike: "ad%"}, 1},
+		{"主机名", Filter{HostLike: "example"}, 1},
+		{"数量限制", Filter{Limit: 1}, 1},
+	}
+	for _, tt := range tests {
+		got, err := s.Query(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: Query() error = %v", tt.name, err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: Query() 返回 %d 条, want %d", tt.name, len(got), tt.want)
+		}
+	}
+
+	got, _ := s.Query(ctx, Filter{UsernameLike: "Notch"})
+	if len(got) != 1 || got[0].ASN != 64496 || !got[0].Timestamp.Equal(base.Add(time.Minute)) {
+		t.Errorf("事件未完整保存: %+v", got)
+	}
+
+	n, err := s.Prune(ctx, base.Add(2*time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("Prune() = %d, %v, want 2", n, err)
+	}
+	s.Close()
+
+	// 重新打开时不应重复执行迁移
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("重新打开 Open() error = %v", err)
+	}
+	defer s.Close()
+	if got, _ := s.Query(ctx, Filter{}); len(got) != 2 {
+		t.Errorf("清理后剩余 %d 条, want 2", len(got))
+	}
+}
@@ -0,0 +1,226 @@
+// Package tarpit 焦油坑：以极低的成本拖住可疑连接
+// 所有连接由同一个时间轮驱动，每隔固定间隔写出几个字节的响应，不为每个连接保留协程
+package tarpit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+	"fake-mc-server/internal/timewheel"
+)
+
+// 时间轮参数：100ms 精度，两层覆盖约 1.8 小时
+const (
+	wheelTick   = 100 * time.Millisecond
+	wheelSlots  = 256
+	wheelLevels = 2
+
+	writeTimeout = time.Second // 单次写出的超时，避免阻塞时间轮协程
+)
+
+// Conn 焦油坑接管的连接
+type Conn interface {
+	io.WriteCloser
+	SetWriteDeadline(t time.Time) error
+}
+
+// Tarpit 焦油坑
+type Tarpit struct {
+	config *config.TarpitConfig
+	logger zerolog.Logger
+	wheel  *timewheel.Wheel
+
+	mu     sync.Mutex
+	perIP  map[string]int     // 每个 IP 占用的名额
+	slots  int                // 已占用的名额（含尚未交出连接的）
+	holds  map[*hold]struct{} // 正在写出的连接
+	closed bool
+
+	total   atomic.Int64 // 累计接管的连接数
+	refused atomic.Int64 // 因名额已满未能进入焦油坑的次数
+}
+
+// NewTarpit 创建焦油坑，未启用时返回 nil
+func NewTarpit(cfg *config.TarpitConfig, logger zerolog.Logger) *Tarpit {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Tarpit{
+		config: cfg,
+		logger: logger.With().Str("component", "tarpit").Logger(),
+		wheel:  timewheel.New(wheelTick, wheelSlots, wheelLevels),
+		perIP:  make(map[string]int),
+		holds:  make(map[*hold]struct{}),
+	}
+}
+
+// Acquire 延迟达到阈值时为 IP 占用一个名额
+// 未启用、未达阈值或名额已满时返回 nil，调用方按原有方式延迟
+func (t *Tarpit) Acquire(ip string, delay time.Duration) *Slot {
+	if t == nil || delay < t.config.DelayThreshold {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	if t.slots >= t.config.MaxConnections || t.perIP[ip] >= t.config.MaxPerIP {
+		t.refused.Add(1)
+		return nil
+	}
+	t.slots++
+	t.perIP[ip]++
+	return &Slot{tarpit: t, ip: ip}
+}
+
+// release 归还名额
+func (t *Tarpit) release(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.slots--
+	if t.perIP[ip]--; t.perIP[ip] <= 0 {
+		delete(t.perIP, ip)
+	}
+}
+
+// Run 驱动时间轮，ctx 取消后关闭所有被拖住的连接
+func (t *Tarpit) Run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.wheel.Run(ctx)
+
+	// 时间轮已停止，不会再有回调并发访问 holds
+	t.mu.Lock()
+	t.closed = true
+	holds := make([]*hold, 0, len(t.holds))
+	for h := range t.holds {
+		holds = append(holds, h)
+	}
+	t.mu.Unlock()
+	for _, h := range holds {
+		h.finish()
+	}
+}
+
+// GetStats 获取统计信息
+func (t *Tarpit) GetStats() map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	active := len(t.holds)
+	t.mu.Unlock()
+	return map[string]interface{}{
+		"active_connections":  active,
+		"total_connections":   t.total.Load(),
+		"refused_connections": t.refused.Load(),
+	}
+}
+
+// Slot 焦油坑名额，由处理连接的协程持有
+// 交出连接后名额随连接结束归还；处理中途出错时需调用 Release
+type Slot struct {
+	tarpit *Tarpit
+	ip     string
+	used   bool
+}
+
+// Release 未交出连接时归还名额，可重复调用
+func (s *Slot) Release() {
+	if s == nil || s.used {
+		return
+	}
+	s.used = true
+	s.tarpit.release(s.ip)
+}
+
+// Hold 接管连接：按配置的间隔逐块写出 data，写完、出错或超过最长保持时间后关闭连接
+// 调用后调用方不能再读写或关闭 conn
+func (s *Slot) Hold(conn Conn, data []byte) {
+	if s == nil || s.used {
+		return
+	}
+	s.used = true
+	t := s.tarpit
+	h := &hold{
+		tarpit:   t,
+		ip:       s.ip,
+		conn:     conn,
+		data:     data,
+		start:    time.Now(),
+		deadline: time.Now().Add(t.config.MaxDuration),
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		conn.Close()
+		t.release(s.ip)
+		return
+	}
+	t.holds[h] = struct{}{}
+	t.mu.Unlock()
+
+	t.total.Add(1)
+	t.wheel.Schedule(t.config.Interval, h.step)
+}
+
+// hold 一个被拖住的连接，只在时间轮协程中访问
+type hold struct {
+	tarpit   *Tarpit
+	ip       string
+	conn     Conn
+	data     []byte
+	written  int
+	start    time.Time
+	deadline time.Time
+}
+
+// step 写出下一块数据并安排下一次写出
+func (h *hold) step() {
+	now := time.Now()
+	if now.After(h.deadline) {
+		h.finish()
+		return
+	}
+
+	end := min(h.written+h.tarpit.config.ChunkSize, len(h.data))
+	h.conn.SetWriteDeadline(now.Add(writeTimeout))
+	n, err := h.conn.Write(h.data[h.written:end])
+	h.written += n
+	if err != nil || h.written >= len(h.data) {
+		h.finish()
+		return
+	}
+	h.tarpit.wheel.Schedule(h.tarpit.config.Interval, h.step)
+}
+
+// finish 关闭连接并归还名额
+func (h *hold) finish() {
+	h.conn.Close()
+
+	t := h.tarpit
+	t.mu.Lock()
+	_, ok := t.holds[h]
+	delete(t.holds, h)
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+	t.release(h.ip)
+
+	t.logger.Debug().
+		Str("ip", h.ip).
+		Int("bytes", h.written).
+		Dur("duration", time.Since(h.start)).
+		Msg("焦油坑连接结束")
+}
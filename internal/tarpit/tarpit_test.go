@@ -0,0 +1,109 @@
+package tarpit
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"fake-mc-server/internal/config"
+)
+
+// fakeConn 记录写入的数据
+type fakeConn struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+	closed chan struct{}
+}
+
+func newFakeConn() *fakeConn { return &fakeConn{closed: make(chan struct{})} }
+
+func (c *fakeConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes++
+	return c.buf.Write(p)
+}
+
+func (c *fakeConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+
+func TestTarpit(t *testing.T) {
+	cfg := &config.TarpitConfig{
+		Enabled:        true,
+		DelayThreshold: time.Second,
+		Interval:       wheelTick,
+		ChunkSize:      2,
+		MaxDuration:    time.Minute,
+		MaxConnections: 3,
+		MaxPerIP:       2,
+	}
+	tp := NewTarpit(cfg, zerolog.Nop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tp.Run(ctx)
+		close(done)
+	}()
+
+	if tp.Acquire("192.0.2.1", 500*time.Millisecond) != nil {
+		t.Error("未达阈值不应进入焦油坑")
+	}
+	a := tp.Acquire("192.0.2.1", time.Second)
+	b := tp.Acquire("192.0.2.1", time.Second)
+	if a == nil || b == nil || tp.Acquire("192.0.2.1", time.Second) != nil {
+		t.Fatal("每个 IP 最多 2 个名额")
+	}
+	c := tp.Acquire("192.0.2.2", time.Second)
+	if c == nil || tp.Acquire("192.0.2.3", time.Second) != nil {
+		t.Fatal("总名额最多 3 个")
+	}
+	b.Release()
+	b.Release()
+	if tp.Acquire("192.0.2.3", time.Second) == nil {
+		t.Error("归还后应能再次占用名额")
+	}
+
+	// 数据分块写出，写完后关闭连接并归还名额
+	conn := newFakeConn()
+	a.Hold(conn, []byte("hello"))
+	select {
+	case <-conn.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("写完后应关闭连接")
+	}
+	conn.mu.Lock()
+	if conn.buf.String() != "hello" || conn.writes != 3 {
+		t.Errorf("写出 %q（%d 次），want %q（3 次）", conn.buf.String(), conn.writes, "hello")
+	}
+	conn.mu.Unlock()
+	if tp.Acquire("192.0.2.1", time.Second) == nil {
+		t.Error("连接结束后应归还名额")
+	}
+
+	// 停止时关闭所有被拖住的连接
+	pending := newFakeConn()
+	c.Hold(pending, bytes.Repeat([]byte{'x'}, 1000))
+	cancel()
+	<-done
+	select {
+	case <-pending.closed:
+	default:
+		t.Error("停止后应关闭被拖住的连接")
+	}
+	if stats := tp.GetStats(); stats["total_connections"] != int64(2) || stats["active_connections"] != 0 {
+		t.Errorf("GetStats() = %v", stats)
+	}
+}
@@ -0,0 +1,182 @@
+// Package timewheel 分层时间轮：大量定时任务共用一个协程和一个 ticker，添加和取消都是 O(1)
+package timewheel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Timer 时间轮上的一个任务
+type Timer struct {
+	fn     func()
+	expire uint64 // 到期的 tick
+	level  int    // 所在层，-1 表示已执行或已取消
+	slot   int
+	wheel  *Wheel
+	prev   *Timer
+	next   *Timer
+}
+
+// Stop 取消任务，任务已执行或已取消时返回 false
+func (t *Timer) Stop() bool {
+	if t == nil {
+		return false
+	}
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t.level < 0 {
+		return false
+	}
+	w.remove(t)
+	return true
+}
+
+// Wheel 分层时间轮，精度为一个 tick
+// 第 i 层每格跨度为 slots^i 个 tick，高层的任务在所在格到来时降级到低层；
+// 超出最高层范围的任务先放在最高层，降级时重新计算位置
+// 任务回调在时间轮协程中依次执行，不能阻塞；回调中可以再次调用 Schedule
+type Wheel struct {
+	tick   time.Duration
+	slots  int
+	mu     sync.Mutex
+	levels [][]*Timer // 每层每个槽位的双向链表头
+	now    uint64     // 已经走过的 tick 数
+	count  int
+}
+
+// New 创建时间轮，共 levels 层，每层 slots 个槽位，每 tick 前进一格
+func New(tick time.Duration, slots, levels int) *Wheel {
+	w := &Wheel{
+		tick:   tick,
+		slots:  slots,
+		levels: make([][]*Timer, levels),
+	}
+	for i := range w.levels {
+		w.levels[i] = make([]*Timer, slots)
+	}
+	return w
+}
+
+// Schedule 在 delay 之后执行 fn，不足一个 tick 的按一个 tick 计算
+func (w *Wheel) Schedule(delay time.Duration, fn func()) *Timer {
+	ticks := uint64((delay + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t := &Timer{fn: fn, expire: w.now + ticks, wheel: w}
+	w.insert(t)
+	w.count++
+	return t
+}
+
+// Len 返回等待执行的任务数
+func (w *Wheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Run 驱动时间轮，直到 ctx 取消；取消后未执行的任务被丢弃
+func (w *Wheel) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.advance()
+		}
+	}
+}
+
+// advance 前进一格，把到达的高层槽位降级，然后执行到期的任务
+func (w *Wheel) advance() {
+	w.mu.Lock()
+	w.now++
+
+	// 第 i 层的槽位在 now 为 slots^i 的整数倍时到达，从高层往低层降级
+	top := 0
+	for span := uint64(w.slots); top+1 < len(w.levels) && w.now%span == 0; span *= uint64(w.slots) {
+		top++
+	}
+	for level := top; level > 0; level-- {
+		slot := int(w.now/w.span(level)) % w.slots
+		t := w.levels[level][slot]
+		w.levels[level][slot] = nil
+		for t != nil {
+			next := t.next
+			t.prev, t.next = nil, nil
+			w.insert(t)
+			t = next
+		}
+	}
+
+	slot := int(w.now % uint64(w.slots))
+	var due []*Timer
+	for t := w.levels[0][slot]; t != nil; {
+		next := t.next
+		w.remove(t)
+		due = append(due, t)
+		t = next
+	}
+	w.mu.Unlock()
+
+	for _, t := range due {
+		t.fn()
+	}
+}
+
+// span 第 level 层每格跨越的 tick 数
+func (w *Wheel) span(level int) uint64 {
+	span := uint64(1)
+	for range level {
+		span *= uint64(w.slots)
+	}
+	return span
+}
+
+// insert 按剩余时间把任务放入对应的层和槽位，调用方需持有锁
+func (w *Wheel) insert(t *Timer) {
+	remaining := uint64(0)
+	if t.expire > w.now {
+		remaining = t.expire - w.now
+	}
+	expire := t.expire
+	level := 0
+	for level+1 < len(w.levels) && remaining >= w.span(level+1) {
+		level++
+	}
+	if limit := w.span(level + 1); remaining >= limit {
+		// 超出最高层范围，先放在最高层最远的槽位
+		expire = w.now + limit - 1
+	}
+
+	t.level = level
+	t.slot = int(expire/w.span(level)) % w.slots
+	t.next = w.levels[level][t.slot]
+	if t.next != nil {
+		t.next.prev = t
+	}
+	w.levels[level][t.slot] = t
+}
+
+// remove 从槽位链表中摘除任务，调用方需持有锁
+func (w *Wheel) remove(t *Timer) {
+	if t.prev != nil {
+		t.prev.next = t.next
+	} else {
+		w.levels[t.level][t.slot] = t.next
+	}
+	if t.next != nil {
+		t.next.prev = t.prev
+	}
+	t.prev, t.next = nil, nil
+	t.level = -1
+	w.count--
+}
@@ -0,0 +1,61 @@
+package timewheel
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestScheduleAndStop(t *testing.T) {
+	w := New(10*time.Millisecond, 4, 2)
+	var fired []int
+	for _, ticks := range []int{1, 4, 5, 9} {
+		w.Schedule(time.Duration(ticks)*10*time.Millisecond, func() { fired = append(fired, ticks) })
+	}
+	stopped := w.Schedule(20*time.Millisecond, func() { t.Error("已取消的任务被执行") })
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop() 应只在首次调用时返回 true")
+	}
+	if w.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", w.Len())
+	}
+
+	want := map[int][]int{1: {1}, 4: {1, 4}, 5: {1, 4, 5}, 9: {1, 4, 5, 9}}
+	for tick := 1; tick <= 9; tick++ {
+		w.advance()
+		if exp, ok := want[tick]; ok && len(fired) != len(exp) {
+			t.Fatalf("tick %d: fired = %v, want %v", tick, fired, exp)
+		}
+	}
+	if w.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", w.Len())
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	// 3 层各 8 格覆盖 512 tick，更长的任务需要在最高层多次降级
+	w := New(time.Millisecond, 8, 3)
+	const total = 2000
+	firedAt := make(map[int]uint64)
+	expected := make(map[int]uint64)
+	for i := range 500 {
+		if i%50 == 0 {
+			w.advance() // 从不同的起点添加
+		}
+		ticks := 1 + rand.IntN(total-10)
+		expected[i] = w.now + uint64(ticks)
+		w.Schedule(time.Duration(ticks)*time.Millisecond, func() { firedAt[i] = w.now })
+	}
+
+	for range total {
+		w.advance()
+	}
+	if len(firedAt) != len(expected) || w.Len() != 0 {
+		t.Fatalf("执行了 %d 个任务，剩余 %d，want %d", len(firedAt), w.Len(), len(expected))
+	}
+	for i, want := range expected {
+		if firedAt[i] != want {
+			t.Errorf("任务 %d 在 tick %d 执行，want %d", i, firedAt[i], want)
+		}
+	}
+}